@@ -0,0 +1,375 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// adguardvpn-cli-fake emulates the subset of adguardvpn-cli used by adgui.
+// Point ADGUARD_CMD at the binary to run adgui without a real VPN.
+//
+// State is kept in ADGUARD_FAKE_STATE_DIR (default $XDG_RUNTIME_DIR/adguardvpn-cli-fake).
+// Faults are read from ADGUARD_FAKE_FAULTS or from the "faults" file in the state
+// directory, so they can be changed while adgui is running:
+//
+//	auth         every command except --version reports an expired login
+//	sudo         connect and disconnect fail as if sudo refused elevation
+//	connect      connect fails with a generic error
+//	exclusions   site-exclusions add/remove/mode fail
+//	slow-connect connect sleeps ADGUARD_FAKE_CONNECT_DELAY (default 5s) before succeeding
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	bold  = "\x1b[1m"
+	reset = "\x1b[0m"
+
+	fakeVersion         = "1.5.10-fake"
+	defaultConnectDelay = 5 * time.Second
+)
+
+type location struct {
+	ISO     string
+	Country string
+	City    string
+	Ping    int
+}
+
+var knownLocations = []location{
+	{"LV", "Latvia", "Riga", 29},
+	{"DE", "Germany", "Frankfurt", 37},
+	{"DK", "Denmark", "Copenhagen", 42},
+	{"NL", "Netherlands", "Amsterdam", 45},
+	{"FR", "France", "Paris", 46},
+	{"FI", "Finland", "Helsinki", 50},
+	{"GB", "United Kingdom", "London", 52},
+	{"DE", "Germany", "Berlin", 53},
+	{"PL", "Poland", "Warsaw", 53},
+	{"SE", "Sweden", "Stockholm", 68},
+	{"US", "United States", "New York", 98},
+	{"JP", "Japan", "Tokyo", 241},
+}
+
+type state struct {
+	Connected  bool                `json:"connected"`
+	City       string              `json:"city,omitempty"`
+	Mode       string              `json:"mode"`
+	Exclusions map[string][]string `json:"exclusions"`
+}
+
+type fakeCLI struct {
+	dir    string
+	self   string
+	faults map[string]bool
+}
+
+func main() {
+	cli := &fakeCLI{
+		dir:  stateDir(),
+		self: os.Args[0],
+	}
+	if err := os.MkdirAll(cli.dir, 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create state dir: %v\n", err)
+		os.Exit(1)
+	}
+	cli.faults = cli.loadFaults()
+	os.Exit(cli.run(os.Args[1:]))
+}
+
+func stateDir() string {
+	if dir := os.Getenv("ADGUARD_FAKE_STATE_DIR"); dir != "" {
+		return dir
+	}
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		return filepath.Join(runtime, "adguardvpn-cli-fake")
+	}
+	return filepath.Join(os.TempDir(), "adguardvpn-cli-fake-"+strconv.Itoa(os.Getuid()))
+}
+
+func (c *fakeCLI) loadFaults() map[string]bool {
+	faults := make(map[string]bool)
+	raw := os.Getenv("ADGUARD_FAKE_FAULTS")
+	if data, err := os.ReadFile(filepath.Join(c.dir, "faults")); err == nil {
+		raw += "," + string(data)
+	}
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == ' '
+	}) {
+		faults[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+	return faults
+}
+
+func (c *fakeCLI) run(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: adguardvpn-cli <command>")
+		return 1
+	}
+	if args[0] == "--version" || args[0] == "-v" {
+		fmt.Printf("AdGuard VPN CLI %s\n", fakeVersion)
+		return 0
+	}
+	if c.faults["auth"] {
+		fmt.Printf("You are not logged in. Please run `%s login` first\n", c.self)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		return c.status()
+	case "connect":
+		return c.connect(args[1:])
+	case "disconnect":
+		return c.disconnect()
+	case "list-locations":
+		return c.listLocations()
+	case "license":
+		return c.license()
+	case "site-exclusions":
+		return c.siteExclusions(args[1:])
+	default:
+		fmt.Printf("Unknown command: %s\n", args[0])
+		return 1
+	}
+}
+
+func (c *fakeCLI) status() int {
+	st, err := c.load()
+	if err != nil {
+		return fail(err)
+	}
+	if !st.Connected {
+		fmt.Println("VPN is disconnected")
+		return 0
+	}
+	fmt.Printf("Connected to %s%s%s in %sTUN%s mode, running on %stun0%s\n",
+		bold, strings.ToUpper(st.City), reset, bold, reset, bold, reset)
+	fmt.Println("Warning: System DNS could not be configured. DNS queries may bypass the VPN tunnel")
+	fmt.Printf("You can disconnect by running `%s disconnect`\n", c.self)
+	return 0
+}
+
+func (c *fakeCLI) connect(args []string) int {
+	if c.faults["sudo"] {
+		fmt.Println("sudo: a password is required")
+		fmt.Println("Failed to configure the TUN interface: permission denied")
+		return 1
+	}
+	if c.faults["connect"] {
+		fmt.Println("Failed to connect: server did not respond")
+		return 1
+	}
+
+	target := knownLocations[0]
+	if len(args) >= 2 && args[0] == "-l" {
+		name := strings.Join(args[1:], " ")
+		idx := slices.IndexFunc(knownLocations, func(loc location) bool {
+			return strings.EqualFold(loc.City, name) || strings.EqualFold(loc.ISO, name) ||
+				strings.EqualFold(loc.Country, name)
+		})
+		if idx < 0 {
+			fmt.Printf("Location %s%s%s not found\n", bold, name, reset)
+			return 1
+		}
+		target = knownLocations[idx]
+	}
+
+	if c.faults["slow-connect"] {
+		delay := defaultConnectDelay
+		if raw := os.Getenv("ADGUARD_FAKE_CONNECT_DELAY"); raw != "" {
+			if parsed, err := time.ParseDuration(raw); err == nil {
+				delay = parsed
+			}
+		}
+		time.Sleep(delay)
+	}
+
+	err := c.update(func(st *state) {
+		st.Connected = true
+		st.City = target.City
+	})
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("Successfully Connected to %s%s%s in %sTUN%s mode, running on %stun0%s\n",
+		bold, strings.ToUpper(target.City), reset, bold, reset, bold, reset)
+	return 0
+}
+
+func (c *fakeCLI) disconnect() int {
+	if c.faults["sudo"] {
+		fmt.Println("sudo: a password is required")
+		return 1
+	}
+	err := c.update(func(st *state) {
+		st.Connected = false
+		st.City = ""
+	})
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Println("VPN is disconnected")
+	return 0
+}
+
+func (c *fakeCLI) listLocations() int {
+	fmt.Printf("%s%-5s %-20s %-30s %s\n%s", bold, "ISO", "COUNTRY", "CITY", "PING ESTIMATE", reset)
+	for _, loc := range knownLocations {
+		fmt.Printf("%-5s %-20s %-30s %-10d\n", loc.ISO, loc.Country, loc.City, loc.Ping)
+	}
+	return 0
+}
+
+func (c *fakeCLI) license() int {
+	fmt.Printf("Logged in as %sfake@example.com%s\n", bold, reset)
+	fmt.Printf("You are using the %sPREMIUM%s version\n", bold, reset)
+	fmt.Printf("Up to %s10%s devices simultaneously\n", bold, reset)
+	fmt.Printf("Your subscription is valid until %s\n", time.Now().AddDate(1, 0, 0).Format(time.DateOnly))
+	return 0
+}
+
+func (c *fakeCLI) siteExclusions(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Usage: adguardvpn-cli site-exclusions <show|add|remove|mode>")
+		return 1
+	}
+	if args[0] != "show" && c.faults["exclusions"] {
+		fmt.Println("Failed to update site exclusions")
+		return 1
+	}
+
+	switch args[0] {
+	case "show":
+		st, err := c.load()
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Exclusions for %s%s%s mode:\n", bold, strings.ToUpper(st.Mode), reset)
+		for _, domain := range st.Exclusions[st.Mode] {
+			fmt.Println(domain)
+		}
+		return 0
+	case "add", "remove":
+		if len(args) < 2 {
+			fmt.Printf("Usage: adguardvpn-cli site-exclusions %s <domain>\n", args[0])
+			return 1
+		}
+		domains := args[1:]
+		err := c.update(func(st *state) {
+			list := st.Exclusions[st.Mode]
+			for _, domain := range domains {
+				idx := slices.IndexFunc(list, func(item string) bool { return strings.EqualFold(item, domain) })
+				switch {
+				case args[0] == "add" && idx < 0:
+					list = append(list, domain)
+				case args[0] == "remove" && idx >= 0:
+					list = slices.Delete(list, idx, idx+1)
+				}
+			}
+			st.Exclusions[st.Mode] = list
+		})
+		if err != nil {
+			return fail(err)
+		}
+		verb := "added to"
+		if args[0] == "remove" {
+			verb = "removed from"
+		}
+		fmt.Printf("%s %s exclusions\n", strings.Join(domains, ", "), verb)
+		return 0
+	case "mode":
+		if len(args) < 2 || (args[1] != "general" && args[1] != "selective") {
+			fmt.Println("Usage: adguardvpn-cli site-exclusions mode <general|selective>")
+			return 1
+		}
+		if err := c.update(func(st *state) { st.Mode = args[1] }); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Exclusions mode set to %s%s%s\n", bold, strings.ToUpper(args[1]), reset)
+		return 0
+	default:
+		fmt.Printf("Unknown site-exclusions command: %s\n", args[0])
+		return 1
+	}
+}
+
+func (c *fakeCLI) statePath() string {
+	return filepath.Join(c.dir, "state.json")
+}
+
+func (c *fakeCLI) load() (state, error) {
+	st := state{Mode: "general"}
+	data, err := os.ReadFile(c.statePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			st.Exclusions = make(map[string][]string)
+			return st, nil
+		}
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, err
+	}
+	if st.Mode == "" {
+		st.Mode = "general"
+	}
+	if st.Exclusions == nil {
+		st.Exclusions = make(map[string][]string)
+	}
+	return st, nil
+}
+
+// update applies fn to the state under an exclusive lock so concurrent
+// invocations (status polling while connecting) never lose writes.
+func (c *fakeCLI) update(fn func(*state)) error {
+	lock, err := os.OpenFile(filepath.Join(c.dir, "state.lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Close()
+	}()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	st, err := c.load()
+	if err != nil {
+		return err
+	}
+	fn(&st)
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.statePath())
+}
+
+func fail(err error) int {
+	fmt.Printf("Error: %v\n", err)
+	return 1
+}
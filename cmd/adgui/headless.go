@@ -0,0 +1,340 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"adgui/commands"
+	"adgui/control"
+)
+
+const headlessUsage = `Usage: adgui [command] [flags]
+
+Without a command adgui starts the tray application.
+
+Commands:
+  status [--json]                      show the VPN connection status
+  connect [city|iso]                   connect to a city, the fastest location of a country or the best location
+  disconnect                           disconnect the VPN
+  locations [--sort iso|country|city|ping] [--desc] [--json]
+                                       list locations, bookmarked first
+  history [--json]                     show the connection history
+  bookmarks [--json]                   show bookmarked locations
+  help                                 show this help
+`
+
+var (
+	errUsage = errors.New("invalid usage")
+	// errHeadlessSudo replaces the GUI password dialog: there is nothing to show it in.
+	errHeadlessSudo = errors.New("sudo password needed: run `sudo -v` first or configure passwordless sudo")
+)
+
+// headless runs one subcommand without creating any window. VPN operations go
+// to the running adgui instance through its control socket when one answers,
+// otherwise to a private manager created on demand, so that history and
+// bookmarks work without adguardvpn-cli.
+type headless struct {
+	ctx        context.Context
+	out        io.Writer
+	socketPath string
+	newMgr     func() *commands.VPNManager
+	mgr        *commands.VPNManager
+	client     *control.Client
+	dialed     bool
+}
+
+type headlessCommand func(h *headless, args []string) error
+
+var headlessCommands = map[string]headlessCommand{
+	"status":     (*headless).status,
+	"connect":    (*headless).connect,
+	"disconnect": (*headless).disconnect,
+	"locations":  (*headless).locations,
+	"history":    (*headless).history,
+	"bookmarks":  (*headless).bookmarks,
+	"help":       (*headless).help,
+	"-h":         (*headless).help,
+	"--help":     (*headless).help,
+}
+
+// isHeadlessCommand reports whether the first argument selects a subcommand instead of the GUI.
+func isHeadlessCommand(name string) bool {
+	_, ok := headlessCommands[name]
+	return ok
+}
+
+// runHeadless executes a subcommand and returns the process exit code:
+// 0 on success, 1 when the operation failed and 2 on invalid usage.
+// Cancelling ctx stops a CLI command run by the private manager.
+func runHeadless(ctx context.Context, name string, args []string, out, errOut io.Writer, socketPath string, newMgr func() *commands.VPNManager) int {
+	cmd, ok := headlessCommands[name]
+	if !ok {
+		_, _ = fmt.Fprint(errOut, headlessUsage)
+		return 2
+	}
+	h := &headless{ctx: ctx, out: out, socketPath: socketPath, newMgr: newMgr}
+	defer h.close()
+
+	if err := cmd(h, args); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprint(errOut, headlessUsage)
+			return 2
+		}
+		_, _ = fmt.Fprintf(errOut, "adgui %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func (h *headless) manager() *commands.VPNManager {
+	if h.mgr == nil {
+		h.mgr = h.newMgr()
+		h.mgr.SetPasswordPrompt(func() ([]byte, error) {
+			return nil, errHeadlessSudo
+		})
+	}
+	return h.mgr
+}
+
+// remote returns the control client of the running instance, or nil.
+func (h *headless) remote() *control.Client {
+	if !h.dialed && h.socketPath != "" {
+		h.dialed = true
+		if client, err := control.Dial(h.socketPath); err == nil {
+			h.client = client
+		}
+	}
+	return h.client
+}
+
+func (h *headless) close() {
+	if h.client != nil {
+		_ = h.client.Close()
+	}
+	if h.mgr != nil {
+		_ = h.mgr.Close()
+	}
+}
+
+// parseFlags parses subcommand flags; positional arguments beyond maxArgs are a usage error.
+func parseFlags(fs *flag.FlagSet, args []string, maxArgs int) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() > maxArgs {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, fs.Arg(maxArgs))
+	}
+	return nil
+}
+
+func (h *headless) writeJSON(value any) error {
+	enc := json.NewEncoder(h.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func (h *headless) help(_ []string) error {
+	_, err := fmt.Fprint(h.out, headlessUsage)
+	return err
+}
+
+func (h *headless) printStatus(st control.Status) error {
+	var b strings.Builder
+	b.WriteString(st.State)
+	if st.City != "" {
+		fmt.Fprintf(&b, ": %s", st.City)
+		if st.Country != "" {
+			fmt.Fprintf(&b, " (%s)", st.Country)
+		}
+	}
+	if st.Mode != "" {
+		fmt.Fprintf(&b, " %s", st.Mode)
+	}
+	if st.Interface != "" {
+		fmt.Fprintf(&b, " %s", st.Interface)
+	}
+	if st.SOCKSPort != 0 {
+		fmt.Fprintf(&b, " port %d", st.SOCKSPort)
+	}
+	if st.Reason != "" {
+		fmt.Fprintf(&b, ": %s", st.Reason)
+	}
+	b.WriteByte('\n')
+	if st.KillSwitch {
+		b.WriteString("kill switch: engaged\n")
+	}
+	for _, warning := range st.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", warning)
+	}
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *headless) status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	var st control.Status
+	if client := h.remote(); client != nil {
+		if err := client.Call(control.MethodStatus, control.StatusParams{Refresh: true}, &st); err != nil {
+			return err
+		}
+	} else {
+		h.manager().RefreshStatus(h.ctx)
+		st = control.StatusOf(h.manager())
+	}
+	if *asJSON {
+		return h.writeJSON(st)
+	}
+	return h.printStatus(st)
+}
+
+func (h *headless) connect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	var st control.Status
+	var err error
+	if client := h.remote(); client != nil {
+		err = client.Call(control.MethodConnect, control.ConnectParams{Target: fs.Arg(0)}, &st)
+	} else {
+		st, err = control.Connect(h.ctx, h.manager(), fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	return h.printStatus(st)
+}
+
+func (h *headless) disconnect(args []string) error {
+	fs := flag.NewFlagSet("disconnect", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	var st control.Status
+	var err error
+	if client := h.remote(); client != nil {
+		err = client.Call(control.MethodDisconnect, nil, &st)
+	} else {
+		st, err = control.Disconnect(h.ctx, h.manager())
+	}
+	if err != nil {
+		return err
+	}
+	return h.printStatus(st)
+}
+
+func (h *headless) locations(args []string) error {
+	fs := flag.NewFlagSet("locations", flag.ContinueOnError)
+	sortBy := fs.String("sort", "ping", "")
+	desc := fs.Bool("desc", false, "")
+	asJSON := fs.Bool("json", false, "")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	column, ok := control.ParseSortColumn(*sortBy)
+	if !ok {
+		return fmt.Errorf("%w: unknown sort column %q", errUsage, *sortBy)
+	}
+
+	var locs []control.Location
+	var err error
+	if client := h.remote(); client != nil {
+		err = client.Call(control.MethodLocations, control.LocationsParams{Sort: *sortBy, Desc: *desc}, &locs)
+	} else {
+		locs, err = control.Locations(h.ctx, h.manager(), column, !*desc)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return h.writeJSON(locs)
+	}
+	tw := tabwriter.NewWriter(h.out, 0, 4, 2, ' ', 0)
+	for _, loc := range locs {
+		mark := " "
+		if loc.Bookmarked {
+			mark = "*"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", mark, loc.ISO, loc.Country, loc.City, loc.Ping)
+	}
+	return tw.Flush()
+}
+
+func (h *headless) history(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	history, err := commands.LoadConnectionHistory()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if history == nil {
+			history = []commands.ConnectionHistoryEntry{}
+		}
+		return h.writeJSON(history)
+	}
+	tw := tabwriter.NewWriter(h.out, 0, 4, 2, ' ', 0)
+	for _, entry := range history {
+		ended := "…"
+		if entry.EndedAt != nil {
+			ended = entry.EndedAt.Local().Format(time.DateTime)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			entry.StartedAt.Local().Format(time.DateTime), ended, entry.City, entry.Country, entry.Reason)
+	}
+	return tw.Flush()
+}
+
+func (h *headless) bookmarks(args []string) error {
+	fs := flag.NewFlagSet("bookmarks", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	bookmarks, err := commands.LoadLocationBookmarks()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if bookmarks == nil {
+			bookmarks = []commands.LocationBookmark{}
+		}
+		return h.writeJSON(bookmarks)
+	}
+	tw := tabwriter.NewWriter(h.out, 0, 4, 2, ' ', 0)
+	for _, bookmark := range bookmarks {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", bookmark.ISO, bookmark.Country, bookmark.City)
+	}
+	return tw.Flush()
+}
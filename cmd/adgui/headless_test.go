@@ -0,0 +1,114 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"adgui/commands"
+	"adgui/control"
+)
+
+func runHeadlessReplay(t *testing.T, entries []commands.TranscriptEntry, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("ADGUARD_SUDO_WRAP", "0")
+
+	var out, errOut bytes.Buffer
+	code := runHeadless(context.Background(), args[0], args[1:], &out, &errOut, "", func() *commands.VPNManager {
+		return commands.New(commands.NewReplayRunner(entries))
+	})
+	return code, out.String(), errOut.String()
+}
+
+func TestHeadlessStatusJSON(t *testing.T) {
+	code, out, errOut := runHeadlessReplay(t, []commands.TranscriptEntry{
+		{Args: []string{"status"}, Output: "Connected to FRANKFURT in TUN mode, running on tun0\n"},
+		{Args: []string{"list-locations"}, Output: readReferenceList(t)},
+	}, "status", "--json")
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, errOut)
+	}
+
+	var report control.Status
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if report.State != "connected" || report.City != "Frankfurt" || report.ISO != "DE" || report.Interface != "tun0" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestHeadlessConnectByISO(t *testing.T) {
+	code, out, errOut := runHeadlessReplay(t, []commands.TranscriptEntry{
+		{Args: []string{"list-locations"}, Output: readReferenceList(t)},
+		{Args: []string{"connect", "-l", "Frankfurt"}, Output: "Successfully Connected to FRANKFURT in TUN mode, running on tun0\n"},
+	}, "connect", "de")
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, errOut)
+	}
+	if !strings.HasPrefix(out, "connected: Frankfurt (Germany)") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestHeadlessConnectFailure(t *testing.T) {
+	code, _, errOut := runHeadlessReplay(t, []commands.TranscriptEntry{
+		{Args: []string{"connect"}, Output: "Failed to connect: server did not respond\n", ExitCode: 1},
+	}, "connect")
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(errOut, "server did not respond") {
+		t.Fatalf("expected CLI reason in %q", errOut)
+	}
+}
+
+func TestHeadlessUsage(t *testing.T) {
+	code, _, errOut := runHeadlessReplay(t, nil, "locations", "--sort", "speed")
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(errOut, "Usage: adgui") {
+		t.Fatalf("expected usage in %q", errOut)
+	}
+	if isHeadlessCommand("-psn_0_1") {
+		t.Fatal("unknown arguments must start the GUI")
+	}
+}
+
+func TestHeadlessBookmarksJSON(t *testing.T) {
+	code, out, _ := runHeadlessReplay(t, nil, "bookmarks", "--json")
+	if code != 0 || strings.TrimSpace(out) != "[]" {
+		t.Fatalf("expected empty JSON list, got %d %q", code, out)
+	}
+}
+
+func readReferenceList(t *testing.T) string {
+	t.Helper()
+	list, err := os.ReadFile(filepath.Join("..", "..", "cli-reference-output", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(list)
+}
@@ -16,8 +16,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"adgui/commands"
 	"adgui/config"
+	"adgui/control"
+	"adgui/dbusvpn"
 	"adgui/ui"
 
 	"fyne.io/fyne/v2"
@@ -30,6 +38,19 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && isHeadlessCommand(os.Args[1]) {
+		out := os.Stdout
+		// Manager logs go to stderr so stdout carries only the command output.
+		os.Stdout = os.Stderr
+		// Ctrl+C stops a running CLI command together with its children.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := runHeadless(ctx, os.Args[1], os.Args[2:], out, os.Stderr, control.SocketPath(), func() *commands.VPNManager {
+			return commands.New(cliRunner())
+		})
+		stop()
+		os.Exit(code)
+	}
+
 	if err := ui.LoadTranslations(); err != nil {
 		fyne.LogError("failed to load translations", err)
 	}
@@ -58,13 +79,130 @@ func main() {
 				"config.adguirc.ADGUARD_SUDO_ASKPASS",
 				"Show GUI sudo password dialog. Values: true, false (also 1/0, yes/no, on/off).",
 			),
+			"ADGUARD_CLI_RECORD": lang.X(
+				"config.adguirc.ADGUARD_CLI_RECORD",
+				"Record every adguardvpn-cli call (args, output, exit code) to this JSON Lines file. Empty disables recording.",
+			),
+			"ADGUARD_CLI_RECORD_RAW": lang.X(
+				"config.adguirc.ADGUARD_CLI_RECORD_RAW",
+				"Keep e-mail addresses, license keys and IP addresses in the CLI transcript instead of redacting them. Values: true, false (also 1/0, yes/no, on/off).",
+			),
+			"ADGUARD_CLI_REPLAY": lang.X(
+				"config.adguirc.ADGUARD_CLI_REPLAY",
+				"Serve recorded adguardvpn-cli output from this transcript file or directory instead of running the CLI. Empty disables replay.",
+			),
+			"ADGUARD_RECONNECT": lang.X(
+				"config.adguirc.ADGUARD_RECONNECT",
+				"Reconnect automatically when the VPN drops without Disconnect. Values: true, false (also 1/0, yes/no, on/off).",
+			),
+			"ADGUARD_RECONNECT_ATTEMPTS": lang.X(
+				"config.adguirc.ADGUARD_RECONNECT_ATTEMPTS",
+				"Reconnect attempts to the last location before falling back to the best location.",
+			),
+			"ADGUARD_KILLSWITCH": lang.X(
+				"config.adguirc.ADGUARD_KILLSWITCH",
+				"Block traffic outside the VPN tunnel with nftables while the VPN should be up. Only Disconnect lifts the block. Values: true, false (also 1/0, yes/no, on/off).",
+			),
+			"ADGUARD_KILLSWITCH_LAN": lang.X(
+				"config.adguirc.ADGUARD_KILLSWITCH_LAN",
+				"Comma-separated CIDR ranges that stay reachable while the kill switch is on.",
+			),
+			"ADGUARD_KILLSWITCH_ENDPOINTS": lang.X(
+				"config.adguirc.ADGUARD_KILLSWITCH_ENDPOINTS",
+				"Comma-separated VPN server addresses, optionally with a port (203.0.113.7:443), the tunnel may reach outside it while the kill switch is on. Without them the CLI cannot reconnect until Disconnect lifts the block.",
+			),
+			"ADGUARD_TIMEOUT": lang.X(
+				"config.adguirc.ADGUARD_TIMEOUT",
+				"Time limit for adguardvpn-cli commands without their own setting, e.g. 30s or 2m. 0 disables the limit.",
+			),
+			"ADGUARD_TIMEOUT_CONNECT": lang.X(
+				"config.adguirc.ADGUARD_TIMEOUT_CONNECT",
+				"Time limit for adguardvpn-cli connect.",
+			),
+			"ADGUARD_TIMEOUT_DISCONNECT": lang.X(
+				"config.adguirc.ADGUARD_TIMEOUT_DISCONNECT",
+				"Time limit for adguardvpn-cli disconnect.",
+			),
+			"ADGUARD_TIMEOUT_STATUS": lang.X(
+				"config.adguirc.ADGUARD_TIMEOUT_STATUS",
+				"Time limit for adguardvpn-cli status.",
+			),
+			"ADGUARD_NETWORK_CHANGE": lang.X(
+				"config.adguirc.ADGUARD_NETWORK_CHANGE",
+				"What to do when the default route moves to another network while the VPN is up. Values: off, check (status and connectivity probe), reconnect (also reconnect to the same location when the probe fails).",
+			),
+			"ADGUARD_NETWORK_PROBE": lang.X(
+				"config.adguirc.ADGUARD_NETWORK_PROBE",
+				"host:port dialed over TCP to confirm that the tunnel passes traffic after a network change.",
+			),
+			"ADGUARD_RESUME_RECONNECT": lang.X(
+				"config.adguirc.ADGUARD_RESUME_RECONNECT",
+				"Reconnect to the same location right away when the VPN is found down after the computer wakes from sleep. Values: true, false (also 1/0, yes/no, on/off).",
+			),
+			"ADGUARD_FAILOVER": lang.X(
+				"config.adguirc.ADGUARD_FAILOVER",
+				"Comma-separated steps tried one by one after a failed connect to a location: bookmarks (next bookmark), country (fastest location in the same country), auto (best location); off disables failover. Overrides the choice in the tray menu.",
+			),
 		},
 	); err != nil {
 		fyne.LogError("failed to create config file", err)
 	}
 
-	appLogic := commands.New()
+	appLogic := commands.New(cliRunner())
+	server, err := control.Listen(control.SocketPath(), appLogic)
+	if err != nil {
+		fmt.Printf("control socket error: %v\n", err)
+	}
+	bus, err := dbusvpn.StartSession(appLogic)
+	if err != nil {
+		fmt.Printf("D-Bus service error: %v\n", err)
+	}
 	appUI := ui.New(appLogic, version)
 	_ = gitCommit
 	appUI.Run()
+	if bus != nil {
+		_ = bus.Close()
+	}
+	if server != nil {
+		_ = server.Close()
+	}
+}
+
+// cliRunner builds the adguardvpn-cli runner from ADGUARD_CLI_REPLAY,
+// ADGUARD_CLI_RECORD and ADGUARD_CLI_RECORD_RAW; by default the real CLI is executed.
+func cliRunner() commands.CLIRunner {
+	var runner commands.CLIRunner = commands.NewExecRunner()
+
+	replay, err := config.AdguardCLIReplay()
+	if err != nil {
+		fmt.Printf("config read error for CLI replay: %v\n", err)
+	}
+	if replay != "" {
+		var entries []commands.TranscriptEntry
+		if info, statErr := os.Stat(replay); statErr == nil && info.IsDir() {
+			entries, err = commands.LoadTranscriptDir(replay)
+		} else {
+			entries, err = commands.LoadTranscript(replay)
+		}
+		if err != nil {
+			fmt.Printf("load CLI replay transcript error: %v\n", err)
+		} else {
+			runner = commands.NewReplayRunner(entries)
+		}
+	}
+
+	record, err := config.AdguardCLIRecord()
+	if err != nil {
+		fmt.Printf("config read error for CLI record: %v\n", err)
+	}
+	if record != "" {
+		recorder := commands.NewRecordingRunner(runner, record)
+		raw, err := config.AdguardCLIRecordRaw()
+		if err != nil {
+			fmt.Printf("config read error for CLI record raw: %v\n", err)
+		}
+		recorder.SetRaw(raw)
+		runner = recorder
+	}
+	return runner
 }
@@ -0,0 +1,157 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"adgui/commands/sudowrap"
+)
+
+// ErrorKind classifies failed VPN operations so the UI can explain them.
+type ErrorKind string
+
+const (
+	ErrorNotLoggedIn         ErrorKind = "not_logged_in"
+	ErrorSubscriptionExpired ErrorKind = "subscription_expired"
+	ErrorDeviceLimit         ErrorKind = "device_limit"
+	ErrorLocationUnknown     ErrorKind = "location_unknown"
+	ErrorSudoDenied          ErrorKind = "sudo_denied"
+	ErrorCLIMissing          ErrorKind = "cli_missing"
+	ErrorTimeout             ErrorKind = "timeout"
+	// ErrorCanceled means the caller cancelled the operation; it needs no explanation.
+	ErrorCanceled ErrorKind = "canceled"
+	ErrorOther    ErrorKind = "other"
+)
+
+// CLIError is returned by ConnectAuto, ConnectToLocation, Disconnect and
+// ListLocations when adguardvpn-cli failed or could not be run.
+type CLIError struct {
+	Kind ErrorKind
+	// Op is the failed operation, e.g. "connect" or "list locations".
+	Op string
+	// ExitCode is the CLI exit status; -1 when the CLI did not run or reported none.
+	ExitCode int
+	// Message is the first line of CLI output, or the text of Err without output.
+	Message string
+	Err     error
+}
+
+func (e *CLIError) Error() string {
+	return "failed to " + e.Op + ": " + e.Message
+}
+
+func (e *CLIError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf returns the kind of a CLIError in err's chain, or ErrorOther.
+func ErrorKindOf(err error) ErrorKind {
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) {
+		return cliErr.Kind
+	}
+	return ErrorOther
+}
+
+// outputPatterns map lowercase fragments of adguardvpn-cli output to error kinds.
+// Subscription messages come first: they may also mention the login.
+var outputPatterns = []struct {
+	kind      ErrorKind
+	fragments []string
+}{
+	{ErrorSubscriptionExpired, []string{"subscription expired", "subscription has expired", "license expired", "license has expired", "traffic limit"}},
+	{ErrorDeviceLimit, []string{"device limit", "devices limit", "too many devices", "maximum number of devices"}},
+	{ErrorNotLoggedIn, []string{"not logged in", "login first", "log in first", "please log in", "authentication required"}},
+	{ErrorSudoDenied, []string{"sudo: a password is required", "incorrect password", "not in the sudoers", "permission denied", "operation not permitted"}},
+	{ErrorCLIMissing, []string{"command not found"}},
+	{ErrorLocationUnknown, []string{"not found", "unknown location", "no such location"}},
+	{ErrorTimeout, []string{"timed out", "timeout"}},
+}
+
+// ClassifyOutput maps CLI output to an error kind; ErrorOther when nothing matches.
+func ClassifyOutput(output string) ErrorKind {
+	lower := strings.ToLower(ansiStripRegex.ReplaceAllString(output, ""))
+	for _, pattern := range outputPatterns {
+		for _, fragment := range pattern.fragments {
+			if strings.Contains(lower, fragment) {
+				return pattern.kind
+			}
+		}
+	}
+	return ErrorOther
+}
+
+// newCLIError classifies a failed operation from the invocation error, the exit
+// code and the output. err may be nil when the CLI exited 0 without success.
+func newCLIError(op string, err error, output string) *CLIError {
+	cliErr := &CLIError{
+		Kind:     classifyError(err),
+		Op:       op,
+		ExitCode: ExitCode(err),
+		Message:  firstOutputLine(output),
+		Err:      err,
+	}
+	switch cliErr.Kind {
+	case ErrorOther:
+		cliErr.Kind = ClassifyOutput(output)
+	case ErrorTimeout, ErrorCanceled:
+		// Partial output of an interrupted command explains nothing.
+		cliErr.Message = err.Error()
+	}
+	if cliErr.Message == "" && err != nil {
+		cliErr.Message = err.Error()
+	}
+	if cliErr.Message == "" {
+		cliErr.Message = "no output"
+	}
+	return cliErr
+}
+
+// classifyError recognizes failures that happen outside the CLI output:
+// a missing executable, a deadline or cancellation, refused elevation and shell exit codes.
+func classifyError(err error) ErrorKind {
+	switch {
+	case err == nil:
+		return ErrorOther
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist), ExitCode(err) == 127:
+		return ErrorCLIMissing
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded), ExitCode(err) == 124:
+		return ErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, ErrSudoPasswordRequired), errors.Is(err, ErrSudoPasswordPrompt),
+		errors.Is(err, sudowrap.ErrInvalidPassword):
+		return ErrorSudoDenied
+	default:
+		return ErrorOther
+	}
+}
+
+// firstOutputLine returns the first non-blank line of output without ANSI codes.
+func firstOutputLine(output string) string {
+	for line := range strings.SplitSeq(ansiStripRegex.ReplaceAllString(output, ""), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
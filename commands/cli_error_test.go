@@ -0,0 +1,104 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CLI errors", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-cli-error-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+
+	DescribeTable("classifies CLI output",
+		func(output string, kind commands.ErrorKind) {
+			Expect(commands.ClassifyOutput(output)).To(Equal(kind))
+		},
+		Entry("login", "You are not logged in. Please run `adguardvpn-cli login` first\n", commands.ErrorNotLoggedIn),
+		Entry("subscription", "Your subscription has expired. Renew it to continue\n", commands.ErrorSubscriptionExpired),
+		Entry("devices", "Too many devices are connected to this account\n", commands.ErrorDeviceLimit),
+		Entry("location", "Location \x1b[1mATLANTIS\x1b[0m not found\n", commands.ErrorLocationUnknown),
+		Entry("sudo", "sudo: a password is required\n", commands.ErrorSudoDenied),
+		Entry("timeout", "Connection timed out\n", commands.ErrorTimeout),
+		Entry("other", "Failed to connect: server did not respond\n", commands.ErrorOther),
+	)
+
+	It("returns the classified failure from connect", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "\nDevice limit reached\n", ExitCode: 3},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		err := mgr.ConnectToLocation(context.Background(), riga)
+		Expect(err).To(MatchError("failed to connect: Device limit reached"))
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorDeviceLimit))
+		Expect(mgr.LastError()).To(HaveField("ExitCode", 3))
+		Expect(mgr.ConnectionState().Reason).To(Equal("Device limit reached"))
+	})
+
+	It("reports a missing CLI executable", func() {
+		oldCmd, hadCmd := os.LookupEnv("ADGUARD_CMD")
+		Expect(os.Setenv("ADGUARD_CMD", filepath.Join(tempHome, "adguardvpn-cli"))).To(Succeed())
+		defer func() {
+			if hadCmd {
+				_ = os.Setenv("ADGUARD_CMD", oldCmd)
+			} else {
+				_ = os.Unsetenv("ADGUARD_CMD")
+			}
+		}()
+		mgr := commands.New(commands.NewExecRunner())
+		defer func() { _ = mgr.Close() }()
+
+		_, err := mgr.ListLocations(context.Background())
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorCLIMissing))
+		Expect(commands.ErrorKindOf(mgr.Disconnect(context.Background()))).To(Equal(commands.ErrorCLIMissing))
+		Expect(mgr.LastError()).To(HaveField("ExitCode", -1))
+	})
+})
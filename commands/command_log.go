@@ -0,0 +1,266 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxCommandLogEntries = 300
+	// maxCommandLogOutput bounds the output kept per entry; list-locations is the longest.
+	maxCommandLogOutput = 16 * 1024
+	commandLogFile      = "command-log"
+)
+
+// CommandLogEntry records one finished CLI invocation.
+type CommandLogEntry struct {
+	Args      []string  `json:"args"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	ExitCode  int       `json:"exit_code"`
+	// Error describes a failure without an exit status, e.g. a timeout.
+	Error string `json:"error,omitempty"`
+	// Output is the combined output with personal data redacted.
+	Output string `json:"output"`
+}
+
+var (
+	redactEmailRegex = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// License keys and tokens: long runs of letters and digits containing both.
+	redactTokenRegex = regexp.MustCompile(`\b[A-Za-z0-9_-]{20,}\b`)
+	redactIPv4Regex  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// RedactOutput strips colors, e-mail addresses, license keys and IPv4 addresses
+// from CLI output and cuts it to maxCommandLogOutput bytes.
+func RedactOutput(output string) string {
+	output = redactPersonalData(ansiStripRegex.ReplaceAllString(output, ""))
+	if len(output) > maxCommandLogOutput {
+		output = strings.ToValidUTF8(output[:maxCommandLogOutput], "") + "\n…"
+	}
+	return output
+}
+
+// redactPersonalData replaces e-mail addresses, license keys and IPv4 addresses.
+func redactPersonalData(output string) string {
+	output = redactEmailRegex.ReplaceAllString(output, "<email>")
+	output = redactTokenRegex.ReplaceAllStringFunc(output, func(token string) string {
+		if strings.ContainsAny(token, "0123456789") && strings.ContainsAny(strings.ToLower(token), "abcdefghijklmnopqrstuvwxyz") {
+			return "<redacted>"
+		}
+		return token
+	})
+	return redactIPv4Regex.ReplaceAllString(output, "<ip>")
+}
+
+// GetCommandLogPath returns the absolute path to the command log file.
+func GetCommandLogPath() (string, error) {
+	dir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, commandLogFile), nil
+}
+
+// LoadCommandLog reads the command log from disk, newest entry first.
+// Returns an empty slice when the file does not exist.
+func LoadCommandLog() ([]CommandLogEntry, error) {
+	path, err := GetCommandLogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open command log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []CommandLogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*maxCommandLogOutput)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		var entry CommandLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read command log: %w", err)
+	}
+
+	// The file is append-only, oldest first.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if len(entries) > maxCommandLogEntries {
+		entries = entries[:maxCommandLogEntries]
+	}
+	return entries, nil
+}
+
+// SaveCommandLog replaces the command log on disk with entries, newest first.
+func SaveCommandLog(entries []CommandLogEntry) error {
+	if len(entries) > maxCommandLogEntries {
+		entries = entries[:maxCommandLogEntries]
+	}
+	var b strings.Builder
+	for i := len(entries) - 1; i >= 0; i-- {
+		data, err := json.Marshal(entries[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode command log entry: %w", err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	return writeCommandLog(os.O_CREATE|os.O_TRUNC|os.O_WRONLY, b.String())
+}
+
+func appendCommandLog(entry CommandLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode command log entry: %w", err)
+	}
+	return writeCommandLog(os.O_CREATE|os.O_APPEND|os.O_WRONLY, string(data)+"\n")
+}
+
+func writeCommandLog(flag int, data string) error {
+	path, err := GetCommandLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	file, err := os.OpenFile(path, flag, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open command log: %w", err)
+	}
+	if _, err := file.WriteString(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write command log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write command log: %w", err)
+	}
+	return nil
+}
+
+// CommandLog returns the finished CLI invocations, newest first.
+func (v *VPNManager) CommandLog() []CommandLogEntry {
+	v.cmdLogMx.Lock()
+	defer v.cmdLogMx.Unlock()
+	return append([]CommandLogEntry(nil), v.cmdLog...)
+}
+
+// logCommand records a finished invocation in memory and on disk. The file is
+// appended to and compacted once it holds twice the entries kept.
+func (v *VPNManager) logCommand(entry CommandLogEntry) {
+	v.cmdLogMx.Lock()
+	defer v.cmdLogMx.Unlock()
+	v.cmdLog = append([]CommandLogEntry{entry}, v.cmdLog...)
+	if len(v.cmdLog) > maxCommandLogEntries {
+		v.cmdLog = v.cmdLog[:maxCommandLogEntries]
+	}
+
+	var err error
+	v.cmdLogLines++
+	if v.cmdLogLines > 2*maxCommandLogEntries {
+		err = SaveCommandLog(v.cmdLog)
+		v.cmdLogLines = len(v.cmdLog)
+	} else {
+		err = appendCommandLog(entry)
+	}
+	if err != nil {
+		fmt.Printf("save command log error: %v\n", err)
+	}
+}
+
+// RerunCommand runs the command of a log entry again. Connects, disconnects,
+// exclusion changes and mode switches go through the same operations as from
+// the UI so the connection state and the saved lists follow; other mutating commands wait for their turn and
+// are followed by a status check.
+func (v *VPNManager) RerunCommand(ctx context.Context, entry CommandLogEntry) error {
+	args := entry.Args
+	switch {
+	case len(args) == 1 && args[0] == "connect":
+		return v.ConnectAuto(ctx)
+	case len(args) > 2 && args[0] == "connect" && args[1] == "-l":
+		return v.ConnectToLocation(ctx, v.resolveLocation(ctx, strings.Join(args[2:], " ")))
+	case len(args) == 1 && args[0] == "disconnect":
+		return v.Disconnect(ctx)
+	case len(args) == 3 && args[0] == "site-exclusions" && args[1] == "add":
+		return v.AddSiteExclusion(ctx, args[2])
+	case len(args) == 3 && args[0] == "site-exclusions" && args[1] == "remove":
+		return v.RemoveSiteExclusion(ctx, args[2])
+	case len(args) > 3 && args[0] == "site-exclusions" && (args[1] == "add" || args[1] == "remove"):
+		return v.rerunBatch(ctx, BatchAction(args[1]), args[2:])
+	case len(args) == 3 && args[0] == "site-exclusions" && args[1] == "mode" &&
+		(args[2] == string(SiteExclusionModeGeneral) || args[2] == string(SiteExclusionModeSelective)):
+		_, current, err := v.GetSiteExclusions(ctx)
+		if err != nil {
+			return err
+		}
+		return v.SetSiteExclusionsMode(ctx, SiteExclusionMode(args[2]), current)
+	}
+
+	if ClassifyCommand(args) == CommandMutating {
+		release, err := v.exclusive(ctx, "rerun "+strings.Join(args, " "), "")
+		if err != nil {
+			return err
+		}
+		defer release()
+		defer v.requestStatusCheck()
+	}
+	output, err := v.executeCommand(ctx, args...)
+	if err != nil {
+		return newCLIError("rerun "+strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// rerunBatch repeats a multi-domain exclusion change as a batch and fails
+// when any of its domains failed.
+func (v *VPNManager) rerunBatch(ctx context.Context, action BatchAction, domains []string) error {
+	report, err := v.BatchSiteExclusions(ctx, action, domains, BatchOptions{})
+	if err != nil {
+		return err
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		return fmt.Errorf("failed to %s %d of %d site exclusions: %w", action, len(failed), len(report.Results), failed[0].Err)
+	}
+	return nil
+}
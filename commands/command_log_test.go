@@ -0,0 +1,130 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Command log", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-command-log-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	It("redacts personal data from output", func() {
+		output := "Logged in as \x1b[1mjane.doe@example.com\x1b[0m\nLicense key: AB12CD34EF56GH78IJ90KL\nServer 203.0.113.7\nRiga  Frankfurt\n"
+		Expect(commands.RedactOutput(output)).To(Equal(
+			"Logged in as <email>\nLicense key: <redacted>\nServer <ip>\nRiga  Frankfurt\n"))
+	})
+
+	It("keeps finished commands across restarts", func() {
+		entries := []commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "example.com"}, Output: "added\n"},
+			{Args: []string{"license"}, Output: "Logged in as jane@example.com\n"},
+		}
+		mgr := commands.New(commands.NewReplayRunner(entries))
+		clock := &fakeClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+		mgr.SetClock(clock)
+		Expect(mgr.AddSiteExclusion(context.Background(), "example.com")).To(Succeed())
+		Expect(mgr.License(context.Background())).NotTo(BeEmpty())
+		Expect(mgr.Close()).To(Succeed())
+
+		check := func(log []commands.CommandLogEntry) {
+			Expect(log).To(HaveLen(2))
+			Expect(log[0].Args).To(Equal([]string{"license"}))
+			Expect(log[0].Output).To(Equal("Logged in as <email>\n"))
+			Expect(log[1].Args).To(Equal([]string{"site-exclusions", "add", "example.com"}))
+			Expect(log[1].ExitCode).To(Equal(0))
+			Expect(log[1].StartedAt).To(BeTemporally("==", clock.now))
+		}
+		check(mgr.CommandLog())
+
+		restarted := commands.New(commands.NewReplayRunner(entries))
+		defer func() { _ = restarted.Close() }()
+		check(restarted.CommandLog())
+	})
+
+	It("reruns a logged command through the manager", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "remove", "example.org"}, Output: "not found\n", ExitCode: 3},
+			{Args: []string{"site-exclusions", "add", "example.com"}, Output: "added\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		Expect(mgr.AddSiteExclusion(context.Background(), "example.com")).To(Succeed())
+		Expect(mgr.RemoveSiteExclusion(context.Background(), "example.org")).NotTo(Succeed())
+		log := mgr.CommandLog()
+		Expect(log[0].ExitCode).To(Equal(3))
+
+		Expect(mgr.RerunCommand(context.Background(), log[1])).To(Succeed())
+		Expect(mgr.RerunCommand(context.Background(), log[0])).NotTo(Succeed())
+		Expect(mgr.CommandLog()).To(HaveLen(4))
+		Expect(drainEvents[commands.ExclusionsChanged](events)).To(HaveLen(2))
+	})
+
+	It("reruns batches and mode switches through the exclusion operations", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.example", "b.example"}, Output: "added to exclusions\n"},
+			{Args: []string{"site-exclusions", "remove", "a.example", "c.example"}, Output: "Failed to remove\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\na.example\nb.example\n"},
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		rerun := func(args ...string) error {
+			return mgr.RerunCommand(context.Background(), commands.CommandLogEntry{Args: args})
+		}
+		Expect(rerun("site-exclusions", "add", "a.example", "b.example")).To(Succeed())
+		Expect(rerun("site-exclusions", "remove", "a.example", "c.example")).NotTo(Succeed())
+		Expect(rerun("site-exclusions", "mode", "selective")).To(Succeed())
+		Expect(mgr.SiteExclusionsMode()).To(Equal(commands.SiteExclusionModeSelective))
+		general, err := commands.LoadExclusionsForMode(commands.SiteExclusionModeGeneral)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(general).To(Equal([]string{"a.example", "b.example"}))
+	})
+})
@@ -16,10 +16,9 @@
 package commands
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 	"regexp"
 	"strings"
@@ -27,6 +26,9 @@ import (
 	"syscall"
 	"time"
 
+	"adgui/commands/killswitch"
+	"adgui/commands/netwatch"
+	"adgui/commands/sleepwatch"
 	"adgui/commands/sudowrap"
 	"adgui/config"
 	"adgui/locations"
@@ -34,6 +36,19 @@ import (
 
 const startDelay = 3 * time.Second // Начальная задержка
 
+const (
+	// statusPollInterval is used when network changes cannot be watched.
+	statusPollInterval = 60 * time.Second
+	// statusPollIntervalWatched is used while netlink reports tunnel changes.
+	statusPollIntervalWatched = 10 * time.Minute
+	// networkSettleDelay lets a burst of link, address and route changes pass
+	// before the status check.
+	networkSettleDelay = 500 * time.Millisecond
+)
+
+// maxCommandOutputLines bounds the output kept per running command.
+const maxCommandOutputLines = 100
+
 var (
 	// ErrSudoPasswordRequired is returned when sudo auth was cancelled or empty.
 	ErrSudoPasswordRequired = errors.New("sudo password required")
@@ -83,52 +98,105 @@ type RunningCommand struct {
 	Path      string
 	Args      []string
 	StartedAt time.Time
+	// Output holds the latest output lines, at most maxCommandOutputLines.
+	Output []string
 }
 
 type VPNManager struct {
-	statusTicker   *time.Ticker
-	onStatusChange func()
-	checkReqs      chan struct{}
+	statusTicker *time.Ticker
+	checkReqs    chan struct{}
+	netReqs      chan struct{}
+	uplinkReqs   chan struct{}
+	events       *eventBus
+	sched        *scheduler
+	loopCtx      context.Context
+	stopLoop     context.CancelFunc
 
 	// all below protected by statemx
 	statemx            sync.Mutex
 	status             string
+	statusDetails      Status
+	dismissedWarnings  map[string]bool
+	reconnect          ReconnectPolicy
+	networkChange      NetworkChangePolicy
+	uplinkBusy         bool
+	uplinkNext         *uplinkMove
+	resumeBusy         bool
+	resumeNext         time.Time
+	resumeReconnect    bool
+	failover           []FailoverStep
+	watchdogStop       chan struct{}
+	killSwitch         *killswitch.KillSwitch
+	killSwitchOn       bool
+	killSwitchStale    bool
 	location           string
 	connectedLocation  locations.Location
-	isConnected        bool
+	state              *StateMachine
 	siteExclusionsMode SiteExclusionMode
 	lastStatusLog      string
+	lastError          *CLIError
+	timeouts           CommandTimeouts
 
 	// connection history (historyMx)
 	historyMx          sync.Mutex
 	history            []ConnectionHistoryEntry
 	activeConnection   *ConnectionHistoryEntry
+	suspendedAt        time.Time
 	locationsCache     []locations.Location
 	locationsCacheTime time.Time
 
 	// command queue tracking
-	queueMx       sync.Mutex
-	runningCmds   map[uint64]*exec.Cmd
-	cmdInfos      map[uint64]RunningCommand
-	nextCmdID     uint64
-	onQueueChange func()
-
-	sudoEnv       *sudowrap.Env
+	queueMx     sync.Mutex
+	runningCmds map[uint64]CLIProcess
+	cmdInfos    map[uint64]RunningCommand
+	cmdOutput   map[uint64][]string
+	nextCmdID   uint64
+
+	// finished commands, newest first (cmdLogMx)
+	cmdLogMx    sync.Mutex
+	cmdLog      []CommandLogEntry
+	cmdLogLines int
+
+	runner         CLIRunner
+	sudoEnv        *sudowrap.Env
 	passwordPrompt PasswordPrompt
 	promptMx       sync.Mutex
 }
 
-func New() *VPNManager {
+// New creates a VPNManager that invokes adguardvpn-cli through runner.
+// A nil runner falls back to ExecRunner.
+func New(runner CLIRunner) *VPNManager {
+	if runner == nil {
+		runner = NewExecRunner()
+	}
 	mgr := VPNManager{
-		checkReqs:   make(chan struct{}, 1),
-		runningCmds: make(map[uint64]*exec.Cmd),
-		cmdInfos:    make(map[uint64]RunningCommand),
+		checkReqs:         make(chan struct{}, 1),
+		netReqs:           make(chan struct{}, 1),
+		uplinkReqs:        make(chan struct{}, 1),
+		events:            newEventBus(),
+		sched:             newScheduler(),
+		runningCmds:       make(map[uint64]CLIProcess),
+		cmdInfos:          make(map[uint64]RunningCommand),
+		cmdOutput:         make(map[uint64][]string),
+		runner:            runner,
+		state:             NewStateMachine(nil),
+		dismissedWarnings: make(map[string]bool),
+		reconnect:         DefaultReconnectPolicy(),
+		networkChange:     DefaultNetworkChangePolicy(),
+		failover:          DefaultFailoverSteps(),
+		timeouts:          DefaultCommandTimeouts(),
 	}
 	if history, err := LoadConnectionHistory(); err != nil {
 		fmt.Printf("load connections history error: %v\n", err)
 	} else {
 		mgr.history = history
 	}
+	if cmdLog, err := LoadCommandLog(); err != nil {
+		fmt.Printf("load command log error: %v\n", err)
+	} else {
+		mgr.cmdLog = cmdLog
+		mgr.cmdLogLines = len(cmdLog)
+	}
 
 	enabled, err := config.AdguardSudoWrapEnabled()
 	if err != nil {
@@ -140,13 +208,35 @@ func New() *VPNManager {
 		fmt.Printf("config read error for sudo askpass: %v\n", err)
 		askpass = true
 	}
+	if mgr.reconnect.Enabled, err = config.AdguardReconnectEnabled(); err != nil {
+		fmt.Printf("config read error for reconnect: %v\n", err)
+	}
+	if attempts, err := config.AdguardReconnectAttempts(); err != nil {
+		fmt.Printf("config read error for reconnect attempts: %v\n", err)
+	} else {
+		mgr.reconnect.LocationAttempts = attempts
+	}
+	if mgr.timeouts, err = loadCommandTimeouts(); err != nil {
+		fmt.Printf("config read error for command timeouts: %v\n", err)
+	}
+	if mgr.networkChange, err = loadNetworkChangePolicy(); err != nil {
+		fmt.Printf("config read error for network change: %v\n", err)
+	}
+	if mgr.resumeReconnect, err = config.AdguardResumeReconnect(); err != nil {
+		fmt.Printf("config read error for resume reconnect: %v\n", err)
+	}
+	if mgr.failover, err = loadFailoverSteps(); err != nil {
+		fmt.Printf("config read error for failover: %v\n", err)
+	}
 	sudoEnv, err := sudowrap.Setup(enabled, askpass)
 	if err != nil {
 		fmt.Printf("sudo wrap setup error: %v\n", err)
 	} else {
 		mgr.sudoEnv = sudoEnv
 	}
+	mgr.setupKillSwitch()
 
+	mgr.loopCtx, mgr.stopLoop = context.WithCancel(context.Background())
 	go mgr.statusCheckLoop()
 	return &mgr
 }
@@ -198,8 +288,12 @@ func (v *VPNManager) EnsureSudoPassword() error {
 	return nil
 }
 
-// Close wipes sudo session secrets and removes the private wrapper directory.
+// Close stops status checks and the reconnect watchdog, wipes sudo session
+// secrets and removes the private wrapper directory. Kill switch rules stay
+// with the tunnel and are recovered on the next start.
 func (v *VPNManager) Close() error {
+	v.stopLoop()
+	v.stopWatchdog()
 	if v.sudoEnv == nil {
 		return nil
 	}
@@ -221,7 +315,7 @@ func (v *VPNManager) Location() string {
 func (v *VPNManager) ConnectedLocation() (locations.Location, bool) {
 	v.statemx.Lock()
 	defer v.statemx.Unlock()
-	if !v.isConnected {
+	if v.state.Current().State != StateConnected {
 		return locations.Location{}, false
 	}
 	return v.connectedLocation, true
@@ -261,10 +355,127 @@ func (v *VPNManager) Status() string {
 	return v.status
 }
 
+// StatusDetails returns the last parsed status output, including tunnel mode and warnings.
+func (v *VPNManager) StatusDetails() Status {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	st := v.statusDetails
+	st.Warnings = append([]string(nil), st.Warnings...)
+	return st
+}
+
 func (v *VPNManager) IsConnected() bool {
 	v.statemx.Lock()
 	defer v.statemx.Unlock()
-	return v.isConnected
+	return v.state.Current().State == StateConnected
+}
+
+// ConnectionState returns the current connection state with its error reason.
+func (v *VPNManager) ConnectionState() StateInfo {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.state.Current()
+}
+
+// SetClock replaces the clock used for state changes, command start times and
+// connection history. A nil clock restores wall time.
+func (v *VPNManager) SetClock(clock Clock) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.state.SetClock(clock)
+}
+
+func (v *VPNManager) now() time.Time {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.state.Now()
+}
+
+// transition moves the state machine and publishes StatusChanged.
+func (v *VPNManager) transition(to ConnectionState, reason string) (StateInfo, error) {
+	v.statemx.Lock()
+	prev, err := v.state.Transition(to, reason)
+	loc := v.connectedLocation
+	v.statemx.Unlock()
+	if err != nil {
+		return prev, err
+	}
+	v.publishStatus(prev.State, to, reason, loc)
+	return prev, nil
+}
+
+func (v *VPNManager) publishStatus(from, to ConnectionState, reason string, loc locations.Location) {
+	v.events.publish(StatusChanged{From: from, To: to, Reason: reason, Location: loc})
+}
+
+// restoreState returns to the state saved before an operation that did not reach the CLI.
+func (v *VPNManager) restoreState(prev StateInfo) {
+	if _, err := v.transition(prev.State, prev.Reason); err != nil {
+		fmt.Printf("restore connection state error: %v\n", err)
+	}
+}
+
+// failState records a failed operation as StateError with the first line of CLI output.
+// A cancelled operation returns to prev instead and leaves the outcome to the next status check.
+func (v *VPNManager) failState(prev StateInfo, err *CLIError) {
+	if err.Kind == ErrorCanceled {
+		v.restoreState(prev)
+		v.requestStatusCheck()
+		return
+	}
+	v.statemx.Lock()
+	v.lastError = err
+	v.statemx.Unlock()
+	if _, terr := v.transition(StateError, err.Message); terr != nil {
+		fmt.Printf("connection state error: %v\n", terr)
+	}
+}
+
+// LastError returns the failure behind the latest StateError, or nil.
+func (v *VPNManager) LastError() *CLIError {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.lastError
+}
+
+// beginConnect enters Connecting, or Reconnecting when a tunnel is already up.
+// It fails with ErrInvalidTransition while another operation owns the state.
+func (v *VPNManager) beginConnect() (StateInfo, error) {
+	target := StateConnecting
+	if v.IsConnected() {
+		target = StateReconnecting
+	}
+	return v.transition(target, "")
+}
+
+// storeStatusDetails keeps mode and warnings reported by connect output
+// until the next status check replaces them.
+func (v *VPNManager) storeStatusDetails(output string) {
+	details, err := ParseStatus(output)
+	if err != nil {
+		return
+	}
+	v.setStatusDetails(details)
+}
+
+// exclusive waits for the turn of a mutating operation named op; key is
+// connectKey for connects, which a newer connect replaces while they wait.
+// The returned function ends the operation.
+func (v *VPNManager) exclusive(ctx context.Context, op, key string) (func(), error) {
+	if err := v.sched.acquire(ctx, key); err != nil {
+		if errors.Is(err, ErrSuperseded) {
+			return nil, fmt.Errorf("failed to %s: %w", op, err)
+		}
+		return nil, newCLIError(op, err, "")
+	}
+	return v.sched.release, nil
+}
+
+func (v *VPNManager) requestStatusCheck() {
+	select {
+	case v.checkReqs <- struct{}{}:
+	default:
+	}
 }
 
 func (v *VPNManager) SiteExclusionsMode() SiteExclusionMode {
@@ -276,31 +487,57 @@ func (v *VPNManager) SiteExclusionsMode() SiteExclusionMode {
 	return v.siteExclusionsMode
 }
 
-func (v *VPNManager) SetStatusChangeCallback(callback func()) {
-	v.statemx.Lock()
-	defer v.statemx.Unlock()
-	v.onStatusChange = callback
+// executeCommand runs the CLI within the per-command timeout. When ctx ends
+// first, the process group is terminated and the context cause is returned.
+// Concurrent identical read-only commands share one process.
+func (v *VPNManager) executeCommand(ctx context.Context, args ...string) (string, error) {
+	if ClassifyCommand(args) == CommandReadOnly {
+		output, _, err := v.executeShared(ctx, args)
+		return output, err
+	}
+	return v.runCommand(ctx, args, nil)
 }
 
-func (v *VPNManager) executeCommand(args ...string) (string, error) {
-	cmdPath := resolveCommandPath()
-	cmd := exec.Command(cmdPath, args...)
-	v.applySudoWrap(cmd)
-	prepareCLICommand(cmd)
-
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
+// executeShared joins or starts the shared run of a read-only command and also
+// returns the mutating operation epoch seen when the command started.
+func (v *VPNManager) executeShared(ctx context.Context, args []string) (string, uint64, error) {
+	return v.sched.share(ctx, args, func(ctx context.Context) (string, error) {
+		return v.runCommand(ctx, args, nil)
+	})
+}
 
-	if err := cmd.Start(); err != nil {
+// runCommand starts one invocation. Output lines go to the command queue, to
+// CommandOutput events and, when set, to onLine.
+func (v *VPNManager) runCommand(ctx context.Context, args []string, onLine func(line string)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", context.Cause(ctx)
+	}
+	ctx, cancel := v.withCommandTimeout(ctx, args)
+	defer cancel()
+
+	id := v.reserveCommand()
+	proc, err := v.runner.Start(CLIRequest{
+		Args: args,
+		Env:  v.childEnv(),
+		OnOutput: func(line string) {
+			line = ansiStripRegex.ReplaceAllString(line, "")
+			v.appendCommandOutput(id, args, line)
+			if onLine != nil {
+				onLine(line)
+			}
+		},
+	})
+	if err != nil {
+		v.queueMx.Lock()
+		delete(v.cmdOutput, id)
+		v.queueMx.Unlock()
 		return "", err
 	}
 
-	_, done := v.registerCommand(cmdPath, args, cmd)
-	defer done()
-
-	err := cmd.Wait()
-	return buf.String(), err
+	done := v.registerCommand(id, args, proc)
+	output, err := waitContext(ctx, proc)
+	done(output, err)
+	return output, err
 }
 
 // prepareCLICommand detaches the child from the parent's controlling terminal so
@@ -313,41 +550,80 @@ func prepareCLICommand(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 }
 
-func (v *VPNManager) registerCommand(path string, args []string, cmd *exec.Cmd) (uint64, func()) {
+// reserveCommand allocates the ID of an invocation about to start,
+// so its output is collected from the first line.
+func (v *VPNManager) reserveCommand() uint64 {
 	v.queueMx.Lock()
+	defer v.queueMx.Unlock()
 	v.nextCmdID++
-	id := v.nextCmdID
+	v.cmdOutput[v.nextCmdID] = nil
+	return v.nextCmdID
+}
 
-	pid := 0
-	if cmd.Process != nil {
-		pid = cmd.Process.Pid
+// appendCommandOutput keeps line for a queued invocation. Lines of mutating
+// commands are also published as CommandOutput; read-only commands such as
+// list-locations print too much to flood subscribers with. Lines written after
+// the invocation left the queue are dropped.
+func (v *VPNManager) appendCommandOutput(id uint64, args []string, line string) {
+	v.queueMx.Lock()
+	lines, ok := v.cmdOutput[id]
+	if ok {
+		lines = append(lines, line)
+		if len(lines) > maxCommandOutputLines {
+			lines = lines[len(lines)-maxCommandOutputLines:]
+		}
+		v.cmdOutput[id] = lines
 	}
+	v.queueMx.Unlock()
+	if ok && ClassifyCommand(args) == CommandMutating {
+		v.events.publish(CommandOutput{ID: id, Line: line})
+	}
+}
 
-	v.runningCmds[id] = cmd
+// registerCommand adds proc to the command queue under a reserved id and publishes
+// CommandStarted. The returned function removes it, records it in the command log
+// and publishes CommandFinished with the Wait error.
+func (v *VPNManager) registerCommand(id uint64, args []string, proc CLIProcess) func(string, error) {
+	startedAt := v.now()
+	v.queueMx.Lock()
+	v.runningCmds[id] = proc
 	v.cmdInfos[id] = RunningCommand{
 		ID:        id,
-		PID:       pid,
-		Path:      path,
+		PID:       proc.PID(),
+		Path:      proc.Path(),
 		Args:      args,
-		StartedAt: time.Now(),
+		StartedAt: startedAt,
 	}
-	callback := v.onQueueChange
 	v.queueMx.Unlock()
 
-	if callback != nil {
-		callback()
-	}
+	v.events.publish(CommandStarted{ID: id, Args: args, StartedAt: startedAt})
 
-	return id, func() {
+	return func(output string, err error) {
 		v.queueMx.Lock()
 		delete(v.runningCmds, id)
 		delete(v.cmdInfos, id)
-		callback := v.onQueueChange
+		delete(v.cmdOutput, id)
 		v.queueMx.Unlock()
 
-		if callback != nil {
-			callback()
+		endedAt := v.now()
+		entry := CommandLogEntry{
+			Args:      args,
+			StartedAt: startedAt,
+			EndedAt:   endedAt,
+			ExitCode:  ExitCode(err),
+			Output:    RedactOutput(output),
+		}
+		if err != nil && entry.ExitCode == -1 {
+			entry.Error = err.Error()
 		}
+		v.logCommand(entry)
+
+		v.events.publish(CommandFinished{
+			ID:       id,
+			Args:     args,
+			ExitCode: ExitCode(err),
+			Duration: endedAt.Sub(startedAt),
+		})
 	}
 }
 
@@ -359,31 +635,25 @@ func (v *VPNManager) RunningCommands() []RunningCommand {
 
 	cmds := make([]RunningCommand, 0, len(v.cmdInfos))
 	for _, info := range v.cmdInfos {
+		info.Output = append([]string(nil), v.cmdOutput[info.ID]...)
 		cmds = append(cmds, info)
 	}
 	return cmds
 }
 
-// SetCommandQueueChangeCallback sets a function to be called when the command queue changes.
-func (v *VPNManager) SetCommandQueueChangeCallback(callback func()) {
-	v.queueMx.Lock()
-	defer v.queueMx.Unlock()
-	v.onQueueChange = callback
-}
-
 // KillCommand attempts to terminate a specific running command by its ID.
-// It sends SIGTERM first, and falls back to SIGKILL if the process does not terminate.
+// It sends SIGTERM to the process group first, and falls back to SIGKILL if the process does not terminate.
 func (v *VPNManager) KillCommand(id uint64) error {
 	v.queueMx.Lock()
-	cmd, ok := v.runningCmds[id]
+	proc, ok := v.runningCmds[id]
 	info, hasInfo := v.cmdInfos[id]
 	v.queueMx.Unlock()
 
-	if !ok || cmd == nil || cmd.Process == nil {
+	if !ok || proc == nil {
 		return fmt.Errorf("command not found or not running")
 	}
 
-	pid := cmd.Process.Pid
+	pid := proc.PID()
 	if hasInfo && info.PID != 0 {
 		pid = info.PID
 	}
@@ -393,7 +663,7 @@ func (v *VPNManager) KillCommand(id uint64) error {
 		fmt.Printf("Config read error for kill cmd: %v\n", err)
 	}
 
-	if killCmdStr != "" {
+	if killCmdStr != "" && pid != 0 {
 		fields := strings.Fields(killCmdStr)
 		if len(fields) > 0 {
 			fields = append(fields, fmt.Sprintf("%d", pid))
@@ -406,17 +676,17 @@ func (v *VPNManager) KillCommand(id uint64) error {
 		}
 	}
 
-	err = cmd.Process.Signal(syscall.SIGTERM)
+	err = signalGroup(proc, syscall.SIGTERM)
 	if err != nil {
-		return cmd.Process.Kill()
+		return signalGroup(proc, syscall.SIGKILL)
 	}
 
-	go func(p *os.Process) {
-		time.Sleep(500 * time.Millisecond)
+	go func(p CLIProcess) {
+		time.Sleep(killGrace)
 		if err := p.Signal(syscall.Signal(0)); err == nil {
-			_ = p.Kill()
+			_ = signalGroup(p, syscall.SIGKILL)
 		}
-	}(cmd.Process)
+	}(proc)
 
 	return nil
 }
@@ -444,91 +714,182 @@ func resolveCommandPath() string {
 	return cmdPath
 }
 
-func (v *VPNManager) applySudoWrap(cmd *exec.Cmd) {
-	if v.sudoEnv != nil {
-		v.sudoEnv.Apply(cmd)
+// childEnv returns the environment for CLI children; nil keeps the parent environment.
+func (v *VPNManager) childEnv() []string {
+	if v.sudoEnv == nil {
+		return nil
 	}
+	return v.sudoEnv.ChildEnv()
 }
 
-func (v *VPNManager) ConnectAuto() {
-	if err := v.EnsureSudoPassword(); err != nil {
-		fmt.Printf("sudo auth error: %v\n", err)
-		return
+// ConnectAuto connects to the location picked by adguardvpn-cli. It waits for
+// running mutating operations first; a newer connect arriving meanwhile makes it
+// return ErrSuperseded. A failure is returned as *CLIError unless the state
+// forbids connecting, which yields ErrInvalidTransition.
+func (v *VPNManager) ConnectAuto(ctx context.Context) error {
+	v.stopWatchdog()
+	return v.connectAuto(ctx)
+}
+
+func (v *VPNManager) connectAuto(ctx context.Context) error {
+	release, err := v.exclusive(ctx, "connect", connectKey)
+	if err != nil {
+		return err
 	}
-	// Получаем список локаций
-	output, err := v.executeCommand("connect")
+	defer release()
+
+	prev, err := v.beginConnect()
 	if err != nil {
-		fmt.Printf("Could not connect: %s: %s\n", err, output)
-		return
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	progress := v.newConnectProgress(locations.Location{})
+	progress.advance(StageResolvingLocation)
+	progress.advance(StageRequestingElevation)
+	if err := v.EnsureSudoPassword(); err != nil {
+		v.restoreState(prev)
+		return newCLIError("connect", err, "")
 	}
-	v.checkReqs <- struct{}{}
+	progress.advance(StageEstablishingTunnel)
+	output, err := v.runCommand(ctx, []string{"connect"}, progress.observe)
+	if err != nil {
+		cliErr := newCLIError("connect", err, output)
+		v.failState(prev, cliErr)
+		return cliErr
+	}
+	if !strings.Contains(output, statusConnectedTo) {
+		v.restoreState(prev)
+		v.requestStatusCheck()
+		return newCLIError("connect", nil, output)
+	}
+	v.storeStatusDetails(output)
+	v.applyConnected(v.resolveLocation(ctx, ParseLocationFromStatus(output)))
+	v.requestStatusCheck()
+	return nil
 }
 
-func (v *VPNManager) ListLocations() []locations.Location {
-	// Получаем список локаций
-	output, err := v.executeCommand("list-locations")
+// ListLocations returns the locations reported by adguardvpn-cli.
+// A failure or an empty list is returned as *CLIError.
+func (v *VPNManager) ListLocations(ctx context.Context) ([]locations.Location, error) {
+	output, err := v.executeCommand(ctx, "list-locations")
 	if err != nil {
-		fmt.Printf("List locations error: %v\nOutput: %s\n", err, output)
-		return nil
+		return nil, newCLIError("list locations", err, output)
 	}
 
 	// Парсим список локаций
 	actualLocations := locations.ParseLocations(output)
 	if len(actualLocations) == 0 {
-		fmt.Println("No locations found")
-		return nil
+		cliErr := newCLIError("list locations", nil, output)
+		if cliErr.Kind == ErrorOther {
+			cliErr.Message = "no locations found"
+		}
+		return nil, cliErr
 	}
-	return actualLocations
+	return actualLocations, nil
+}
+
+// ConnectToLocation connects to loc; errors are as for ConnectAuto.
+func (v *VPNManager) ConnectToLocation(ctx context.Context, loc locations.Location) error {
+	v.stopWatchdog()
+	return v.connectToLocation(ctx, loc)
 }
 
-func (v *VPNManager) ConnectToLocation(loc locations.Location) {
+func (v *VPNManager) connectToLocation(ctx context.Context, loc locations.Location) error {
+	release, err := v.exclusive(ctx, "connect", connectKey)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	prev, err := v.beginConnect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", loc.City, err)
+	}
+	progress := v.newConnectProgress(loc)
+	progress.advance(StageResolvingLocation)
+	progress.advance(StageRequestingElevation)
 	if err := v.EnsureSudoPassword(); err != nil {
-		fmt.Printf("sudo auth error: %v\n", err)
-		return
+		v.restoreState(prev)
+		return newCLIError("connect", err, "")
 	}
-	output, err := v.executeCommand("connect", "-l", loc.City)
+	progress.advance(StageEstablishingTunnel)
+	output, err := v.runCommand(ctx, []string{"connect", "-l", loc.City}, progress.observe)
 	if err != nil {
-		fmt.Printf("Connect to location error: %v\nOutput: %s\n", err, output)
-		return
+		cliErr := newCLIError("connect", err, output)
+		v.failState(prev, cliErr)
+		return cliErr
 	}
 
 	if strings.Contains(output, statusConnectedTo) {
+		v.storeStatusDetails(output)
 		v.applyConnected(loc)
+		return nil
 	}
+	v.restoreState(prev)
+	v.requestStatusCheck()
+	return newCLIError("connect", nil, output)
 }
 
+// applyConnected moves to StateConnected. The tunnel counts as previously up while
+// a connected location is known, so a reconnect to another city splits the history.
 func (v *VPNManager) applyConnected(loc locations.Location) {
 	v.statemx.Lock()
-	wasConnected := v.isConnected
 	prevLoc := v.connectedLocation
-	v.isConnected = true
+	wasConnected := prevLoc != (locations.Location{})
+	prev, err := v.state.Transition(StateConnected, "")
+	if err != nil {
+		v.statemx.Unlock()
+		fmt.Printf("apply connected state error: %v\n", err)
+		return
+	}
 	v.location = loc.City
 	v.connectedLocation = loc
-	callback := v.onStatusChange
 	v.statemx.Unlock()
 
 	v.updateConnectionHistory(wasConnected, prevLoc, loc)
 
-	if callback != nil {
-		callback()
+	// A status check confirming the same tunnel is not a change.
+	if prev.State != StateConnected || !locationsEqual(prevLoc, loc) {
+		v.publishStatus(prev.State, StateConnected, "", loc)
 	}
+	v.engageKillSwitch()
 }
 
+// applyDisconnected moves to StateDisconnected. Coming straight from Connected means
+// the status check saw the tunnel drop without Disconnect, which starts the watchdog.
+// The kill switch keeps blocking traffic then; only recovered rules are removed.
 func (v *VPNManager) applyDisconnected() {
+	v.settleStaleKillSwitch()
+
 	v.statemx.Lock()
-	wasConnected := v.isConnected
-	v.isConnected = false
+	prevLoc := v.connectedLocation
+	wasConnected := prevLoc != (locations.Location{})
+	prev, err := v.state.Transition(StateDisconnected, "")
+	if err != nil {
+		v.statemx.Unlock()
+		fmt.Printf("apply disconnected state error: %v\n", err)
+		return
+	}
 	v.location = ""
 	v.connectedLocation = locations.Location{}
-	callback := v.onStatusChange
 	v.statemx.Unlock()
 
+	unexpected := prev.State == StateConnected
+	if unexpected {
+		if v.resuming() {
+			v.annotateActiveConnection("disconnected during sleep")
+		} else {
+			v.annotateActiveConnection("unexpected disconnect")
+		}
+	}
 	if wasConnected {
 		v.finalizeActiveConnection()
 	}
 
-	if callback != nil {
-		callback()
+	if prev.State != StateDisconnected {
+		v.publishStatus(prev.State, StateDisconnected, "", locations.Location{})
+	}
+	if unexpected {
+		v.startWatchdog(prevLoc)
 	}
 }
 
@@ -551,7 +912,7 @@ func (v *VPNManager) updateConnectionHistory(wasConnected bool, prevLoc, newLoc
 }
 
 func (v *VPNManager) startActiveConnectionLocked(loc locations.Location) {
-	now := time.Now()
+	now := v.now()
 	entry := ConnectionHistoryEntry{
 		City:      loc.City,
 		Country:   loc.Country,
@@ -571,16 +932,27 @@ func (v *VPNManager) finalizeActiveConnectionLocked() {
 	if v.activeConnection == nil {
 		return
 	}
-	now := time.Now()
+	now := v.now()
+	// A tunnel found down after a resume went down with the suspend.
+	if sleptAt, ok := v.sleepStartLocked(); ok && sleptAt.After(v.activeConnection.StartedAt) && sleptAt.Before(now) {
+		now = sleptAt
+	}
 	v.activeConnection.EndedAt = &now
-	v.history = prependHistoryEntry(v.history, *v.activeConnection)
+	entry := *v.activeConnection
+	v.activeConnection = nil
+	v.prependHistoryLocked(entry)
+}
+
+// prependHistoryLocked stores a finished entry and publishes HistoryAppended.
+func (v *VPNManager) prependHistoryLocked(entry ConnectionHistoryEntry) {
+	v.history = prependHistoryEntry(v.history, entry)
 	if len(v.history) > maxHistoryEntries {
 		v.history = v.history[:maxHistoryEntries]
 	}
-	v.activeConnection = nil
 	if err := SaveConnectionHistory(v.history); err != nil {
 		fmt.Printf("save connections history error: %v\n", err)
 	}
+	v.events.publish(HistoryAppended{Entry: entry})
 }
 
 func prependHistoryEntry(entries []ConnectionHistoryEntry, entry ConnectionHistoryEntry) []ConnectionHistoryEntry {
@@ -590,7 +962,7 @@ func prependHistoryEntry(entries []ConnectionHistoryEntry, entry ConnectionHisto
 	return result
 }
 
-func (v *VPNManager) resolveLocation(cityName string) locations.Location {
+func (v *VPNManager) resolveLocation(ctx context.Context, cityName string) locations.Location {
 	if cityName == "" {
 		return locations.Location{}
 	}
@@ -601,7 +973,11 @@ func (v *VPNManager) resolveLocation(cityName string) locations.Location {
 	v.statemx.Unlock()
 
 	if len(cached) == 0 || time.Since(cacheTime) > 5*time.Minute {
-		cached = v.ListLocations()
+		var err error
+		cached, err = v.ListLocations(ctx)
+		if err != nil {
+			fmt.Printf("List locations error: %v\n", err)
+		}
 		v.statemx.Lock()
 		v.locationsCache = cached
 		v.locationsCacheTime = time.Now()
@@ -616,19 +992,21 @@ func (v *VPNManager) resolveLocation(cityName string) locations.Location {
 
 var ansiStripRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 
-func (v *VPNManager) CLIVersion() string {
-	cmdPath := resolveCommandPath()
-	cmd := exec.Command(cmdPath, "--version")
-	v.applySudoWrap(cmd)
-	prepareCLICommand(cmd)
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("CLI version error: %v\nOutput: %s\n", err, buf.String())
+func (v *VPNManager) CLIVersion(ctx context.Context) string {
+	args := []string{"--version"}
+	ctx, cancel := v.withCommandTimeout(ctx, args)
+	defer cancel()
+
+	var output string
+	proc, err := v.runner.Start(CLIRequest{Args: args, Env: v.childEnv()})
+	if err == nil {
+		output, err = waitContext(ctx, proc)
+	}
+	if err != nil {
+		fmt.Printf("CLI version error: %v\nOutput: %s\n", err, output)
 		return "unavailable"
 	}
-	output := ansiStripRegex.ReplaceAllString(buf.String(), "")
+	output = ansiStripRegex.ReplaceAllString(output, "")
 	output = strings.TrimSpace(output)
 	if output == "" {
 		return "unavailable"
@@ -636,25 +1014,41 @@ func (v *VPNManager) CLIVersion() string {
 	return output
 }
 
-func (v *VPNManager) Disconnect() {
+// Disconnect stops the tunnel and removes kill switch rules; errors are as for ConnectAuto.
+func (v *VPNManager) Disconnect(ctx context.Context) error {
+	v.stopWatchdog()
+	release, err := v.exclusive(ctx, "disconnect", "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	prev, err := v.transition(StateDisconnecting, "")
+	if err != nil {
+		return fmt.Errorf("failed to disconnect: %w", err)
+	}
 	if err := v.EnsureSudoPassword(); err != nil {
-		fmt.Printf("sudo auth error: %v\n", err)
-		return
+		v.restoreState(prev)
+		return newCLIError("disconnect", err, "")
 	}
-	output, err := v.executeCommand("disconnect")
+	output, err := v.executeCommand(ctx, "disconnect")
 	if err != nil {
-		fmt.Printf("Disconnect error: %v\nOutput: %s\n", err, output)
-		return
+		cliErr := newCLIError("disconnect", err, output)
+		v.failState(prev, cliErr)
+		return cliErr
 	}
 
 	v.statemx.Lock()
 	v.status = statusDisconnected
 	v.statemx.Unlock()
+	v.setStatusDetails(Status{State: StateDisconnected})
+	v.disengageKillSwitch()
 	v.applyDisconnected()
+	return nil
 }
 
-func (v *VPNManager) License() string {
-	output, err := v.executeCommand("license")
+func (v *VPNManager) License(ctx context.Context) string {
+	output, err := v.executeCommand(ctx, "license")
 	if err != nil {
 		fmt.Printf("Show license error: %v\nOutput: %s\n", err, output)
 		return ""
@@ -663,12 +1057,21 @@ func (v *VPNManager) License() string {
 }
 
 // GetSiteExclusions retrieves current exclusion mode and domain list from CLI output.
-func (v *VPNManager) GetSiteExclusions() (SiteExclusionMode, []string, error) {
-	output, err := v.executeCommand("site-exclusions", "show")
+func (v *VPNManager) GetSiteExclusions(ctx context.Context) (SiteExclusionMode, []string, error) {
+	output, err := v.executeCommand(ctx, "site-exclusions", "show")
 	if err != nil {
 		return SiteExclusionModeGeneral, nil, fmt.Errorf("site-exclusions show failed: %w, output: %s", err, output)
 	}
 
+	mode, exclusions := parseSiteExclusions(output)
+	v.statemx.Lock()
+	v.siteExclusionsMode = mode
+	v.statemx.Unlock()
+	return mode, exclusions, nil
+}
+
+// parseSiteExclusions reads the mode header and the domains from site-exclusions show.
+func parseSiteExclusions(output string) (SiteExclusionMode, []string) {
 	lines := strings.Split(output, "\n")
 	mode := SiteExclusionModeGeneral
 	var exclusions []string
@@ -685,84 +1088,184 @@ func (v *VPNManager) GetSiteExclusions() (SiteExclusionMode, []string, error) {
 		// Treat any remaining non-empty line as a domain entry.
 		exclusions = append(exclusions, trimmed)
 	}
-	v.statemx.Lock()
-	v.siteExclusionsMode = mode
-	v.statemx.Unlock()
-	return mode, exclusions, nil
+	return mode, exclusions
 }
 
 // AddSiteExclusion appends a domain to the exclusions list via CLI.
-func (v *VPNManager) AddSiteExclusion(domain string) error {
-	output, err := v.executeCommand("site-exclusions", "add", domain)
+// The domain is validated first and goes to the CLI in punycode.
+func (v *VPNManager) AddSiteExclusion(ctx context.Context, domain string) error {
+	domain, err := siteExclusionDomain(domain)
 	if err != nil {
-		return fmt.Errorf("site-exclusions add failed: %w, output: %s", err, output)
+		return err
+	}
+	release, err := v.exclusive(ctx, "add site exclusion", "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := v.addSiteExclusion(ctx, domain); err != nil {
+		return err
 	}
+	v.events.publish(ExclusionsChanged{Mode: v.SiteExclusionsMode(), Added: []string{domain}})
 	return nil
 }
 
-// RemoveSiteExclusion removes a domain from the exclusions list via CLI.
-func (v *VPNManager) RemoveSiteExclusion(domain string) error {
-	output, err := v.executeCommand("site-exclusions", "remove", domain)
+func (v *VPNManager) addSiteExclusion(ctx context.Context, domain string) error {
+	output, err := v.executeCommand(ctx, "site-exclusions", "add", domain)
 	if err != nil {
-		return fmt.Errorf("site-exclusions remove failed: %w, output: %s", err, output)
+		return fmt.Errorf("site-exclusions add failed: %w, output: %s", err, output)
 	}
 	return nil
 }
 
-// SetSiteExclusionsMode switches the site exclusions mode in AdGuard VPN.
-// Detailed explanation: It saves the domains of the previous mode to its corresponding file,
-// executes the CLI mode switch command, loads the domains for the new target mode from its file,
-// and applies them to the CLI.
-func (v *VPNManager) SetSiteExclusionsMode(mode SiteExclusionMode, domains []string) error {
-	prevMode := v.SiteExclusionsMode()
-
-	if err := SaveExclusionsForMode(prevMode, domains); err != nil {
-		return fmt.Errorf("failed to save exclusions for previous mode %s: %w", prevMode, err)
+// RemoveSiteExclusion removes a domain from the exclusions list via CLI.
+func (v *VPNManager) RemoveSiteExclusion(ctx context.Context, domain string) error {
+	release, err := v.exclusive(ctx, "remove site exclusion", "")
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	output, err := v.executeCommand("site-exclusions", "mode", mode.String())
-	if err != nil {
-		return fmt.Errorf("site-exclusions mode %s failed: %w, output: %s", mode, err, output)
+	if err := v.removeSiteExclusion(ctx, domain); err != nil {
+		return err
 	}
+	v.events.publish(ExclusionsChanged{Mode: v.SiteExclusionsMode(), Removed: []string{domain}})
+	return nil
+}
 
-	newDomains, err := LoadExclusionsForMode(mode)
+func (v *VPNManager) removeSiteExclusion(ctx context.Context, domain string) error {
+	output, err := v.executeCommand(ctx, "site-exclusions", "remove", domain)
 	if err != nil {
-		return fmt.Errorf("failed to load exclusions for target mode %s: %w", mode, err)
+		return fmt.Errorf("site-exclusions remove failed: %w, output: %s", err, output)
 	}
+	return nil
+}
 
-	for _, domain := range newDomains {
-		if strings.TrimSpace(domain) == "" {
-			continue
+// statusCheckLoop polls the CLI status. While the netlink watcher works, tunnel
+// changes trigger the checks and the poll only backs them up. Default route
+// changes are compared against the last uplink; without netlink every poll
+// compares them. A resume from suspend is checked at once.
+func (v *VPNManager) statusCheckLoop() {
+	ctx := v.loopCtx
+	changes, err := netwatch.Watch(ctx)
+	if err != nil {
+		fmt.Printf("network watch disabled: %v\n", err)
+	}
+	interval := statusPollInterval
+	if changes != nil {
+		interval = statusPollIntervalWatched
+	}
+	sleeps := sleepwatch.Watch(ctx)
+	uplink, _ := v.currentUplink()
+	checkUplink := func() {
+		current, ok := v.currentUplink()
+		if !ok || current == uplink {
+			return
 		}
-		if err := v.AddSiteExclusion(domain); err != nil {
-			return fmt.Errorf("re-applying domain %s failed: %w", domain, err)
+		prev := uplink
+		uplink = current
+		if prev.Interface != "" {
+			v.UplinkChanged(ctx, prev, current)
 		}
 	}
 
-	v.statemx.Lock()
-	v.siteExclusionsMode = mode
-	v.statemx.Unlock()
-	return nil
-}
-
-func (v *VPNManager) statusCheckLoop() {
-	time.Sleep(startDelay)
-	v.checkStatus()
+	if !sleepCtx(ctx, startDelay) {
+		return
+	}
+	v.checkStatus(ctx)
 
 	// Regular checks
-	v.statusTicker = time.NewTicker(60 * time.Second)
+	v.statusTicker = time.NewTicker(interval)
 	defer v.statusTicker.Stop()
 	for {
 		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				fmt.Printf("network watch stopped, polling status every %s\n", statusPollInterval)
+				changes = nil
+				v.statusTicker.Reset(statusPollInterval)
+				continue
+			}
+			v.NetworkChanged(change)
+		case <-v.netReqs:
+			if !sleepCtx(ctx, networkSettleDelay) {
+				return
+			}
+			v.checkStatus(ctx)
+		case event, ok := <-sleeps:
+			if !ok {
+				sleeps = nil
+				continue
+			}
+			v.WokeUp(ctx, event.Suspended)
+		case <-v.uplinkReqs:
+			if !sleepCtx(ctx, networkSettleDelay) {
+				return
+			}
+			checkUplink()
 		case <-v.checkReqs:
-			time.Sleep(startDelay)
-			v.checkStatus()
+			if !sleepCtx(ctx, startDelay) {
+				return
+			}
+			v.checkStatus(ctx)
 		case <-v.statusTicker.C:
-			v.checkStatus()
+			v.checkStatus(ctx)
+			if changes == nil {
+				checkUplink()
+			}
 		}
 	}
 }
 
+// NetworkChanged requests a status check when change concerns the tunnel: the
+// interface from the last status, or a point-to-point link appearing or
+// disappearing while the tunnel name is not known yet. Other link and route
+// changes request a default route comparison.
+func (v *VPNManager) NetworkChanged(change netwatch.Change) {
+	if v.tunnelChange(change) {
+		select {
+		case v.netReqs <- struct{}{}:
+		default:
+		}
+	}
+	if change.Kind != netwatch.KindAddress && !v.isTunnelInterface(change.Interface) {
+		select {
+		case v.uplinkReqs <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (v *VPNManager) tunnelChange(change netwatch.Change) bool {
+	if change.Kind == netwatch.KindLost {
+		return true
+	}
+	if v.isTunnelInterface(change.Interface) {
+		return true
+	}
+	return change.Kind == netwatch.KindLink && change.PointToPoint
+}
+
+// sleepCtx waits for d and reports false when ctx ends first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// RefreshStatus runs a status check now instead of waiting for the next tick.
+func (v *VPNManager) RefreshStatus(ctx context.Context) {
+	v.checkStatus(ctx)
+}
+
 func (v *VPNManager) shouldLogStatusCheck(key string) bool {
 	v.statemx.Lock()
 	defer v.statemx.Unlock()
@@ -773,28 +1276,50 @@ func (v *VPNManager) shouldLogStatusCheck(key string) bool {
 	return true
 }
 
-func (v *VPNManager) checkStatus() {
-	output, err := v.executeCommand("status")
+// checkStatus applies the CLI status unless a mutating operation started or
+// finished while the command ran; its output may predate that operation.
+func (v *VPNManager) checkStatus(ctx context.Context) {
+	output, epoch, err := v.executeShared(ctx, []string{"status"})
 	if err != nil {
 		if v.shouldLogStatusCheck("error:" + err.Error()) {
 			fmt.Printf("Status check error: %v\n", err)
 		}
 		return
 	}
+	if epoch != v.sched.Epoch() {
+		v.requestStatusCheck()
+		return
+	}
 
+	details, parseErr := ParseStatus(output)
 	v.statemx.Lock()
 	v.status = output
+	busy := v.state.Current().State.Busy()
 	v.statemx.Unlock()
+	if parseErr == nil {
+		v.setStatusDetails(details)
+	}
+
+	if parseErr != nil {
+		if v.shouldLogStatusCheck("parse:" + output) {
+			fmt.Printf("Status check error: %v: %s\n", parseErr, output)
+		}
+		return
+	}
+	// A running connect or disconnect settles the state itself.
+	if busy {
+		return
+	}
 
 	// Проверяем статус
-	if strings.Contains(output, statusDisconnected) {
+	if details.State == StateDisconnected {
 		if v.shouldLogStatusCheck("disconnected") {
 			fmt.Printf("status check: disconnected\n")
 		}
 		v.applyDisconnected()
-	} else if strings.Contains(output, "Connected to") {
-		locationName := ParseLocationFromStatus(output)
-		loc := v.resolveLocation(locationName)
+	} else {
+		locationName := details.City
+		loc := v.resolveLocation(ctx, locationName)
 		if v.shouldLogStatusCheck("connected:" + locationName) {
 			fmt.Printf("status check: connected to %s\n", locationName)
 		}
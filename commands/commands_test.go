@@ -17,8 +17,8 @@ package commands_test
 
 import (
 	"adgui/commands"
+	"context"
 	"os"
-	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -82,12 +82,10 @@ var _ = Describe("Command Queue Tracking and Killing", func() {
 
 	Context("when executing long-running CLI command", func() {
 		It("should register command in queue, notify callbacks, and remove on completion after kill", func() {
-			mgr := commands.New()
+			mgr := commands.New(commands.NewExecRunner())
 
-			var callbackCount int32
-			mgr.SetCommandQueueChangeCallback(func() {
-				atomic.AddInt32(&callbackCount, 1)
-			})
+			events, unsubscribe := mgr.Subscribe(8)
+			defer unsubscribe()
 
 			// Initially queue should be empty
 			Expect(mgr.RunningCommands()).To(BeEmpty())
@@ -95,7 +93,7 @@ var _ = Describe("Command Queue Tracking and Killing", func() {
 			// Run License() in a goroutine because our fake script sleeps
 			errChan := make(chan error, 1)
 			go func() {
-				_ = mgr.License()
+				_ = mgr.License(context.Background())
 				errChan <- nil
 			}()
 
@@ -111,8 +109,10 @@ var _ = Describe("Command Queue Tracking and Killing", func() {
 			Expect(cmd.Args).To(ConsistOf("license"))
 			Expect(cmd.PID).To(BeNumerically(">", 0))
 
-			// Verify callback was triggered at least once
-			Expect(atomic.LoadInt32(&callbackCount)).To(BeNumerically(">", 0))
+			// Verify the queue change was published
+			Expect(events).To(Receive(Equal(commands.CommandStarted{
+				ID: cmd.ID, Args: []string{"license"}, StartedAt: cmd.StartedAt,
+			})))
 
 			// Kill the command
 			err := mgr.KillCommand(cmd.ID)
@@ -125,13 +125,14 @@ var _ = Describe("Command Queue Tracking and Killing", func() {
 
 			// Make sure License goroutine has exited
 			Eventually(errChan, 2*time.Second).Should(Receive())
+			Eventually(events).Should(Receive(BeAssignableToTypeOf(commands.CommandFinished{})))
 		})
 
 		It("should terminate all commands when KillAllCommands is called", func() {
-			mgr := commands.New()
+			mgr := commands.New(commands.NewExecRunner())
 
 			// Run License() in a goroutine
-			go func() { _ = mgr.License() }()
+			go func() { _ = mgr.License(context.Background()) }()
 
 			Eventually(func() []commands.RunningCommand {
 				return mgr.RunningCommands()
@@ -213,10 +214,10 @@ var _ = Describe("Command Queue Tracking and Killing", func() {
 		It("should inject private sudo wrapper only into child CLI environment", func() {
 			originalPath := os.Getenv("PATH")
 			_ = os.Setenv("TERM", "xterm-test")
-			mgr := commands.New()
+			mgr := commands.New(commands.NewExecRunner())
 			defer func() { _ = mgr.Close() }()
 
-			output := mgr.License()
+			output := mgr.License(context.Background())
 			Expect(os.Getenv("PATH")).To(Equal(originalPath))
 			Expect(output).To(ContainSubstring("ASKPASS:"))
 			Expect(output).To(Or(
@@ -0,0 +1,85 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"adgui/locations"
+)
+
+// ConnectStage is a step of a connect operation reported by ConnectProgress.
+type ConnectStage int
+
+const (
+	StageResolvingLocation ConnectStage = iota
+	StageRequestingElevation
+	StageEstablishingTunnel
+	StageConfiguringDNS
+)
+
+// ConnectStageCount is the number of connect stages.
+const ConnectStageCount = int(StageConfiguringDNS) + 1
+
+func (s ConnectStage) String() string {
+	switch s {
+	case StageResolvingLocation:
+		return "resolving location"
+	case StageRequestingElevation:
+		return "requesting elevation"
+	case StageEstablishingTunnel:
+		return "establishing tunnel"
+	case StageConfiguringDNS:
+		return "configuring DNS"
+	default:
+		return fmt.Sprintf("stage(%d)", int(s))
+	}
+}
+
+// connectProgress publishes the stages of one connect operation. Stages only
+// move forward: a stage reached earlier or skipped is not published again.
+type connectProgress struct {
+	v   *VPNManager
+	loc locations.Location
+
+	mx   sync.Mutex
+	next ConnectStage
+}
+
+func (v *VPNManager) newConnectProgress(loc locations.Location) *connectProgress {
+	return &connectProgress{v: v, loc: loc}
+}
+
+func (p *connectProgress) advance(stage ConnectStage) {
+	p.mx.Lock()
+	if stage < p.next {
+		p.mx.Unlock()
+		return
+	}
+	p.next = stage + 1
+	p.mx.Unlock()
+	p.v.events.publish(ConnectProgress{Stage: stage, Location: p.loc})
+}
+
+// observe advances on connect output lines: adguardvpn-cli reports DNS
+// configuration once the tunnel is up.
+func (p *connectProgress) observe(line string) {
+	if strings.Contains(strings.ToLower(line), "dns") {
+		p.advance(StageConfiguringDNS)
+	}
+}
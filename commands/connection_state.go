@@ -0,0 +1,158 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a connection state change is not allowed,
+// for example a second connect while the first one is still running.
+var ErrInvalidTransition = errors.New("invalid connection state transition")
+
+// ConnectionState is the VPN connection lifecycle state owned by VPNManager.
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateDisconnecting
+	StateReconnecting
+	StateError
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnecting:
+		return "disconnecting"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateError:
+		return "error"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+// Busy reports whether a CLI operation owns the state and further
+// connect/disconnect requests must wait.
+func (s ConnectionState) Busy() bool {
+	return s == StateConnecting || s == StateDisconnecting || s == StateReconnecting
+}
+
+// allowedTransitions lists the states reachable from each state.
+// Self transitions of idle states are status check confirmations;
+// Connected -> Connected is also a location change reported by the CLI.
+var allowedTransitions = map[ConnectionState][]ConnectionState{
+	StateDisconnected:  {StateDisconnected, StateConnecting, StateConnected, StateDisconnecting, StateError},
+	StateConnecting:    {StateConnected, StateDisconnected, StateError},
+	StateConnected:     {StateConnected, StateDisconnecting, StateReconnecting, StateDisconnected, StateError},
+	StateDisconnecting: {StateDisconnected, StateConnected, StateError},
+	StateReconnecting:  {StateConnected, StateDisconnected, StateError},
+	StateError:         {StateConnecting, StateReconnecting, StateDisconnecting, StateConnected, StateDisconnected, StateError},
+}
+
+// Clock abstracts wall time so state changes and history can be tested deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// StateInfo describes the current connection state.
+type StateInfo struct {
+	State ConnectionState
+	// Reason explains StateError; empty for other states.
+	Reason string
+	Since  time.Time
+}
+
+// StateMachine validates connection state transitions.
+// It is not safe for concurrent use; VPNManager guards it with its state mutex.
+type StateMachine struct {
+	clock   Clock
+	current StateInfo
+}
+
+// NewStateMachine returns a machine in StateDisconnected. A nil clock uses wall time.
+func NewStateMachine(clock Clock) *StateMachine {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &StateMachine{
+		clock:   clock,
+		current: StateInfo{State: StateDisconnected, Since: clock.Now()},
+	}
+}
+
+// Current returns the current state.
+func (m *StateMachine) Current() StateInfo {
+	return m.current
+}
+
+// SetClock replaces the clock used to stamp future transitions.
+func (m *StateMachine) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	m.clock = clock
+}
+
+// Now returns the machine clock time.
+func (m *StateMachine) Now() time.Time {
+	return m.clock.Now()
+}
+
+// CanTransition reports whether the machine may move to the target state.
+func (m *StateMachine) CanTransition(to ConnectionState) bool {
+	for _, allowed := range allowedTransitions[m.current.State] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the machine to the target state and returns the previous one.
+// reason is kept only for StateError. Since is preserved on idle self transitions.
+func (m *StateMachine) Transition(to ConnectionState, reason string) (StateInfo, error) {
+	prev := m.current
+	if !m.CanTransition(to) {
+		return prev, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, prev.State, to)
+	}
+	next := StateInfo{State: to, Since: m.clock.Now()}
+	if to == StateError {
+		next.Reason = reason
+	}
+	if to == prev.State && to != StateError {
+		next.Since = prev.Since
+	}
+	m.current = next
+	return prev, nil
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// gatedRunner wraps a replay runner and holds connect invocations until release is closed.
+type gatedRunner struct {
+	next    commands.CLIRunner
+	release chan struct{}
+}
+
+func (r *gatedRunner) Start(req commands.CLIRequest) (commands.CLIProcess, error) {
+	if len(req.Args) > 0 && req.Args[0] == "connect" {
+		<-r.release
+	}
+	return r.next.Start(req)
+}
+
+var _ = Describe("Connection state machine", func() {
+	var clock *fakeClock
+
+	BeforeEach(func() {
+		clock = &fakeClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	})
+
+	It("starts disconnected and stamps transitions with the injected clock", func() {
+		m := commands.NewStateMachine(clock)
+		Expect(m.Current().State).To(Equal(commands.StateDisconnected))
+
+		clock.now = clock.now.Add(time.Minute)
+		prev, err := m.Transition(commands.StateConnecting, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prev.State).To(Equal(commands.StateDisconnected))
+		Expect(m.Current()).To(Equal(commands.StateInfo{State: commands.StateConnecting, Since: clock.now}))
+	})
+
+	It("rejects a second connect while connecting", func() {
+		m := commands.NewStateMachine(clock)
+		_, err := m.Transition(commands.StateConnecting, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = m.Transition(commands.StateConnecting, "")
+		Expect(err).To(MatchError(commands.ErrInvalidTransition))
+		_, err = m.Transition(commands.StateReconnecting, "")
+		Expect(err).To(MatchError(commands.ErrInvalidTransition))
+		Expect(m.Current().State).To(Equal(commands.StateConnecting))
+	})
+
+	It("keeps the error reason only for the error state", func() {
+		m := commands.NewStateMachine(clock)
+		_, err := m.Transition(commands.StateConnecting, "ignored")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Current().Reason).To(BeEmpty())
+
+		_, err = m.Transition(commands.StateError, "server did not respond")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Current().Reason).To(Equal("server did not respond"))
+
+		_, err = m.Transition(commands.StateDisconnected, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Current().Reason).To(BeEmpty())
+	})
+
+	It("keeps Since on a status confirmation of the same state", func() {
+		m := commands.NewStateMachine(clock)
+		_, err := m.Transition(commands.StateConnected, "")
+		Expect(err).NotTo(HaveOccurred())
+		since := m.Current().Since
+
+		clock.now = clock.now.Add(time.Hour)
+		_, err = m.Transition(commands.StateConnected, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Current().Since).To(Equal(since))
+	})
+
+	It("reports busy states", func() {
+		Expect(commands.StateConnecting.Busy()).To(BeTrue())
+		Expect(commands.StateDisconnecting.Busy()).To(BeTrue())
+		Expect(commands.StateReconnecting.Busy()).To(BeTrue())
+		Expect(commands.StateConnected.Busy()).To(BeFalse())
+		Expect(commands.StateError.Busy()).To(BeFalse())
+	})
+
+	Context("in VPNManager", func() {
+		var (
+			tempHome    string
+			oldHome     string
+			oldSudoWrap string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempHome, err = os.MkdirTemp("", "adgui-state-home-*")
+			Expect(err).NotTo(HaveOccurred())
+			oldHome = os.Getenv("HOME")
+			oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+			Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+			Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if oldHome != "" {
+				_ = os.Setenv("HOME", oldHome)
+			}
+			if oldSudoWrap != "" {
+				_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+			} else {
+				_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+			}
+			_ = os.RemoveAll(tempHome)
+		})
+
+		riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+
+		It("shows Connecting while the CLI runs and queues a second connect", func() {
+			runner := &gatedRunner{
+				next: commands.NewReplayRunner([]commands.TranscriptEntry{
+					{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+				}),
+				release: make(chan struct{}),
+			}
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			mgr.SetClock(clock)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_ = mgr.ConnectToLocation(context.Background(), riga)
+			}()
+			Eventually(func() commands.ConnectionState {
+				return mgr.ConnectionState().State
+			}).Should(Equal(commands.StateConnecting))
+			Expect(mgr.IsConnected()).To(BeFalse())
+
+			second := make(chan error, 1)
+			go func() {
+				second <- mgr.ConnectToLocation(context.Background(), riga)
+			}()
+			Consistently(second, 50*time.Millisecond).ShouldNot(Receive())
+			Expect(mgr.ConnectionState().State).To(Equal(commands.StateConnecting))
+
+			close(runner.release)
+			Eventually(done).Should(BeClosed())
+			Eventually(second).Should(Receive(BeNil()))
+			Expect(mgr.ConnectionState()).To(Equal(commands.StateInfo{State: commands.StateConnected, Since: clock.now}))
+			Expect(mgr.ConnectionHistory()[0].StartedAt).To(Equal(clock.now))
+		})
+
+		It("moves to Error with the CLI message when connect fails", func() {
+			mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "\nFailed to connect: server did not respond\n", ExitCode: 1},
+			}))
+			defer func() { _ = mgr.Close() }()
+
+			err := mgr.ConnectToLocation(context.Background(), riga)
+			var cliErr *commands.CLIError
+			Expect(errors.As(err, &cliErr)).To(BeTrue())
+			Expect(cliErr.Kind).To(Equal(commands.ErrorOther))
+			Expect(cliErr.ExitCode).To(Equal(1))
+			Expect(mgr.LastError()).To(Equal(cliErr))
+			state := mgr.ConnectionState()
+			Expect(state.State).To(Equal(commands.StateError))
+			Expect(state.Reason).To(Equal("Failed to connect: server did not respond"))
+			Expect(mgr.IsConnected()).To(BeFalse())
+		})
+
+		It("ends the history entry with the injected clock on disconnect", func() {
+			mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+				{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+			}))
+			defer func() { _ = mgr.Close() }()
+			mgr.SetClock(clock)
+
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+			clock.now = clock.now.Add(90 * time.Second)
+			Expect(mgr.Disconnect(context.Background())).To(Succeed())
+
+			Expect(mgr.ConnectionState().State).To(Equal(commands.StateDisconnected))
+			history := mgr.PreviousConnectionHistory()
+			Expect(history).To(HaveLen(1))
+			Expect(history[0].StartedAt).To(Equal(clock.now.Add(-90 * time.Second)))
+			Expect(history[0].EndedAt).To(HaveValue(Equal(clock.now)))
+		})
+	})
+})
@@ -37,6 +37,9 @@ type ConnectionHistoryEntry struct {
 	Ping      int        `json:"ping"`
 	StartedAt time.Time  `json:"started_at"`
 	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// Reason notes watchdog activity: an unexpected disconnect or a reconnect attempt.
+	// Empty for sessions the user started and ended.
+	Reason string `json:"reason,omitempty"`
 }
 
 // GetDataDir returns the XDG user data directory for adgui.
@@ -0,0 +1,168 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"sync"
+	"time"
+
+	"adgui/locations"
+)
+
+const defaultEventBuffer = 16
+
+// Event is a VPNManager notification delivered through Subscribe.
+// Concrete types: StatusChanged, CommandStarted, CommandOutput, CommandFinished,
+// ConnectProgress, FailoverAttempt, ExclusionsChanged, WarningsChanged,
+// HistoryAppended and KillSwitchChanged.
+type Event interface {
+	isEvent()
+}
+
+// StatusChanged reports a connection state transition or a location change.
+type StatusChanged struct {
+	From     ConnectionState
+	To       ConnectionState
+	Reason   string
+	Location locations.Location
+}
+
+// CommandStarted reports a CLI invocation registered in the command queue.
+type CommandStarted struct {
+	ID        uint64
+	Args      []string
+	StartedAt time.Time
+}
+
+// CommandOutput reports one output line of a mutating CLI invocation in the
+// command queue; RunningCommands holds the output of every invocation.
+type CommandOutput struct {
+	ID   uint64
+	Line string
+}
+
+// CommandFinished reports a CLI invocation leaving the command queue.
+type CommandFinished struct {
+	ID       uint64
+	Args     []string
+	ExitCode int
+	Duration time.Duration
+}
+
+// ConnectProgress reports a connect operation reaching stage. Location is
+// empty when adguardvpn-cli picks the location.
+type ConnectProgress struct {
+	Stage    ConnectStage
+	Location locations.Location
+}
+
+// FailoverAttempt reports a failed connect moving on to the next location of
+// the failover chain. Location is empty for FailoverAuto.
+type FailoverAttempt struct {
+	// Attempt counts failover attempts from 1; the first connect is not one.
+	Attempt  int
+	Step     FailoverStep
+	Failed   locations.Location
+	Failure  string
+	Location locations.Location
+}
+
+// ExclusionsChanged reports site exclusions changed through VPNManager.
+type ExclusionsChanged struct {
+	Mode    SiteExclusionMode
+	Added   []string
+	Removed []string
+}
+
+// WarningsChanged reports a new set of active CLI warnings or a dismissal.
+type WarningsChanged struct {
+	Warnings []Warning
+}
+
+// HistoryAppended reports a finished connection session saved to history.
+type HistoryAppended struct {
+	Entry ConnectionHistoryEntry
+}
+
+// KillSwitchChanged reports the kill switch rules being installed or removed.
+type KillSwitchChanged struct {
+	Engaged   bool
+	Interface string
+}
+
+func (StatusChanged) isEvent()     {}
+func (CommandStarted) isEvent()    {}
+func (CommandOutput) isEvent()     {}
+func (CommandFinished) isEvent()   {}
+func (ConnectProgress) isEvent()   {}
+func (FailoverAttempt) isEvent()   {}
+func (ExclusionsChanged) isEvent() {}
+func (WarningsChanged) isEvent()   {}
+func (HistoryAppended) isEvent()   {}
+func (KillSwitchChanged) isEvent() {}
+
+// eventBus fans events out to subscribers without blocking the publisher.
+// A subscriber whose buffer is full misses the event.
+type eventBus struct {
+	mx     sync.Mutex
+	subs   map[uint64]chan Event
+	nextID uint64
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[uint64]chan Event)}
+}
+
+func (b *eventBus) subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	ch := make(chan Event, buffer)
+
+	b.mx.Lock()
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = ch
+	b.mx.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mx.Lock()
+			delete(b.subs, id)
+			b.mx.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) publish(ev Event) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of manager events and a function that unsubscribes
+// and closes the channel. Delivery never blocks the manager: when the buffer
+// (default 16 for buffer <= 0) is full, events are dropped for this subscriber.
+func (v *VPNManager) Subscribe(buffer int) (<-chan Event, func()) {
+	return v.events.subscribe(buffer)
+}
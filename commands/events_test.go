@@ -0,0 +1,180 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// drainEvents collects events of type T that are already buffered.
+func drainEvents[T commands.Event](events <-chan commands.Event) []T {
+	var result []T
+	for {
+		select {
+		case ev := <-events:
+			if typed, ok := ev.(T); ok {
+				result = append(result, typed)
+			}
+		default:
+			return result
+		}
+	}
+}
+
+var _ = Describe("VPNManager events", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-events-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+
+	It("delivers status transitions and history to every subscriber", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		first, unsubscribeFirst := mgr.Subscribe(32)
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := mgr.Subscribe(32)
+		defer unsubscribeSecond()
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.Disconnect(context.Background())).To(Succeed())
+
+		expected := []commands.StatusChanged{
+			{From: commands.StateDisconnected, To: commands.StateConnecting},
+			{From: commands.StateConnecting, To: commands.StateConnected, Location: riga},
+			{From: commands.StateConnected, To: commands.StateDisconnecting, Location: riga},
+			{From: commands.StateDisconnecting, To: commands.StateDisconnected},
+		}
+		Expect(drainEvents[commands.StatusChanged](first)).To(Equal(expected))
+
+		history := drainEvents[commands.HistoryAppended](second)
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].Entry.City).To(Equal("Riga"))
+	})
+
+	It("reports command exit codes and exclusion changes", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "example.com"}, Output: "added\n"},
+			{Args: []string{"site-exclusions", "remove", "example.org"}, Output: "not found\n", ExitCode: 3},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		Expect(mgr.AddSiteExclusion(context.Background(), "example.com")).To(Succeed())
+		Expect(mgr.RemoveSiteExclusion(context.Background(), "example.org")).NotTo(Succeed())
+
+		var finished []commands.CommandFinished
+		var changed []commands.ExclusionsChanged
+		for _, ev := range drainEvents[commands.Event](events) {
+			switch typed := ev.(type) {
+			case commands.CommandFinished:
+				finished = append(finished, typed)
+			case commands.ExclusionsChanged:
+				changed = append(changed, typed)
+			}
+		}
+		Expect(finished).To(HaveLen(2))
+		Expect(finished[0].ExitCode).To(Equal(0))
+		Expect(finished[1].ExitCode).To(Equal(3))
+		Expect(changed).To(Equal([]commands.ExclusionsChanged{
+			{Mode: commands.SiteExclusionModeGeneral, Added: []string{"example.com"}},
+		}))
+	})
+
+	It("reports connect stages and command output lines", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to \x1b[1mRIGA\x1b[0m\nWarning: System DNS could not be configured\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+		var stages []commands.ConnectStage
+		var lines []string
+		for _, ev := range drainEvents[commands.Event](events) {
+			switch typed := ev.(type) {
+			case commands.ConnectProgress:
+				Expect(typed.Location).To(Equal(riga))
+				stages = append(stages, typed.Stage)
+			case commands.CommandOutput:
+				lines = append(lines, typed.Line)
+			}
+		}
+		Expect(stages).To(Equal([]commands.ConnectStage{
+			commands.StageResolvingLocation,
+			commands.StageRequestingElevation,
+			commands.StageEstablishingTunnel,
+			commands.StageConfiguringDNS,
+		}))
+		Expect(lines).To(Equal([]string{
+			"Successfully Connected to RIGA",
+			"Warning: System DNS could not be configured",
+		}))
+	})
+
+	It("drops events for a full subscriber and closes the channel on unsubscribe", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "example.com"}, Output: "added\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(1)
+
+		for range 3 {
+			Expect(mgr.AddSiteExclusion(context.Background(), "example.com")).To(Succeed())
+		}
+		Expect(events).To(HaveLen(1))
+
+		unsubscribe()
+		unsubscribe()
+		Eventually(events).Should(BeClosed())
+	})
+})
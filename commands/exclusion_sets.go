@@ -0,0 +1,375 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	exclusionSetExt       = ".txt"
+	exclusionSetModeLabel = "# mode: "
+	// activeExclusionSetFile holds the name of the last activated set. Set
+	// names cannot start with a dot, so it never clashes with a set.
+	activeExclusionSetFile = ".active-set"
+	maxExclusionSetName    = 64
+)
+
+var (
+	// ErrExclusionSetExists is returned when a new set name is taken, ignoring case.
+	ErrExclusionSetExists = errors.New("exclusion set already exists")
+	// ErrExclusionSetNotFound is returned for a set without a file.
+	ErrExclusionSetNotFound = errors.New("exclusion set not found")
+)
+
+// ExclusionSet is a named list of domains bound to the exclusions mode it is
+// activated in. Each set is a file under ~/.config/adgui/site-exclusions, next
+// to the lists of the modes, with a "# mode: general" header followed by one
+// domain per line.
+type ExclusionSet struct {
+	Name    string
+	Mode    SiteExclusionMode
+	Domains []string
+}
+
+// ValidateExclusionSetName rejects names that cannot be used as file names.
+func ValidateExclusionSetName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("exclusion set name is empty")
+	case name != strings.TrimSpace(name):
+		return fmt.Errorf("exclusion set name %q has leading or trailing spaces", name)
+	case utf8.RuneCountInString(name) > maxExclusionSetName:
+		return fmt.Errorf("exclusion set name is longer than %d characters", maxExclusionSetName)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("exclusion set name %q starts with a dot", name)
+	case strings.EqualFold(name, string(SiteExclusionModeGeneral)) || strings.EqualFold(name, string(SiteExclusionModeSelective)):
+		return fmt.Errorf("exclusion set name %q is taken by the list of the mode", name)
+	case strings.ContainsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\' || !unicode.IsPrint(r)
+	}):
+		return fmt.Errorf("exclusion set name %q contains a slash or a control character", name)
+	}
+	return nil
+}
+
+func exclusionSetPath(name string) (string, error) {
+	if err := ValidateExclusionSetName(name); err != nil {
+		return "", err
+	}
+	dir, err := GetExclusionsDirPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+exclusionSetExt), nil
+}
+
+// ListExclusionSets returns the saved sets sorted by name. Unreadable files are skipped.
+func ListExclusionSets() ([]ExclusionSet, error) {
+	dir, err := GetExclusionsDirPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read exclusions directory: %w", err)
+	}
+
+	var sets []ExclusionSet
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), exclusionSetExt)
+		if !ok || entry.IsDir() || ValidateExclusionSetName(name) != nil {
+			continue
+		}
+		set, err := LoadExclusionSet(name)
+		if err != nil {
+			fmt.Printf("skipping exclusion set %s: %v\n", name, err)
+			continue
+		}
+		sets = append(sets, set)
+	}
+	slices.SortFunc(sets, func(a, b ExclusionSet) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return sets, nil
+}
+
+// LoadExclusionSet reads the named set. A file without the mode header
+// belongs to the general mode.
+func LoadExclusionSet(name string) (ExclusionSet, error) {
+	path, err := exclusionSetPath(name)
+	if err != nil {
+		return ExclusionSet{}, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ExclusionSet{}, fmt.Errorf("%w: %s", ErrExclusionSetNotFound, name)
+		}
+		return ExclusionSet{}, fmt.Errorf("failed to open exclusion set: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	set := ExclusionSet{Name: name, Mode: SiteExclusionModeGeneral}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if mode, ok := strings.CutPrefix(line, exclusionSetModeLabel); ok {
+			set.Mode = parseSiteExclusionMode(mode)
+			continue
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set.Domains = append(set.Domains, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ExclusionSet{}, fmt.Errorf("failed to read exclusion set: %w", err)
+	}
+	set.Domains = NormalizeDomains(set.Domains)
+	return set, nil
+}
+
+// SaveExclusionSet creates or replaces the set file. The file is written to
+// a temporary name first, so a failed save keeps the old content.
+func SaveExclusionSet(set ExclusionSet) error {
+	path, err := exclusionSetPath(set.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create exclusions directory: %w", err)
+	}
+
+	mode := set.Mode
+	if mode != SiteExclusionModeSelective {
+		mode = SiteExclusionModeGeneral
+	}
+	var b strings.Builder
+	b.WriteString(exclusionSetModeLabel + mode.String() + "\n")
+	for _, domain := range NormalizeDomains(set.Domains) {
+		b.WriteString(domain + "\n")
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write exclusion set: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write exclusion set: %w", err)
+	}
+	return nil
+}
+
+// CreateExclusionSet saves a new set and fails when the name is taken.
+func CreateExclusionSet(set ExclusionSet) error {
+	if err := checkExclusionSetFree(set.Name); err != nil {
+		return err
+	}
+	return SaveExclusionSet(set)
+}
+
+// RenameExclusionSet renames a set; the active set keeps being active under the new name.
+func RenameExclusionSet(name, newName string) error {
+	from, err := exclusionSetPath(name)
+	if err != nil {
+		return err
+	}
+	to, err := exclusionSetPath(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(from); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrExclusionSetNotFound, name)
+		}
+		return fmt.Errorf("failed to check exclusion set: %w", err)
+	}
+	// A change of case only is a rename of the same file on case-insensitive filesystems.
+	if !strings.EqualFold(name, newName) {
+		if err := checkExclusionSetFree(newName); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to rename exclusion set: %w", err)
+	}
+
+	if active, err := ActiveExclusionSet(); err == nil && active == name {
+		return SetActiveExclusionSet(newName)
+	}
+	return nil
+}
+
+// DuplicateExclusionSet copies a set under a new name.
+func DuplicateExclusionSet(name, newName string) error {
+	set, err := LoadExclusionSet(name)
+	if err != nil {
+		return err
+	}
+	set.Name = newName
+	return CreateExclusionSet(set)
+}
+
+// DeleteExclusionSet removes a set; deleting the active set leaves no set active.
+// The CLI list is not changed.
+func DeleteExclusionSet(name string) error {
+	path, err := exclusionSetPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrExclusionSetNotFound, name)
+		}
+		return fmt.Errorf("failed to delete exclusion set: %w", err)
+	}
+	if active, err := ActiveExclusionSet(); err == nil && active == name {
+		return SetActiveExclusionSet("")
+	}
+	return nil
+}
+
+func checkExclusionSetFree(name string) error {
+	sets, err := ListExclusionSets()
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		if strings.EqualFold(set.Name, name) {
+			return fmt.Errorf("%w: %s", ErrExclusionSetExists, set.Name)
+		}
+	}
+	_, err = exclusionSetPath(name)
+	return err
+}
+
+// ActiveExclusionSet returns the name of the last activated set, or "" when
+// none is active.
+func ActiveExclusionSet() (string, error) {
+	dir, err := GetExclusionsDirPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, activeExclusionSetFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read active exclusion set: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetActiveExclusionSet records name as the active set without touching the
+// CLI, e.g. for a set just created from the current list. An empty name
+// leaves no set active.
+func SetActiveExclusionSet(name string) error {
+	dir, err := GetExclusionsDirPath()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, activeExclusionSetFile)
+	if name == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear active exclusion set: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create exclusions directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(name+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write active exclusion set: %w", err)
+	}
+	return nil
+}
+
+// SyncActiveExclusionSet keeps the active set in step with edits of the CLI
+// list: the set takes the domains of its own mode, and switching to the other
+// mode leaves no set active.
+func SyncActiveExclusionSet(mode SiteExclusionMode, domains []string) error {
+	set, ok, err := activeExclusionSetFor(mode)
+	if err != nil || !ok {
+		return err
+	}
+	if add, remove := DiffExclusions(set.Domains, domains); len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	set.Domains = domains
+	return SaveExclusionSet(set)
+}
+
+// activeExclusionSetFor loads the active set if it belongs to mode and
+// otherwise leaves no set active.
+func activeExclusionSetFor(mode SiteExclusionMode) (ExclusionSet, bool, error) {
+	name, err := ActiveExclusionSet()
+	if err != nil || name == "" {
+		return ExclusionSet{}, false, err
+	}
+	set, err := LoadExclusionSet(name)
+	if err != nil && !errors.Is(err, ErrExclusionSetNotFound) {
+		return ExclusionSet{}, false, err
+	}
+	if err != nil || set.Mode != mode {
+		return ExclusionSet{}, false, SetActiveExclusionSet("")
+	}
+	return set, true, nil
+}
+
+// ActivateExclusionSet switches the CLI to the mode of the named set and
+// replaces its list with the set as one transaction, like
+// SetSiteExclusionsMode: a failed step restores the previous mode and the
+// current domains. The current domains are saved for the previous mode first,
+// and the set becomes the saved list of its mode.
+func (v *VPNManager) ActivateExclusionSet(ctx context.Context, name string, domains []string) error {
+	set, err := LoadExclusionSet(name)
+	if err != nil {
+		return err
+	}
+
+	release, err := v.exclusive(ctx, "activate exclusion set", "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	prevMode := v.SiteExclusionsMode()
+	if err := SaveExclusionsForMode(prevMode, domains); err != nil {
+		return fmt.Errorf("failed to save exclusions for previous mode %s: %w", prevMode, err)
+	}
+	if err := v.switchExclusions(ctx, prevMode, domains, set.Mode, set.Domains, false); err != nil {
+		return fmt.Errorf("failed to activate exclusion set %s: %w", name, err)
+	}
+	if err := SaveExclusionsForMode(set.Mode, set.Domains); err != nil {
+		return fmt.Errorf("failed to save exclusions for mode %s: %w", set.Mode, err)
+	}
+	return SetActiveExclusionSet(name)
+}
@@ -0,0 +1,187 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exclusion sets", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-exclusion-sets-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	work := commands.ExclusionSet{
+		Name:    "Work",
+		Mode:    commands.SiteExclusionModeSelective,
+		Domains: []string{"intranet.example.com", "jira.example.com"},
+	}
+
+	DescribeTable("validates set names",
+		func(name string, valid bool) {
+			err := commands.ValidateExclusionSetName(name)
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("plain name", "Streaming", true),
+		Entry("spaces and unicode", "Поездки 2026", true),
+		Entry("empty", "", false),
+		Entry("padded", " work", false),
+		Entry("hidden file", ".active-set", false),
+		Entry("mode list", "General", false),
+		Entry("mode list", "selective", false),
+		Entry("path", "../work", false),
+		Entry("control character", "work\n", false),
+	)
+
+	It("stores sets with their mode next to the mode lists", func() {
+		Expect(commands.CreateExclusionSet(work)).To(Succeed())
+		Expect(commands.CreateExclusionSet(commands.ExclusionSet{Name: "Travel", Domains: []string{"Maps.example.com", "maps.example.com"}})).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(tempHome, ".config", "adgui", "site-exclusions", "Work.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("# mode: selective\nintranet.example.com\njira.example.com\n"))
+
+		sets, err := commands.ListExclusionSets()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sets).To(Equal([]commands.ExclusionSet{
+			{Name: "Travel", Mode: commands.SiteExclusionModeGeneral, Domains: []string{"Maps.example.com"}},
+			work,
+		}))
+		Expect(errors.Is(commands.CreateExclusionSet(commands.ExclusionSet{Name: "work"}), commands.ErrExclusionSetExists)).To(BeTrue())
+	})
+
+	It("renames, duplicates and deletes sets", func() {
+		Expect(commands.CreateExclusionSet(work)).To(Succeed())
+		Expect(commands.DuplicateExclusionSet("Work", "Office")).To(Succeed())
+		Expect(commands.RenameExclusionSet("Work", "Job")).To(Succeed())
+		Expect(errors.Is(commands.RenameExclusionSet("Job", "Office"), commands.ErrExclusionSetExists)).To(BeTrue())
+
+		office, err := commands.LoadExclusionSet("Office")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(office.Mode).To(Equal(work.Mode))
+		Expect(office.Domains).To(Equal(work.Domains))
+		_, err = commands.LoadExclusionSet("Work")
+		Expect(errors.Is(err, commands.ErrExclusionSetNotFound)).To(BeTrue())
+
+		Expect(commands.DeleteExclusionSet("Job")).To(Succeed())
+		Expect(errors.Is(commands.DeleteExclusionSet("Job"), commands.ErrExclusionSetNotFound)).To(BeTrue())
+		sets, err := commands.ListExclusionSets()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sets).To(HaveLen(1))
+		Expect(sets[0].Name).To(Equal("Office"))
+	})
+
+	It("activates a set as one transaction", func() {
+		Expect(commands.CreateExclusionSet(work)).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\njira.example.com\nold.example.com\n"},
+			{Args: []string{"site-exclusions", "remove", "old.example.com"}, Output: "old.example.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "intranet.example.com"}, Output: "intranet.example.com added to exclusions\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		Expect(mgr.ActivateExclusionSet(context.Background(), "Work", []string{"news.example.com"})).To(Succeed())
+		Expect(mgr.SiteExclusionsMode()).To(Equal(commands.SiteExclusionModeSelective))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{
+			"mode selective", "show", "remove old.example.com", "add intranet.example.com",
+		}))
+		Expect(commands.ActiveExclusionSet()).To(Equal("Work"))
+		Expect(commands.LoadExclusionsForMode(commands.SiteExclusionModeGeneral)).To(Equal([]string{"news.example.com"}))
+		Expect(commands.LoadExclusionsForMode(commands.SiteExclusionModeSelective)).To(Equal(work.Domains))
+
+		By("following edits of the list while active")
+		Expect(commands.SyncActiveExclusionSet(commands.SiteExclusionModeSelective, []string{"jira.example.com"})).To(Succeed())
+		set, err := commands.LoadExclusionSet("Work")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.Domains).To(Equal([]string{"jira.example.com"}))
+
+		By("keeping the saved order when only the order of the list changed")
+		set.Domains = []string{"jira.example.com", "intranet.example.com"}
+		Expect(commands.SaveExclusionSet(set)).To(Succeed())
+		Expect(commands.SyncActiveExclusionSet(commands.SiteExclusionModeSelective, []string{"Intranet.example.com", "jira.example.com"})).To(Succeed())
+		set, err = commands.LoadExclusionSet("Work")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.Domains).To(Equal([]string{"jira.example.com", "intranet.example.com"}))
+
+		By("keeping the active set across a rename")
+		Expect(commands.RenameExclusionSet("Work", "Job")).To(Succeed())
+		Expect(commands.ActiveExclusionSet()).To(Equal("Job"))
+
+		By("leaving no set active after a switch to the other mode")
+		Expect(commands.SyncActiveExclusionSet(commands.SiteExclusionModeGeneral, []string{"news.example.com"})).To(Succeed())
+		Expect(commands.ActiveExclusionSet()).To(BeEmpty())
+		set, err = commands.LoadExclusionSet("Job")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.Domains).To(Equal([]string{"jira.example.com", "intranet.example.com"}))
+	})
+
+	It("keeps the previous set active when activation fails", func() {
+		Expect(commands.CreateExclusionSet(work)).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\nnews.example.com\n"},
+			{Args: []string{"site-exclusions", "add", "intranet.example.com"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "mode", "general"}, Output: "Exclusions mode set to GENERAL\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		err := mgr.ActivateExclusionSet(context.Background(), "Work", []string{"news.example.com"})
+		var switchErr *commands.ExclusionsSwitchError
+		Expect(errors.As(err, &switchErr)).To(BeTrue())
+		Expect(switchErr.RollbackErr).NotTo(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("failed to activate exclusion set Work: "))
+		Expect(mgr.SiteExclusionsMode()).To(Equal(commands.SiteExclusionModeGeneral))
+		Expect(commands.ActiveExclusionSet()).To(BeEmpty())
+		Expect(commands.LoadExclusionsForMode(commands.SiteExclusionModeSelective)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,391 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchAction is the change a batch applies to every domain.
+type BatchAction string
+
+const (
+	BatchAdd    BatchAction = "add"
+	BatchRemove BatchAction = "remove"
+)
+
+// ErrBatchCanceled is the result of the domains a cancelled batch did not finish.
+var ErrBatchCanceled = errors.New("batch cancelled")
+
+// transientFragments are lowercase fragments of CLI output worth a retry.
+var transientFragments = []string{"try again", "temporarily", "busy", "locked"}
+
+// BatchOptions tunes BatchSiteExclusions. Zero fields take the defaults.
+type BatchOptions struct {
+	// Workers bounds the CLI processes running at once.
+	Workers int
+	// ChunkSize is the number of domains passed to one CLI call. A failed
+	// call with several domains is repeated one domain at a time, so every
+	// domain gets its own result. When all of them pass that way, the CLI is
+	// taken to accept a single domain and the rest of the batch uses single calls.
+	ChunkSize int
+	// Attempts per call, counting the first; only transient failures are retried.
+	Attempts   int
+	RetryDelay time.Duration
+	// OnProgress, when set, receives the number of finished domains.
+	// Calls come from one goroutine at a time.
+	OnProgress func(done, total int)
+}
+
+// DefaultBatchOptions returns the options used for zero fields.
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		Workers:    4,
+		ChunkSize:  50,
+		Attempts:   3,
+		RetryDelay: 500 * time.Millisecond,
+	}
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	def := DefaultBatchOptions()
+	if o.Workers <= 0 {
+		o.Workers = def.Workers
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = def.ChunkSize
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = def.Attempts
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = def.RetryDelay
+	}
+	return o
+}
+
+// BatchResult is the outcome for one domain; Err is nil when the change was applied.
+type BatchResult struct {
+	Domain string
+	Err    error
+}
+
+// BatchReport lists the outcome of every domain of a batch in input order.
+type BatchReport struct {
+	Action  BatchAction
+	Results []BatchResult
+}
+
+// Applied returns the domains the batch changed.
+func (r BatchReport) Applied() []string {
+	var result []string
+	for _, res := range r.Results {
+		if res.Err == nil {
+			result = append(result, res.Domain)
+		}
+	}
+	return result
+}
+
+// Failed returns the domains the CLI refused or failed on.
+func (r BatchReport) Failed() []BatchResult {
+	var result []BatchResult
+	for _, res := range r.Results {
+		if res.Err != nil && !errors.Is(res.Err, ErrBatchCanceled) {
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+// Skipped returns the domains left unfinished by a cancellation.
+func (r BatchReport) Skipped() []string {
+	var result []string
+	for _, res := range r.Results {
+		if errors.Is(res.Err, ErrBatchCanceled) {
+			result = append(result, res.Domain)
+		}
+	}
+	return result
+}
+
+// BatchSiteExclusions adds or removes many domains as one operation.
+// Chunks of domains go to up to opts.Workers CLI calls at once, transient
+// failures are retried, and a failure only affects the domains it belongs to.
+// Cancelling ctx stops the batch; the unfinished domains get ErrBatchCanceled.
+// The error is only set when the batch could not start. Added domains are
+// validated first; the invalid ones fail without a CLI call.
+func (v *VPNManager) BatchSiteExclusions(ctx context.Context, action BatchAction, domains []string, opts BatchOptions) (BatchReport, error) {
+	domains = NormalizeDomains(domains)
+	if action != BatchAdd {
+		return v.batchSiteExclusions(ctx, action, domains, opts)
+	}
+	valid, results := validSiteExclusions(domains)
+	report, err := v.batchSiteExclusions(ctx, action, valid, opts)
+	// Put the CLI outcomes back between the invalid entries.
+	next := 0
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = report.Results[next]
+			next++
+		}
+	}
+	report.Results = results
+	return report, err
+}
+
+func (v *VPNManager) batchSiteExclusions(ctx context.Context, action BatchAction, domains []string, opts BatchOptions) (BatchReport, error) {
+	report := BatchReport{Action: action, Results: make([]BatchResult, len(domains))}
+	for i, domain := range domains {
+		report.Results[i].Domain = domain
+	}
+	if len(domains) == 0 {
+		return report, nil
+	}
+
+	var release func()
+	err := fmt.Errorf("unknown batch action %q", action)
+	if action == BatchAdd || action == BatchRemove {
+		release, err = v.exclusive(ctx, string(action)+" site exclusions", "")
+	}
+	if err != nil {
+		for i := range report.Results {
+			report.Results[i].Err = err
+		}
+		return report, err
+	}
+	defer release()
+
+	b := &exclusionsBatch{
+		v:       v,
+		action:  action,
+		opts:    opts.withDefaults(),
+		results: report.Results,
+		settled: make([]bool, len(domains)),
+		multi:   make([]bool, len(domains)),
+	}
+	b.run(ctx)
+	b.verify(ctx)
+
+	if applied := report.Applied(); len(applied) > 0 {
+		ev := ExclusionsChanged{Mode: v.SiteExclusionsMode()}
+		if action == BatchAdd {
+			ev.Added = applied
+		} else {
+			ev.Removed = applied
+		}
+		v.events.publish(ev)
+	}
+	return report, nil
+}
+
+type exclusionsBatch struct {
+	v       *VPNManager
+	action  BatchAction
+	opts    BatchOptions
+	results []BatchResult
+	// single is set once the CLI turned out to take one domain per call.
+	single atomic.Bool
+
+	mx sync.Mutex
+	// settled marks the results that are final; multi marks the ones
+	// applied by a call with several domains, verified after the run.
+	settled []bool
+	multi   []bool
+	done    int
+}
+
+func (b *exclusionsBatch) run(ctx context.Context) {
+	chunks := make(chan []int)
+	var wg sync.WaitGroup
+	workers := min(b.opts.Workers, (len(b.results)+b.opts.ChunkSize-1)/b.opts.ChunkSize)
+	for range workers {
+		wg.Go(func() {
+			for chunk := range chunks {
+				b.runChunk(ctx, chunk)
+			}
+		})
+	}
+
+feed:
+	for start := 0; start < len(b.results); start += b.opts.ChunkSize {
+		chunk := make([]int, 0, b.opts.ChunkSize)
+		for i := start; i < min(start+b.opts.ChunkSize, len(b.results)); i++ {
+			chunk = append(chunk, i)
+		}
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	for i := range b.results {
+		if !b.settled[i] {
+			b.settle(i, ErrBatchCanceled, false)
+		}
+	}
+}
+
+func (b *exclusionsBatch) runChunk(ctx context.Context, chunk []int) {
+	multiFailed := false
+	if len(chunk) > 1 && !b.single.Load() {
+		err := b.call(ctx, b.domains(chunk)...)
+		if err == nil {
+			for _, i := range chunk {
+				b.settle(i, nil, true)
+			}
+			return
+		}
+		if ctx.Err() != nil || sharedFailure(err) {
+			for _, i := range chunk {
+				b.settle(i, b.canceledOr(ctx, err), false)
+			}
+			return
+		}
+		multiFailed = true
+	}
+
+	allApplied := true
+	for _, i := range chunk {
+		if ctx.Err() != nil {
+			b.settle(i, ErrBatchCanceled, false)
+			allApplied = false
+			continue
+		}
+		err := b.call(ctx, b.results[i].Domain)
+		b.settle(i, b.canceledOr(ctx, err), false)
+		allApplied = allApplied && err == nil
+	}
+	if multiFailed && allApplied && !b.single.Swap(true) {
+		fmt.Printf("site-exclusions %s takes one domain per call, batching disabled\n", b.action)
+	}
+}
+
+// call runs one CLI invocation for domains and retries transient failures.
+func (b *exclusionsBatch) call(ctx context.Context, domains ...string) error {
+	args := append([]string{"site-exclusions", string(b.action)}, domains...)
+	for attempt := 1; ; attempt++ {
+		output, err := b.v.runCommand(ctx, args, nil)
+		if err == nil {
+			return nil
+		}
+		cliErr := newCLIError(string(b.action)+" site exclusions", err, output)
+		if attempt >= b.opts.Attempts || !transientFailure(cliErr, output) || !sleepCtx(ctx, b.opts.RetryDelay) {
+			return cliErr
+		}
+	}
+}
+
+// canceledOr replaces the failure of a call interrupted by the batch cancellation.
+func (b *exclusionsBatch) canceledOr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ErrBatchCanceled
+	}
+	return err
+}
+
+// sharedFailure reports failures that are not about the domains, such as an
+// expired login; splitting the call would only repeat them.
+func sharedFailure(err error) bool {
+	switch ErrorKindOf(err) {
+	case ErrorNotLoggedIn, ErrorSubscriptionExpired, ErrorDeviceLimit, ErrorSudoDenied, ErrorCLIMissing:
+		return true
+	default:
+		return false
+	}
+}
+
+func transientFailure(err *CLIError, output string) bool {
+	if err.Kind == ErrorTimeout {
+		return true
+	}
+	if err.Kind != ErrorOther {
+		return false
+	}
+	lower := strings.ToLower(output)
+	for _, fragment := range transientFragments {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// verify checks the domains applied by calls with several domains against
+// the CLI list, since a CLI taking one domain may ignore the others without
+// failing. Unconfirmed domains are applied again one at a time.
+func (b *exclusionsBatch) verify(ctx context.Context) {
+	var pending []int
+	for i := range b.results {
+		if b.multi[i] {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 || ctx.Err() != nil {
+		return
+	}
+	current, err := b.v.readSiteExclusions(ctx)
+	if err != nil {
+		fmt.Printf("site-exclusions %s: batch not verified: %v\n", b.action, err)
+		return
+	}
+	listed := make(map[string]bool, len(current))
+	for _, domain := range current {
+		listed[strings.ToLower(domain)] = true
+	}
+	for _, i := range pending {
+		if listed[strings.ToLower(b.results[i].Domain)] == (b.action == BatchAdd) {
+			continue
+		}
+		if !b.single.Swap(true) {
+			fmt.Printf("site-exclusions %s ignored extra domains, batching disabled\n", b.action)
+		}
+		err := ErrBatchCanceled
+		if ctx.Err() == nil {
+			err = b.canceledOr(ctx, b.call(ctx, b.results[i].Domain))
+		}
+		b.results[i].Err = err
+	}
+}
+
+func (b *exclusionsBatch) domains(chunk []int) []string {
+	result := make([]string, len(chunk))
+	for n, i := range chunk {
+		result[n] = b.results[i].Domain
+	}
+	return result
+}
+
+func (b *exclusionsBatch) settle(i int, err error, multi bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.results[i].Err = err
+	b.settled[i] = true
+	b.multi[i] = multi && err == nil
+	b.done++
+	if b.opts.OnProgress != nil {
+		b.opts.OnProgress(b.done, len(b.results))
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/domains"
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch site exclusions", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-exclusions-batch-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	failed := func(report commands.BatchReport) []string {
+		var result []string
+		for _, res := range report.Failed() {
+			result = append(result, res.Domain)
+		}
+		return result
+	}
+
+	It("passes chunks of domains to parallel CLI calls", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.com", "b.com"}, Output: "a.com, b.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "c.com", "d.com"}, Output: "c.com, d.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "e.com"}, Output: "e.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\na.com\nb.com\nc.com\nd.com\ne.com\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		var progress []int
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd,
+			[]string{"a.com", "b.com", "c.com", "d.com", "e.com", "A.com"},
+			commands.BatchOptions{Workers: 3, ChunkSize: 2, OnProgress: func(done, total int) {
+				Expect(total).To(Equal(5))
+				progress = append(progress, done)
+			}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"a.com", "b.com", "c.com", "d.com", "e.com"}))
+		Expect(report.Failed()).To(BeEmpty())
+		Expect(progress).To(Equal([]int{1, 2, 3, 4, 5}))
+		Expect(exclusionCommands(mgr)).To(ConsistOf("add a.com b.com", "add c.com d.com", "add e.com", "show"))
+		Expect(drainEvents[commands.ExclusionsChanged](events)).To(Equal([]commands.ExclusionsChanged{{
+			Mode:  commands.SiteExclusionModeGeneral,
+			Added: []string{"a.com", "b.com", "c.com", "d.com", "e.com"},
+		}}))
+	})
+
+	It("splits a failed chunk to report the failing domain", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "remove", "a.com", "b.com"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "remove", "a.com"}, Output: "a.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "remove", "b.com"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "remove", "c.com", "d.com"}, Output: "c.com, d.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\nb.com\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchRemove,
+			[]string{"a.com", "b.com", "c.com", "d.com"}, commands.BatchOptions{Workers: 1, ChunkSize: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"a.com", "c.com", "d.com"}))
+		Expect(failed(report)).To(Equal([]string{"b.com"}))
+		Expect(report.Failed()[0].Err).To(MatchError("failed to remove site exclusions: Failed to update site exclusions"))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{
+			"remove a.com b.com", "remove a.com", "remove b.com", "remove c.com d.com", "show",
+		}))
+	})
+
+	It("falls back to single calls when the CLI ignores extra domains", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.com", "b.com"}, Output: "a.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\na.com\n"},
+			{Args: []string{"site-exclusions", "add", "b.com"}, Output: "b.com added to exclusions\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd,
+			[]string{"a.com", "b.com"}, commands.BatchOptions{ChunkSize: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"a.com", "b.com"}))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"add a.com b.com", "show", "add b.com"}))
+	})
+
+	It("retries transient failures", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.com"}, Output: "Settings are busy, try again later\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "add", "a.com"}, Output: "a.com added to exclusions\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd,
+			[]string{"a.com"}, commands.BatchOptions{RetryDelay: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"a.com"}))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"add a.com", "add a.com"}))
+	})
+
+	It("does not split a chunk that failed for every domain", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.com", "b.com"}, Output: "You are not logged in\n", ExitCode: 1},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd,
+			[]string{"a.com", "b.com"}, commands.BatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed(report)).To(Equal([]string{"a.com", "b.com"}))
+		Expect(commands.ErrorKindOf(report.Failed()[1].Err)).To(Equal(commands.ErrorNotLoggedIn))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"add a.com b.com"}))
+	})
+
+	It("skips the remaining domains when cancelled", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "a.com"}, Output: "a.com added to exclusions\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		report, err := mgr.BatchSiteExclusions(ctx, commands.BatchAdd,
+			[]string{"a.com", "b.com", "c.com"}, commands.BatchOptions{Workers: 1, ChunkSize: 1,
+				OnProgress: func(done, _ int) {
+					if done == 1 {
+						cancel()
+					}
+				}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"a.com"}))
+		Expect(report.Skipped()).To(Equal([]string{"b.com", "c.com"}))
+		Expect(report.Failed()).To(BeEmpty())
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"add a.com"}))
+		Expect(drainEvents[commands.ExclusionsChanged](events)).To(HaveLen(1))
+	})
+
+	It("sends Unicode domains in punycode and rejects invalid ones", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "add", "xn--e1afmkfd.xn--p1ai"}, Output: "xn--e1afmkfd.xn--p1ai added to exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "*.example.com", "xn--bcher-kva.example"}, Output: "added to exclusions\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\n*.example.com\nxn--bcher-kva.example\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		Expect(mgr.AddSiteExclusion(context.Background(), "https://Пример.рф/path")).To(Succeed())
+		err := mgr.AddSiteExclusion(context.Background(), "bad_host.example.com")
+		Expect(err).To(MatchError(domains.ErrBadCharacter))
+
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd,
+			[]string{"*.Example.com", "not a domain", "bücher.example", "10.0.0.1"}, commands.BatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal([]string{"*.example.com", "xn--bcher-kva.example"}))
+		Expect(failed(report)).To(Equal([]string{"not a domain", "10.0.0.1"}))
+		Expect(report.Failed()[1].Err).To(MatchError(domains.ErrIPAddress))
+		var order []string
+		for _, res := range report.Results {
+			order = append(order, res.Domain)
+		}
+		Expect(order).To(Equal([]string{"*.example.com", "not a domain", "xn--bcher-kva.example", "10.0.0.1"}))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{
+			"add xn--e1afmkfd.xn--p1ai", "add *.example.com xn--bcher-kva.example", "show",
+		}))
+	})
+})
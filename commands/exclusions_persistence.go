@@ -170,6 +170,7 @@ func SaveExclusionsForMode(mode SiteExclusionMode, domains []string) error {
 
 // NormalizeDomains normalizes the domain list by trimming spaces, removing empty lines,
 // and deduplicating them in a case-insensitive manner while preserving the case of the first occurrence.
+// A Unicode name and its punycode form count as duplicates.
 func NormalizeDomains(domains []string) []string {
 	seen := make(map[string]bool)
 	var result []string
@@ -178,9 +179,9 @@ func NormalizeDomains(domains []string) []string {
 		if trimmed == "" {
 			continue
 		}
-		lower := strings.ToLower(trimmed)
-		if !seen[lower] {
-			seen[lower] = true
+		key := exclusionKey(trimmed)
+		if !seen[key] {
+			seen[key] = true
 			result = append(result, trimmed)
 		}
 	}
@@ -0,0 +1,279 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExclusionsSwitchError reports a mode switch that failed after the CLI had
+// entered the target mode. Added and Removed list exactly the domains applied
+// to the target mode before Err; the switch was rolled back to the previous
+// mode and list unless RollbackErr is set.
+type ExclusionsSwitchError struct {
+	Mode        SiteExclusionMode
+	Added       []string
+	Removed     []string
+	Err         error
+	RollbackErr error
+}
+
+func (e *ExclusionsSwitchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to switch site exclusions to %s mode: %v; applied before the failure: added %s, removed %s",
+		e.Mode, e.Err, domainList(e.Added), domainList(e.Removed))
+	if e.RollbackErr != nil {
+		fmt.Fprintf(&b, "; rollback failed: %v", e.RollbackErr)
+	} else {
+		b.WriteString("; rolled back")
+	}
+	return b.String()
+}
+
+func (e *ExclusionsSwitchError) Unwrap() error {
+	return e.Err
+}
+
+func domainList(domains []string) string {
+	if len(domains) == 0 {
+		return "none"
+	}
+	return strings.Join(domains, ", ")
+}
+
+// DiffExclusions returns the domains to add and to remove so that current
+// becomes desired. Domains compare case-insensitively and Unicode names match
+// their punycode form; the order of the inputs is kept.
+func DiffExclusions(current, desired []string) (add, remove []string) {
+	current = NormalizeDomains(current)
+	desired = NormalizeDomains(desired)
+	have := make(map[string]bool, len(current))
+	for _, domain := range current {
+		have[exclusionKey(domain)] = true
+	}
+	want := make(map[string]bool, len(desired))
+	for _, domain := range desired {
+		want[exclusionKey(domain)] = true
+		if !have[exclusionKey(domain)] {
+			add = append(add, domain)
+		}
+	}
+	for _, domain := range current {
+		if !want[exclusionKey(domain)] {
+			remove = append(remove, domain)
+		}
+	}
+	return add, remove
+}
+
+// exclusionsTx applies exclusion changes one CLI command at a time and
+// remembers them, so they can be undone in reverse order.
+type exclusionsTx struct {
+	v       *VPNManager
+	added   []string
+	removed []string
+	// undo holds the inverse of every applied step, latest last.
+	undo []func(ctx context.Context) error
+}
+
+// apply removes and then adds domains, stopping at the first failure.
+func (tx *exclusionsTx) apply(ctx context.Context, add, remove []string) error {
+	for _, domain := range remove {
+		if err := tx.v.removeSiteExclusion(ctx, domain); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", domain, err)
+		}
+		tx.removed = append(tx.removed, domain)
+		tx.undo = append(tx.undo, func(ctx context.Context) error {
+			if err := tx.v.addSiteExclusion(ctx, domain); err != nil {
+				return fmt.Errorf("failed to undo removing %s: %w", domain, err)
+			}
+			return nil
+		})
+	}
+	for _, entry := range add {
+		domain, err := siteExclusionDomain(entry)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", entry, err)
+		}
+		if err := tx.v.addSiteExclusion(ctx, domain); err != nil {
+			return fmt.Errorf("failed to add %s: %w", domain, err)
+		}
+		tx.added = append(tx.added, domain)
+		tx.undo = append(tx.undo, func(ctx context.Context) error {
+			if err := tx.v.removeSiteExclusion(ctx, domain); err != nil {
+				return fmt.Errorf("failed to undo adding %s: %w", domain, err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// rollback undoes the applied steps, latest first. It keeps going after a
+// failed step and returns all failures.
+func (tx *exclusionsTx) rollback(ctx context.Context) error {
+	var errs []error
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetSiteExclusionsMode switches the site exclusions mode in AdGuard VPN.
+// The domains of the previous mode are saved to its file. After the CLI
+// switches, its list is brought to the saved list of the target mode with the
+// minimal set of adds and removes; a target mode that was never saved keeps
+// the CLI list. When a step fails, the applied steps are undone and the
+// previous mode and list are restored; the returned *ExclusionsSwitchError
+// names the domains that had been applied. An active exclusion set of the
+// other mode stops being active.
+func (v *VPNManager) SetSiteExclusionsMode(ctx context.Context, mode SiteExclusionMode, domains []string) error {
+	release, err := v.exclusive(ctx, "switch site exclusions mode", "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	prevMode := v.SiteExclusionsMode()
+
+	if err := SaveExclusionsForMode(prevMode, domains); err != nil {
+		return fmt.Errorf("failed to save exclusions for previous mode %s: %w", prevMode, err)
+	}
+
+	desired, err := LoadExclusionsForMode(mode)
+	if err != nil {
+		return fmt.Errorf("failed to load exclusions for target mode %s: %w", mode, err)
+	}
+	saved, err := hasSavedExclusions(mode)
+	if err != nil {
+		return err
+	}
+
+	if err := v.switchExclusions(ctx, prevMode, domains, mode, desired, !saved); err != nil {
+		return err
+	}
+	if _, _, err := activeExclusionSetFor(mode); err != nil {
+		fmt.Printf("failed to update active exclusion set: %v\n", err)
+	}
+	return nil
+}
+
+// switchExclusions switches the CLI to mode and brings its list to desired,
+// or keeps the CLI list and saves it for mode when adopt is set. A failure
+// restores prevMode with prevDomains. The caller holds the exclusive section.
+func (v *VPNManager) switchExclusions(ctx context.Context, prevMode SiteExclusionMode, prevDomains []string, mode SiteExclusionMode, desired []string, adopt bool) error {
+	if err := v.setSiteExclusionsMode(ctx, mode); err != nil {
+		return err
+	}
+
+	tx := &exclusionsTx{v: v}
+	current, err := v.readSiteExclusions(ctx)
+	if err == nil {
+		if adopt {
+			desired = current
+			err = SaveExclusionsForMode(mode, desired)
+		} else {
+			add, remove := DiffExclusions(current, desired)
+			err = tx.apply(ctx, add, remove)
+		}
+	}
+	if err != nil {
+		return &ExclusionsSwitchError{
+			Mode:        mode,
+			Added:       tx.added,
+			Removed:     tx.removed,
+			Err:         err,
+			RollbackErr: v.rollbackExclusionsSwitch(ctx, tx, prevMode, prevDomains),
+		}
+	}
+
+	v.statemx.Lock()
+	v.siteExclusionsMode = mode
+	v.statemx.Unlock()
+	v.events.publish(ExclusionsChanged{Mode: mode, Added: NormalizeDomains(desired), Removed: prevDomains})
+	return nil
+}
+
+// rollbackExclusionsSwitch undoes tx and returns the CLI to prevMode with the
+// prevDomains list. It runs even when ctx is cancelled, since the switch
+// may have failed because of it; every command keeps its own timeout.
+func (v *VPNManager) rollbackExclusionsSwitch(ctx context.Context, tx *exclusionsTx, prevMode SiteExclusionMode, prevDomains []string) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	if err := tx.rollback(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := v.setSiteExclusionsMode(ctx, prevMode); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	v.statemx.Lock()
+	v.siteExclusionsMode = prevMode
+	v.statemx.Unlock()
+
+	// Modes normally keep separate lists in the CLI, so this is a check,
+	// but it also repairs the list if the CLI shares one between modes.
+	current, err := v.readSiteExclusions(ctx)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	add, remove := DiffExclusions(current, prevDomains)
+	restore := &exclusionsTx{v: v}
+	if err := restore.apply(ctx, add, remove); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (v *VPNManager) setSiteExclusionsMode(ctx context.Context, mode SiteExclusionMode) error {
+	output, err := v.executeCommand(ctx, "site-exclusions", "mode", mode.String())
+	if err != nil {
+		return fmt.Errorf("site-exclusions mode %s failed: %w, output: %s", mode, err, output)
+	}
+	return nil
+}
+
+// readSiteExclusions lists the exclusions of the current CLI mode. Unlike
+// GetSiteExclusions, it never joins a show that started before the changes
+// made by the caller.
+func (v *VPNManager) readSiteExclusions(ctx context.Context) ([]string, error) {
+	output, err := v.runCommand(ctx, []string{"site-exclusions", "show"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("site-exclusions show failed: %w, output: %s", err, output)
+	}
+	_, domains := parseSiteExclusions(output)
+	return domains, nil
+}
+
+// hasSavedExclusions reports whether adgui has saved a list for mode, even an empty one.
+func hasSavedExclusions(mode SiteExclusionMode) (bool, error) {
+	path, err := GetExclusionsFilePath(mode)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check exclusions file: %w", err)
+	}
+	return true, nil
+}
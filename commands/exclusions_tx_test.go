@@ -0,0 +1,175 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// exclusionCommands returns the site-exclusions commands run by mgr, oldest first.
+func exclusionCommands(mgr *commands.VPNManager) []string {
+	var result []string
+	for _, entry := range mgr.CommandLog() {
+		if len(entry.Args) > 0 && entry.Args[0] == "site-exclusions" {
+			result = append([]string{strings.Join(entry.Args[1:], " ")}, result...)
+		}
+	}
+	return result
+}
+
+var _ = Describe("Site exclusions mode switch", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-exclusions-tx-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	DescribeTable("diffs exclusion lists",
+		func(current, desired, add, remove []string) {
+			gotAdd, gotRemove := commands.DiffExclusions(current, desired)
+			Expect(gotAdd).To(Equal(add))
+			Expect(gotRemove).To(Equal(remove))
+		},
+		Entry("identical lists", []string{"a.com", "b.com"}, []string{"b.com", "a.com"}, nil, nil),
+		Entry("disjoint lists", []string{"a.com"}, []string{"b.com"}, []string{"b.com"}, []string{"a.com"}),
+		Entry("case differences", []string{"Example.com"}, []string{"example.COM", "x.org"}, []string{"x.org"}, nil),
+		Entry("empty target", []string{"a.com", "b.com"}, nil, nil, []string{"a.com", "b.com"}),
+		Entry("Unicode and punycode", []string{"xn--e1afmkfd.xn--p1ai"}, []string{"Пример.рф", "b.com"}, []string{"b.com"}, nil),
+	)
+
+	It("applies only the difference to the saved list", func() {
+		Expect(commands.SaveExclusionsForMode(commands.SiteExclusionModeSelective, []string{"x.com", "y.com"})).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\ny.com\nz.com\n"},
+			{Args: []string{"site-exclusions", "remove", "z.com"}, Output: "z.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "x.com"}, Output: "x.com added to exclusions\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		Expect(mgr.SetSiteExclusionsMode(context.Background(), commands.SiteExclusionModeSelective, []string{"a.com"})).To(Succeed())
+		Expect(mgr.SiteExclusionsMode()).To(Equal(commands.SiteExclusionModeSelective))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"mode selective", "show", "remove z.com", "add x.com"}))
+		Expect(drainEvents[commands.ExclusionsChanged](events)).To(Equal([]commands.ExclusionsChanged{{
+			Mode:    commands.SiteExclusionModeSelective,
+			Added:   []string{"x.com", "y.com"},
+			Removed: []string{"a.com"},
+		}}))
+
+		saved, err := commands.LoadExclusionsForMode(commands.SiteExclusionModeGeneral)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(Equal([]string{"a.com"}))
+	})
+
+	It("keeps the CLI list of a mode that was never saved", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\nz.com\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		Expect(mgr.SetSiteExclusionsMode(context.Background(), commands.SiteExclusionModeSelective, nil)).To(Succeed())
+		Expect(exclusionCommands(mgr)).To(Equal([]string{"mode selective", "show"}))
+		saved, err := commands.LoadExclusionsForMode(commands.SiteExclusionModeSelective)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(Equal([]string{"z.com"}))
+	})
+
+	It("rolls back to the previous mode and list when a step fails", func() {
+		Expect(commands.SaveExclusionsForMode(commands.SiteExclusionModeSelective, []string{"x.com", "y.com"})).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\nz.com\n"},
+			{Args: []string{"site-exclusions", "remove", "z.com"}, Output: "z.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "x.com"}, Output: "x.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "y.com"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "remove", "x.com"}, Output: "x.com removed from exclusions\n"},
+			{Args: []string{"site-exclusions", "add", "z.com"}, Output: "z.com added to exclusions\n"},
+			{Args: []string{"site-exclusions", "mode", "general"}, Output: "Exclusions mode set to GENERAL\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\na.com\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+
+		err := mgr.SetSiteExclusionsMode(context.Background(), commands.SiteExclusionModeSelective, []string{"a.com"})
+		var switchErr *commands.ExclusionsSwitchError
+		Expect(errors.As(err, &switchErr)).To(BeTrue())
+		Expect(switchErr.Mode).To(Equal(commands.SiteExclusionModeSelective))
+		Expect(switchErr.Added).To(Equal([]string{"x.com"}))
+		Expect(switchErr.Removed).To(Equal([]string{"z.com"}))
+		Expect(switchErr.RollbackErr).NotTo(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to add y.com"))
+		Expect(err.Error()).To(ContainSubstring("applied before the failure: added x.com, removed z.com; rolled back"))
+
+		Expect(mgr.SiteExclusionsMode()).To(Equal(commands.SiteExclusionModeGeneral))
+		Expect(exclusionCommands(mgr)).To(Equal([]string{
+			"mode selective", "show", "remove z.com", "add x.com", "add y.com",
+			"remove x.com", "add z.com", "mode general", "show",
+		}))
+		Expect(drainEvents[commands.ExclusionsChanged](events)).To(BeEmpty())
+	})
+
+	It("reports a rollback that could not restore the previous mode", func() {
+		Expect(commands.SaveExclusionsForMode(commands.SiteExclusionModeSelective, []string{"x.com"})).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "mode", "selective"}, Output: "Exclusions mode set to SELECTIVE\n"},
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for SELECTIVE mode:\n"},
+			{Args: []string{"site-exclusions", "add", "x.com"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+			{Args: []string{"site-exclusions", "mode", "general"}, Output: "Failed to update site exclusions\n", ExitCode: 1},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		err := mgr.SetSiteExclusionsMode(context.Background(), commands.SiteExclusionModeSelective, []string{"a.com"})
+		var switchErr *commands.ExclusionsSwitchError
+		Expect(errors.As(err, &switchErr)).To(BeTrue())
+		Expect(switchErr.Added).To(BeEmpty())
+		Expect(switchErr.Removed).To(BeEmpty())
+		Expect(switchErr.RollbackErr).To(MatchError(ContainSubstring("site-exclusions mode general failed")))
+		Expect(err.Error()).To(ContainSubstring("added none, removed none; rollback failed"))
+	})
+})
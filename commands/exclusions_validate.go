@@ -0,0 +1,56 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"adgui/domains"
+)
+
+// siteExclusionDomain checks an entry before it goes to the CLI and returns
+// the form the CLI gets: a URL is reduced to its host and an
+// internationalized name is converted to punycode.
+func siteExclusionDomain(entry string) (string, error) {
+	domain, err := domains.Normalize(entry)
+	if err != nil {
+		return "", fmt.Errorf("invalid site exclusion %q: %w", strings.TrimSpace(entry), err)
+	}
+	return domain, nil
+}
+
+// validSiteExclusions converts the valid entries of list. The results follow
+// list: the invalid entries fail, the valid ones are left for the CLI.
+func validSiteExclusions(list []string) ([]string, []BatchResult) {
+	var valid []string
+	results := make([]BatchResult, len(list))
+	for i, entry := range list {
+		domain, err := siteExclusionDomain(entry)
+		if err != nil {
+			results[i] = BatchResult{Domain: entry, Err: err}
+			continue
+		}
+		valid = append(valid, domain)
+	}
+	return valid, results
+}
+
+// exclusionKey is the form entries are compared in, so that a Unicode name
+// in a saved list matches the punycode name listed by the CLI.
+func exclusionKey(entry string) string {
+	return domains.Key(entry)
+}
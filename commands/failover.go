@@ -0,0 +1,307 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"adgui/config"
+	"adgui/locations"
+)
+
+const failoverFile = "failover"
+
+// FailoverStep picks the next location after a failed connect.
+type FailoverStep string
+
+const (
+	// FailoverBookmarks tries the next bookmarked location.
+	FailoverBookmarks FailoverStep = "bookmarks"
+	// FailoverCountry tries the next-fastest location in the country of the first attempt.
+	FailoverCountry FailoverStep = "country"
+	// FailoverAuto lets adguardvpn-cli pick the location.
+	FailoverAuto FailoverStep = "auto"
+)
+
+// DefaultFailoverSteps returns the chain used when neither adguirc nor the UI set one.
+func DefaultFailoverSteps() []FailoverStep {
+	return []FailoverStep{FailoverBookmarks, FailoverCountry, FailoverAuto}
+}
+
+// ParseFailoverSteps parses a comma-separated chain such as "bookmarks,country,auto".
+// Each item is one attempt, so a step may repeat. "off" or "none" disables failover.
+func ParseFailoverSteps(value string) ([]FailoverStep, error) {
+	steps := []FailoverStep{}
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch FailoverStep(item) {
+		case FailoverBookmarks, FailoverCountry, FailoverAuto:
+			steps = append(steps, FailoverStep(item))
+		case "", "off", "none":
+		default:
+			return nil, fmt.Errorf("unknown failover step %q", item)
+		}
+	}
+	return steps, nil
+}
+
+// FormatFailoverSteps renders steps in the ParseFailoverSteps syntax.
+func FormatFailoverSteps(steps []FailoverStep) string {
+	if len(steps) == 0 {
+		return "off"
+	}
+	items := make([]string, len(steps))
+	for i, step := range steps {
+		items[i] = string(step)
+	}
+	return strings.Join(items, ",")
+}
+
+// GetFailoverPath returns the file keeping the failover chain chosen in the UI.
+func GetFailoverPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", locationBookmarksDir, failoverFile), nil
+}
+
+// LoadFailoverSteps returns the chain saved by the UI; ok is false when none was saved.
+func LoadFailoverSteps() ([]FailoverStep, bool, error) {
+	path, err := GetFailoverPath()
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read failover chain: %w", err)
+	}
+	steps, err := ParseFailoverSteps(string(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse failover chain: %w", err)
+	}
+	return steps, true, nil
+}
+
+// SaveFailoverSteps stores the chain chosen in the UI.
+func SaveFailoverSteps(steps []FailoverStep) error {
+	path, err := GetFailoverPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(FormatFailoverSteps(steps)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write failover chain: %w", err)
+	}
+	return nil
+}
+
+// loadFailoverSteps resolves the chain: ADGUARD_FAILOVER from the environment or
+// adguirc, then the chain saved by the UI, then DefaultFailoverSteps.
+func loadFailoverSteps() ([]FailoverStep, error) {
+	value, err := config.AdguardFailover()
+	if err != nil {
+		return DefaultFailoverSteps(), err
+	}
+	if value != "" {
+		steps, err := ParseFailoverSteps(value)
+		if err != nil {
+			return DefaultFailoverSteps(), fmt.Errorf("invalid ADGUARD_FAILOVER value %q: %w", value, err)
+		}
+		return steps, nil
+	}
+	steps, ok, err := LoadFailoverSteps()
+	if err != nil || !ok {
+		return DefaultFailoverSteps(), err
+	}
+	return steps, nil
+}
+
+// SetFailoverSteps replaces the failover chain of ConnectWithFailover.
+func (v *VPNManager) SetFailoverSteps(steps []FailoverStep) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.failover = append([]FailoverStep(nil), steps...)
+}
+
+// FailoverSteps returns the failover chain of ConnectWithFailover.
+func (v *VPNManager) FailoverSteps() []FailoverStep {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return append([]FailoverStep(nil), v.failover...)
+}
+
+// ConnectWithFailover connects to loc and, when that fails for a reason another
+// location may avoid, walks the failover chain: each step is one more attempt.
+// Every switch publishes FailoverAttempt, failed attempts land in the history
+// and the session that finally connects notes how it was reached. The error of
+// the last attempt is returned when the chain is exhausted.
+func (v *VPNManager) ConnectWithFailover(ctx context.Context, loc locations.Location) error {
+	v.stopWatchdog()
+	err := v.connectToLocation(ctx, loc)
+	if err == nil {
+		return nil
+	}
+
+	tried := map[string]bool{locationKey(loc): true}
+	current := loc
+	attempt := 0
+	for _, step := range v.FailoverSteps() {
+		if !failoverWorthy(err) || ctx.Err() != nil {
+			return err
+		}
+		next, ok := v.failoverCandidate(ctx, step, loc, tried)
+		if !ok {
+			continue
+		}
+		attempt++
+		failure := failureMessage(err)
+		v.recordFailedAttempt(current, "connect failed: "+failure)
+		v.events.publish(FailoverAttempt{Attempt: attempt, Step: step, Failed: current, Failure: failure, Location: next})
+		fmt.Printf("failover: connect to %s failed (%s), trying %s\n", describeLocation(current), failure, describeLocation(next))
+
+		if step == FailoverAuto {
+			err = v.connectAuto(ctx)
+		} else {
+			tried[locationKey(next)] = true
+			err = v.connectToLocation(ctx, next)
+		}
+		if err == nil {
+			v.annotateActiveConnection(fmt.Sprintf("failover attempt %d (%s) after %s failed", attempt, step, describeLocation(loc)))
+			return nil
+		}
+		current = next
+	}
+	if attempt > 0 {
+		v.recordFailedAttempt(current, "connect failed: "+failureMessage(err))
+	}
+	return err
+}
+
+// failoverWorthy reports whether another location might succeed where err failed.
+// Login, subscription, device limit and elevation problems follow the account
+// or the machine; a cancellation is the user's choice.
+func failoverWorthy(err error) bool {
+	var cliErr *CLIError
+	if !errors.As(err, &cliErr) {
+		return false
+	}
+	switch cliErr.Kind {
+	case ErrorLocationUnknown, ErrorTimeout, ErrorOther:
+		return true
+	default:
+		return false
+	}
+}
+
+func (v *VPNManager) failoverCandidate(ctx context.Context, step FailoverStep, first locations.Location, tried map[string]bool) (locations.Location, bool) {
+	switch step {
+	case FailoverAuto:
+		return locations.Location{}, true
+	case FailoverBookmarks:
+		bookmarks, err := LoadLocationBookmarks()
+		if err != nil {
+			fmt.Printf("failover: %v\n", err)
+			return locations.Location{}, false
+		}
+		// Start after the failed location when it is itself a bookmark.
+		start := 0
+		for i, bookmark := range bookmarks {
+			if LocationBookmarkKey(bookmark.ISO, bookmark.Country, bookmark.City) == locationKey(first) {
+				start = i + 1
+				break
+			}
+		}
+		for i := range bookmarks {
+			bookmark := bookmarks[(start+i)%len(bookmarks)]
+			loc := locations.Location{ISO: bookmark.ISO, Country: bookmark.Country, City: bookmark.City}
+			if !tried[locationKey(loc)] {
+				return loc, true
+			}
+		}
+	case FailoverCountry:
+		locs, err := v.ListLocations(ctx)
+		if err != nil {
+			fmt.Printf("failover: %v\n", err)
+			return locations.Location{}, false
+		}
+		var country []locations.Location
+		for _, loc := range locs {
+			if sameCountry(loc, first) && !tried[locationKey(loc)] {
+				country = append(country, loc)
+			}
+		}
+		sort.SliceStable(country, func(i, j int) bool { return country[i].Ping < country[j].Ping })
+		if len(country) > 0 {
+			return country[0], true
+		}
+	}
+	return locations.Location{}, false
+}
+
+// recordFailedAttempt notes a failed connect to loc; an attempt left to the
+// CLI is named "the best location".
+func (v *VPNManager) recordFailedAttempt(loc locations.Location, reason string) {
+	v.appendHistoryEntry(ConnectionHistoryEntry{
+		City:    describeLocation(loc),
+		Country: loc.Country,
+		Ping:    loc.Ping,
+		Reason:  reason,
+	})
+}
+
+func sameCountry(a, b locations.Location) bool {
+	if a.ISO != "" && b.ISO != "" {
+		return strings.EqualFold(a.ISO, b.ISO)
+	}
+	return a.Country != "" && strings.EqualFold(a.Country, b.Country)
+}
+
+func locationKey(loc locations.Location) string {
+	return LocationBookmarkKey(loc.ISO, loc.Country, loc.City)
+}
+
+func describeLocation(loc locations.Location) string {
+	if loc.City == "" {
+		return "the best location"
+	}
+	return loc.City
+}
+
+// failureMessage describes a failed connect for the history: the CLI message,
+// or the error itself, or "not connected" when the connect returned without
+// an error but the tunnel is down.
+func failureMessage(err error) string {
+	if err == nil {
+		return "not connected"
+	}
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) {
+		return cliErr.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connect failover", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+		oldFailover string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-failover-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		oldFailover = os.Getenv("ADGUARD_FAILOVER")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+		Expect(os.Unsetenv("ADGUARD_FAILOVER")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		if oldFailover != "" {
+			_ = os.Setenv("ADGUARD_FAILOVER", oldFailover)
+		} else {
+			_ = os.Unsetenv("ADGUARD_FAILOVER")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+	const locationList = "ISO   COUNTRY   CITY        PING ESTIMATE\n" +
+		"LV    Latvia    Riga        29\n" +
+		"LV    Latvia    Daugavpils  40\n" +
+		"EE    Estonia   Tallinn     35\n"
+
+	DescribeTable("parses failover chains",
+		func(value string, expected []commands.FailoverStep, formatted string) {
+			steps, err := commands.ParseFailoverSteps(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(steps).To(Equal(expected))
+			Expect(commands.FormatFailoverSteps(steps)).To(Equal(formatted))
+		},
+		Entry("default", "bookmarks,country,auto", commands.DefaultFailoverSteps(), "bookmarks,country,auto"),
+		Entry("repeats and spaces", " Bookmarks , bookmarks,auto", []commands.FailoverStep{commands.FailoverBookmarks, commands.FailoverBookmarks, commands.FailoverAuto}, "bookmarks,bookmarks,auto"),
+		Entry("off", "off", []commands.FailoverStep{}, "off"),
+	)
+
+	It("rejects unknown steps", func() {
+		_, err := commands.ParseFailoverSteps("bookmarks,random")
+		Expect(err).To(MatchError(ContainSubstring("random")))
+	})
+
+	It("walks bookmarks and the country until a connect succeeds", func() {
+		Expect(commands.SaveLocationBookmarks([]commands.LocationBookmark{
+			{ISO: "LV", Country: "Latvia", City: "Riga"},
+			{ISO: "EE", Country: "Estonia", City: "Tallinn"},
+		})).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Location RIGA not found\n", ExitCode: 1},
+			{Args: []string{"connect", "-l", "Tallinn"}, Output: "Failed to connect: server did not respond\n", ExitCode: 1},
+			{Args: []string{"connect", "-l", "Daugavpils"}, Output: "Successfully Connected to DAUGAVPILS\n"},
+			{Args: []string{"list-locations"}, Output: locationList},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(64)
+		defer unsubscribe()
+
+		Expect(mgr.ConnectWithFailover(context.Background(), riga)).To(Succeed())
+		Expect(mgr.Location()).To(Equal("Daugavpils"))
+
+		attempts := drainEvents[commands.FailoverAttempt](events)
+		Expect(attempts).To(HaveLen(2))
+		Expect(attempts[0].Step).To(Equal(commands.FailoverBookmarks))
+		Expect(attempts[0].Failed.City).To(Equal("Riga"))
+		Expect(attempts[0].Failure).To(Equal("Location RIGA not found"))
+		Expect(attempts[0].Location.City).To(Equal("Tallinn"))
+		Expect(attempts[1].Attempt).To(Equal(2))
+		Expect(attempts[1].Step).To(Equal(commands.FailoverCountry))
+		Expect(attempts[1].Location.City).To(Equal("Daugavpils"))
+
+		history := mgr.ConnectionHistory()
+		Expect(history).To(HaveLen(3))
+		Expect(history[0].City).To(Equal("Daugavpils"))
+		Expect(history[0].EndedAt).To(BeNil())
+		Expect(history[0].Reason).To(Equal("failover attempt 2 (country) after Riga failed"))
+		Expect(history[1].City).To(Equal("Tallinn"))
+		Expect(history[1].Reason).To(Equal("connect failed: Failed to connect: server did not respond"))
+		Expect(history[2].City).To(Equal("Riga"))
+		Expect(history[2].Reason).To(Equal("connect failed: Location RIGA not found"))
+	})
+
+	It("falls back to the best location and returns the last error when all fail", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Failed to connect: server did not respond\n", ExitCode: 1},
+			{Args: []string{"connect"}, Output: "Failed to connect: no servers available\n", ExitCode: 1},
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetFailoverSteps([]commands.FailoverStep{commands.FailoverBookmarks, commands.FailoverAuto})
+
+		err := mgr.ConnectWithFailover(context.Background(), riga)
+		Expect(err).To(MatchError(ContainSubstring("no servers available")))
+		history := mgr.PreviousConnectionHistory()
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].City).To(Equal("the best location"))
+		Expect(history[0].Country).To(BeEmpty())
+		Expect(history[0].Reason).To(Equal("connect failed: Failed to connect: no servers available"))
+		Expect(history[1].Reason).To(Equal("connect failed: Failed to connect: server did not respond"))
+	})
+
+	It("does not fail over account errors", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "You are not logged in. Please log in first\n", ExitCode: 1},
+		})
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+
+		err := mgr.ConnectWithFailover(context.Background(), riga)
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorNotLoggedIn))
+		Expect(runner.Starts("connect")).To(BeZero())
+		Expect(runner.Starts("list-locations")).To(BeZero())
+		Expect(mgr.PreviousConnectionHistory()).To(BeEmpty())
+	})
+
+	It("takes the chain from adguirc before the one saved by the UI", func() {
+		Expect(commands.SaveFailoverSteps([]commands.FailoverStep{commands.FailoverCountry})).To(Succeed())
+		mgr := commands.New(commands.NewReplayRunner(nil))
+		Expect(mgr.FailoverSteps()).To(Equal([]commands.FailoverStep{commands.FailoverCountry}))
+		_ = mgr.Close()
+
+		Expect(os.Setenv("ADGUARD_FAILOVER", "off")).To(Succeed())
+		mgr = commands.New(commands.NewReplayRunner(nil))
+		defer func() { _ = mgr.Close() }()
+		Expect(mgr.FailoverSteps()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,182 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VPNManager against adguardvpn-cli-fake", Ordered, func() {
+	var (
+		binDir   string
+		fakeBin  string
+		stateDir string
+		tempHome string
+		savedEnv map[string]string
+		mgr      *commands.VPNManager
+	)
+
+	BeforeAll(func() {
+		if _, err := exec.LookPath("go"); err != nil {
+			Skip("go toolchain is required to build the CLI emulator")
+		}
+		var err error
+		binDir, err = os.MkdirTemp("", "adgui-fake-cli-bin-*")
+		Expect(err).NotTo(HaveOccurred())
+		fakeBin = filepath.Join(binDir, "adguardvpn-cli")
+		build := exec.Command("go", "build", "-o", fakeBin, "adgui/cmd/adguardvpn-cli-fake")
+		output, err := build.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(output))
+	})
+
+	AfterAll(func() {
+		_ = os.RemoveAll(binDir)
+	})
+
+	BeforeEach(func() {
+		var err error
+		stateDir, err = os.MkdirTemp("", "adgui-fake-cli-state-*")
+		Expect(err).NotTo(HaveOccurred())
+		tempHome, err = os.MkdirTemp("", "adgui-fake-cli-home-*")
+		Expect(err).NotTo(HaveOccurred())
+
+		savedEnv = make(map[string]string)
+		for key, value := range map[string]string{
+			"HOME":                       tempHome,
+			"ADGUARD_CMD":                fakeBin,
+			"ADGUARD_SUDO_WRAP":          "0",
+			"ADGUARD_FAKE_STATE_DIR":     stateDir,
+			"ADGUARD_FAKE_CONNECT_DELAY": "1s",
+		} {
+			savedEnv[key] = os.Getenv(key)
+			Expect(os.Setenv(key, value)).To(Succeed())
+		}
+		mgr = commands.New(commands.NewExecRunner())
+	})
+
+	AfterEach(func() {
+		mgr.KillAllCommands()
+		_ = mgr.Close()
+		for key, value := range savedEnv {
+			if value != "" {
+				_ = os.Setenv(key, value)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}
+		_ = os.RemoveAll(stateDir)
+		_ = os.RemoveAll(tempHome)
+	})
+
+	setFaults := func(faults string) {
+		Expect(os.WriteFile(filepath.Join(stateDir, "faults"), []byte(faults), 0o600)).To(Succeed())
+	}
+
+	It("picks up an auto connect through the status check loop", func() {
+		Expect(mgr.ConnectAuto(context.Background())).To(Succeed())
+
+		Eventually(mgr.IsConnected, 10*time.Second, 100*time.Millisecond).Should(BeTrue())
+		loc, ok := mgr.ConnectedLocation()
+		Expect(ok).To(BeTrue())
+		Expect(loc.City).To(Equal("Riga"))
+		Expect(loc.Country).To(Equal("Latvia"))
+
+		Expect(mgr.Disconnect(context.Background())).To(Succeed())
+		Expect(mgr.IsConnected()).To(BeFalse())
+		Expect(mgr.PreviousConnectionHistory()).To(HaveLen(1))
+	})
+
+	It("persists exclusions across mode switches", func() {
+		Expect(mgr.AddSiteExclusion(context.Background(), "example.com")).To(Succeed())
+		Expect(mgr.AddSiteExclusion(context.Background(), "example.org")).To(Succeed())
+		mode, domains, err := mgr.GetSiteExclusions(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(commands.SiteExclusionModeGeneral))
+		Expect(domains).To(Equal([]string{"example.com", "example.org"}))
+
+		Expect(mgr.SetSiteExclusionsMode(context.Background(), commands.SiteExclusionModeSelective, domains)).To(Succeed())
+		saved, err := commands.LoadExclusionsForMode(commands.SiteExclusionModeGeneral)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(saved).To(Equal(domains))
+
+		mode, domains, err = mgr.GetSiteExclusions(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(commands.SiteExclusionModeSelective))
+		Expect(domains).To(BeEmpty())
+	})
+
+	It("adds and removes a large list in batches", func() {
+		domains := make([]string, 120)
+		for i := range domains {
+			domains[i] = fmt.Sprintf("site%03d.example.com", i)
+		}
+		report, err := mgr.BatchSiteExclusions(context.Background(), commands.BatchAdd, domains, commands.BatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Applied()).To(Equal(domains))
+		_, listed, err := mgr.GetSiteExclusions(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(ConsistOf(domains))
+
+		report, err = mgr.BatchSiteExclusions(context.Background(), commands.BatchRemove, domains, commands.BatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Failed()).To(BeEmpty())
+		_, listed, err = mgr.GetSiteExclusions(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(BeEmpty())
+	})
+
+	It("stays disconnected when sudo refuses elevation", func() {
+		setFaults("sudo")
+		locs, err := mgr.ListLocations(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(locs).NotTo(BeEmpty())
+
+		err = mgr.ConnectToLocation(context.Background(), locs[0])
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorSudoDenied))
+		Expect(mgr.IsConnected()).To(BeFalse())
+	})
+
+	It("tracks a slow connect in the command queue", func() {
+		setFaults("slow-connect")
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = mgr.ConnectAuto(context.Background())
+		}()
+
+		Eventually(mgr.RunningCommands, 2*time.Second, 20*time.Millisecond).Should(
+			ContainElement(HaveField("Args", Equal([]string{"connect"}))))
+		Eventually(done, 5*time.Second).Should(BeClosed())
+	})
+
+	It("fails every command when the login expired", func() {
+		setFaults("auth")
+		locs, err := mgr.ListLocations(context.Background())
+		Expect(locs).To(BeEmpty())
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorNotLoggedIn))
+		_, _, err = mgr.GetSiteExclusions(context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+})
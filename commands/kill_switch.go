@@ -0,0 +1,175 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"adgui/commands/killswitch"
+	"adgui/config"
+)
+
+const killSwitchStateFile = "killswitch.json"
+
+// GetKillSwitchStatePath returns the file recording installed kill switch rules.
+func GetKillSwitchStatePath() (string, error) {
+	dir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, killSwitchStateFile), nil
+}
+
+// setupKillSwitch builds the kill switch from adguirc. It is created even when
+// disabled so that rules left by a previous run can still be removed.
+func (v *VPNManager) setupKillSwitch() {
+	enabled, err := config.AdguardKillSwitchEnabled()
+	if err != nil {
+		fmt.Printf("config read error for kill switch: %v\n", err)
+	}
+	lanValue, err := config.AdguardKillSwitchLAN()
+	if err != nil {
+		fmt.Printf("config read error for kill switch LAN: %v\n", err)
+	}
+	lan, err := killswitch.ParseLAN(lanValue)
+	if err != nil {
+		fmt.Printf("kill switch LAN error: %v, using defaults\n", err)
+		lan, _ = killswitch.ParseLAN(config.DefaultKillSwitchLAN)
+	}
+	endpointsValue, err := config.AdguardKillSwitchEndpoints()
+	if err != nil {
+		fmt.Printf("config read error for kill switch endpoints: %v\n", err)
+	}
+	endpoints, err := killswitch.ParseEndpoints(endpointsValue)
+	if err != nil {
+		fmt.Printf("kill switch endpoints error: %v, reconnects stay blocked\n", err)
+	}
+	path, err := GetKillSwitchStatePath()
+	if err != nil {
+		fmt.Printf("kill switch state path error: %v\n", err)
+		return
+	}
+	ks := killswitch.New(path, lan, killswitch.NewSudoApplier(v.sudoEnv.SudoCommand))
+	ks.SetEndpoints(endpoints)
+	v.SetKillSwitch(ks, enabled)
+}
+
+// SetKillSwitch replaces the kill switch; enabled controls whether connections engage it.
+// Rules left by a previous run are recovered and settled by the first status check:
+// adopted while the tunnel is up and the kill switch enabled, removed otherwise.
+func (v *VPNManager) SetKillSwitch(ks *killswitch.KillSwitch, enabled bool) {
+	var stale bool
+	if ks != nil {
+		st, ok, err := ks.Recover()
+		if err != nil {
+			fmt.Printf("kill switch recover error: %v\n", err)
+		}
+		if ok {
+			fmt.Printf("kill switch: found rules for %s left by pid %d\n", st.Interface, st.PID)
+			stale = true
+		}
+	}
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.killSwitch = ks
+	v.killSwitchOn = enabled
+	v.killSwitchStale = stale
+}
+
+// KillSwitchEngaged reports whether rules blocking non-tunnel traffic are installed.
+func (v *VPNManager) KillSwitchEngaged() bool {
+	v.statemx.Lock()
+	ks := v.killSwitch
+	v.statemx.Unlock()
+	if ks == nil {
+		return false
+	}
+	_, ok := ks.Engaged()
+	return ok
+}
+
+// engageKillSwitch installs rules for the tunnel interface of the current status.
+// Confirmed connections call it; re-engaging the same interface is cheap.
+// The rules stay in place while the CLI reconnects after a drop, a resume or
+// a network change; the configured VPN endpoints remain reachable for it.
+func (v *VPNManager) engageKillSwitch() {
+	v.statemx.Lock()
+	ks, enabled := v.killSwitch, v.killSwitchOn
+	details := v.statusDetails
+	v.killSwitchStale = false
+	v.statemx.Unlock()
+	if ks == nil {
+		return
+	}
+	if !enabled {
+		v.disengageKillSwitch()
+		return
+	}
+	if details.Interface == "" {
+		fmt.Printf("kill switch: no tunnel interface in %s mode, not engaging\n", details.Mode)
+		v.disengageKillSwitch()
+		return
+	}
+
+	before, wasEngaged := ks.Engaged()
+	if wasEngaged && before.Interface == details.Interface {
+		return
+	}
+	if err := v.EnsureSudoPassword(); err != nil {
+		fmt.Printf("kill switch engage error: %v\n", err)
+		return
+	}
+	if err := ks.Engage(details.Interface); err != nil {
+		fmt.Printf("kill switch engage error: %v\n", err)
+		return
+	}
+	v.events.publish(KillSwitchChanged{Engaged: true, Interface: details.Interface})
+}
+
+// disengageKillSwitch removes the rules, e.g. after a deliberate disconnect.
+func (v *VPNManager) disengageKillSwitch() {
+	v.statemx.Lock()
+	ks := v.killSwitch
+	v.killSwitchStale = false
+	v.statemx.Unlock()
+	if ks == nil {
+		return
+	}
+	if _, ok := ks.Engaged(); !ok {
+		return
+	}
+	if err := v.EnsureSudoPassword(); err != nil {
+		fmt.Printf("kill switch disengage error: %v\n", err)
+		return
+	}
+	if err := ks.Disengage(); err != nil {
+		fmt.Printf("kill switch disengage error: %v\n", err)
+		return
+	}
+	v.events.publish(KillSwitchChanged{})
+}
+
+// settleStaleKillSwitch removes recovered rules when the first status check
+// finds the tunnel down. Rules engaged by this run stay after an unexpected drop.
+func (v *VPNManager) settleStaleKillSwitch() {
+	v.statemx.Lock()
+	stale := v.killSwitchStale
+	v.statemx.Unlock()
+	if stale {
+		v.disengageKillSwitch()
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/commands/killswitch"
+	"adgui/locations"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Kill switch", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+		statePath   string
+		scriptsMx   sync.Mutex
+		scripts     []string
+	)
+
+	apply := func(script string) error {
+		scriptsMx.Lock()
+		defer scriptsMx.Unlock()
+		scripts = append(scripts, script)
+		return nil
+	}
+	applied := func() []string {
+		scriptsMx.Lock()
+		defer scriptsMx.Unlock()
+		return append([]string(nil), scripts...)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-killswitch-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+		statePath = filepath.Join(tempHome, "killswitch.json")
+		scripts = nil
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga"}
+
+	It("blocks traffic until a deliberate disconnect", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Failed to connect: server did not respond\n", ExitCode: 1},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		ks := killswitch.New(statePath, nil, apply)
+		endpoints, err := killswitch.ParseEndpoints("203.0.113.7")
+		Expect(err).NotTo(HaveOccurred())
+		ks.SetEndpoints(endpoints)
+		mgr.SetKillSwitch(ks, true)
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+		Expect(applied()).To(HaveLen(1))
+		Expect(applied()[0]).To(ContainSubstring(`oifname "tun0" accept`))
+		Expect(applied()[0]).To(ContainSubstring("ip daddr 203.0.113.7 accept"))
+		Expect(applied()[0]).NotTo(ContainSubstring("skuid"))
+
+		mgr.RefreshStatus(context.Background())
+		Expect(mgr.IsConnected()).To(BeFalse())
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+
+		// The rules permit the VPN endpoint and stay in place while reconnecting.
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).NotTo(Succeed())
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+		Expect(applied()).To(HaveLen(1))
+
+		Expect(mgr.Disconnect(context.Background())).To(Succeed())
+		Expect(mgr.KillSwitchEngaged()).To(BeFalse())
+		Expect(applied()[len(applied())-1]).To(Equal(killswitch.DeleteScript()))
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+
+	It("does not engage without a tunnel interface", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in SOCKS5 mode, running on 127.0.0.1:1080\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetKillSwitch(killswitch.New(statePath, nil, apply), true)
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.IsConnected()).To(BeTrue())
+		Expect(mgr.KillSwitchEngaged()).To(BeFalse())
+		Expect(applied()).To(BeEmpty())
+	})
+
+	It("removes rules left by a crashed run when the tunnel is down", func() {
+		Expect(killswitch.New(statePath, nil, apply).Engage("tun0")).To(Succeed())
+
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetKillSwitch(killswitch.New(statePath, nil, apply), true)
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+
+		mgr.RefreshStatus(context.Background())
+		Expect(mgr.KillSwitchEngaged()).To(BeFalse())
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+
+	It("adopts rules left by a crashed run while the tunnel is up", func() {
+		Expect(killswitch.New(statePath, nil, apply).Engage("tun0")).To(Succeed())
+
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetKillSwitch(killswitch.New(statePath, nil, apply), true)
+
+		mgr.RefreshStatus(context.Background())
+		Expect(mgr.IsConnected()).To(BeTrue())
+		Expect(mgr.KillSwitchEngaged()).To(BeTrue())
+		// Same interface: nothing is reinstalled.
+		Expect(applied()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,201 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package killswitch blocks traffic outside the VPN tunnel with an nftables table
+// while adgui expects the VPN to be connected.
+package killswitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Applier loads an nft script with root privileges.
+type Applier func(script string) error
+
+// Elevator returns the command line that runs a program as root, e.g. the
+// absolute path of the sudowrap wrapper, and its environment; a nil
+// environment inherits the parent one.
+type Elevator func() (argv []string, env []string)
+
+// NewSudoApplier runs `nft -f -` through elevate, so elevation follows the same
+// askpass / sudo -n path as adguardvpn-cli. The command has no terminal to
+// prompt on: credentials must be available before the script is applied.
+func NewSudoApplier(elevate Elevator) Applier {
+	return func(script string) error {
+		argv, env := elevate()
+		cmd := exec.Command(argv[0], append(argv[1:], "nft", "-f", "-")...)
+		cmd.Env = env
+		cmd.Stdin = strings.NewReader(script)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("nft failed: %w, output: %s", err, strings.TrimSpace(output.String()))
+		}
+		return nil
+	}
+}
+
+// State is persisted while the rules are installed, so a crashed adgui
+// can find and remove them on the next start.
+type State struct {
+	Interface string    `json:"interface"`
+	LAN       []string  `json:"lan"`
+	EngagedAt time.Time `json:"engaged_at"`
+	PID       int       `json:"pid"`
+}
+
+// KillSwitch installs and removes the adgui nftables table.
+type KillSwitch struct {
+	mx        sync.Mutex
+	statePath string
+	lan       []netip.Prefix
+	endpoints []netip.AddrPort
+	apply     Applier
+	engaged   *State
+}
+
+// New returns a disengaged kill switch that keeps its state file at statePath.
+func New(statePath string, lan []netip.Prefix, apply Applier) *KillSwitch {
+	return &KillSwitch{statePath: statePath, lan: lan, apply: apply}
+}
+
+// SetEndpoints sets the VPN servers the next Engage keeps reachable through
+// the uplink.
+func (k *KillSwitch) SetEndpoints(endpoints []netip.AddrPort) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	k.endpoints = endpoints
+}
+
+// Recover loads the state left by a previous run. When found, the kill switch
+// counts as engaged until Disengage removes the rules.
+func (k *KillSwitch) Recover() (State, bool, error) {
+	st, ok, err := LoadState(k.statePath)
+	if err != nil || !ok {
+		return st, ok, err
+	}
+	k.mx.Lock()
+	k.engaged = &st
+	k.mx.Unlock()
+	return st, true, nil
+}
+
+// Engaged returns the installed state, if any.
+func (k *KillSwitch) Engaged() (State, bool) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	if k.engaged == nil {
+		return State{}, false
+	}
+	return *k.engaged, true
+}
+
+// Engage installs rules permitting only iface, loopback, LAN ranges and the
+// VPN endpoints.
+// Re-engaging on the same interface is a no-op. The state file is written
+// before nft runs so a crash in between still leaves a trace.
+func (k *KillSwitch) Engage(iface string) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	if k.engaged != nil && k.engaged.Interface == iface {
+		return nil
+	}
+
+	script, err := Ruleset(Config{Interface: iface, LAN: k.lan, Endpoints: k.endpoints})
+	if err != nil {
+		return err
+	}
+	st := State{Interface: iface, EngagedAt: time.Now(), PID: os.Getpid()}
+	for _, prefix := range k.lan {
+		st.LAN = append(st.LAN, prefix.String())
+	}
+	if err := saveState(k.statePath, st); err != nil {
+		return err
+	}
+	if err := k.apply(script); err != nil {
+		if k.engaged == nil {
+			_ = os.Remove(k.statePath)
+		} else {
+			_ = saveState(k.statePath, *k.engaged)
+		}
+		return err
+	}
+	k.engaged = &st
+	return nil
+}
+
+// Disengage removes the rules and the state file.
+func (k *KillSwitch) Disengage() error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	if k.engaged == nil {
+		return nil
+	}
+	if err := k.apply(DeleteScript()); err != nil {
+		return err
+	}
+	k.engaged = nil
+	if err := os.Remove(k.statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove kill switch state: %w", err)
+	}
+	return nil
+}
+
+// LoadState reads the state file; ok is false when no rules are recorded.
+func LoadState(path string) (State, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return State{}, false, nil
+		}
+		return State{}, false, fmt.Errorf("failed to read kill switch state: %w", err)
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return State{}, false, fmt.Errorf("failed to parse kill switch state: %w", err)
+	}
+	return st, true, nil
+}
+
+func saveState(path string, st State) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create kill switch state directory: %w", err)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to encode kill switch state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write kill switch state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write kill switch state: %w", err)
+	}
+	return nil
+}
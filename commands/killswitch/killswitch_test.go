@@ -0,0 +1,153 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package killswitch_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"adgui/commands/killswitch"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// Run with UPDATE_GOLDEN=1 to rewrite testdata after an intended ruleset change.
+func expectGolden(name, actual string) {
+	path := filepath.Join("testdata", name)
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		Expect(os.WriteFile(path, []byte(actual), 0o644)).To(Succeed())
+	}
+	expected, err := os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(actual).To(Equal(string(expected)))
+}
+
+var _ = Describe("Ruleset", func() {
+	It("renders the default LAN exceptions", func() {
+		lan, err := killswitch.ParseLAN("10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,169.254.0.0/16,fc00::/7,fe80::/10")
+		Expect(err).NotTo(HaveOccurred())
+		script, err := killswitch.Ruleset(killswitch.Config{Interface: "tun0", LAN: lan})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("default.nft", script)
+	})
+
+	It("lets only the VPN endpoints through the uplink", func() {
+		// Without endpoints nothing but the tunnel, loopback and the LAN
+		// leaves, whichever user owns the socket.
+		script, err := killswitch.Ruleset(killswitch.Config{Interface: "tun0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(script).NotTo(ContainSubstring("skuid"))
+		Expect(script).NotTo(ContainSubstring("daddr"))
+
+		endpoints, err := killswitch.ParseEndpoints("203.0.113.7:443, 198.51.100.9, [2001:db8::1]:8443, 203.0.113.7:443")
+		Expect(err).NotTo(HaveOccurred())
+		script, err = killswitch.Ruleset(killswitch.Config{Interface: "tun0", Endpoints: endpoints})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("endpoints.nft", script)
+
+		_, err = killswitch.ParseEndpoints("vpn.example.com:443")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("omits empty address families", func() {
+		lan, err := killswitch.ParseLAN(" 192.168.1.7/24, ,192.168.1.0/24")
+		Expect(err).NotTo(HaveOccurred())
+		script, err := killswitch.Ruleset(killswitch.Config{Interface: "tun1", LAN: lan})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("ipv4_only.nft", script)
+
+		script, err = killswitch.Ruleset(killswitch.Config{Interface: "tun0"})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("no_lan.nft", script)
+	})
+
+	It("rejects unknown and unsafe interfaces", func() {
+		_, err := killswitch.Ruleset(killswitch.Config{})
+		Expect(err).To(MatchError(killswitch.ErrNoInterface))
+		_, err = killswitch.Ruleset(killswitch.Config{Interface: "tun0\" accept; #"})
+		Expect(err).To(HaveOccurred())
+		_, err = killswitch.ParseLAN("10.0.0.0/33")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("KillSwitch", func() {
+	var (
+		statePath string
+		scripts   []string
+		applyErr  error
+		apply     killswitch.Applier
+	)
+
+	BeforeEach(func() {
+		statePath = filepath.Join(GinkgoT().TempDir(), "killswitch.json")
+		scripts = nil
+		applyErr = nil
+		apply = func(script string) error {
+			scripts = append(scripts, script)
+			return applyErr
+		}
+	})
+
+	It("persists state while engaged and removes it on disengage", func() {
+		ks := killswitch.New(statePath, nil, apply)
+		endpoints, err := killswitch.ParseEndpoints("203.0.113.7:443")
+		Expect(err).NotTo(HaveOccurred())
+		ks.SetEndpoints(endpoints)
+		Expect(ks.Engage("tun0")).To(Succeed())
+		Expect(ks.Engage("tun0")).To(Succeed())
+		Expect(scripts).To(HaveLen(1))
+		Expect(scripts[0]).To(ContainSubstring("ip daddr 203.0.113.7 meta l4proto { tcp, udp } th dport 443 accept"))
+
+		st, ok, err := killswitch.LoadState(statePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(st.Interface).To(Equal("tun0"))
+		Expect(st.PID).To(Equal(os.Getpid()))
+
+		Expect(ks.Disengage()).To(Succeed())
+		Expect(scripts[1]).To(Equal(killswitch.DeleteScript()))
+		_, engaged := ks.Engaged()
+		Expect(engaged).To(BeFalse())
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+
+	It("recovers rules left by a crashed run", func() {
+		Expect(killswitch.New(statePath, nil, apply).Engage("tun0")).To(Succeed())
+
+		ks := killswitch.New(statePath, nil, apply)
+		st, ok, err := ks.Recover()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(st.Interface).To(Equal("tun0"))
+		_, engaged := ks.Engaged()
+		Expect(engaged).To(BeTrue())
+
+		Expect(ks.Disengage()).To(Succeed())
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+
+	It("leaves no state when nft fails", func() {
+		applyErr = errors.New("nft: permission denied")
+		ks := killswitch.New(statePath, nil, apply)
+		Expect(ks.Engage("tun0")).To(MatchError(applyErr))
+		_, engaged := ks.Engaged()
+		Expect(engaged).To(BeFalse())
+		Expect(statePath).NotTo(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,184 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package killswitch
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// TableName is the nftables table owned by adgui. Nothing else is touched.
+const TableName = "adgui_killswitch"
+
+var (
+	// ErrNoInterface is returned when the tunnel interface is unknown (e.g. SOCKS mode).
+	ErrNoInterface = errors.New("tunnel interface is unknown")
+
+	ifaceNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,15}$`)
+)
+
+// Config describes what the kill switch permits.
+type Config struct {
+	// Interface is the tunnel device reported by adguardvpn-cli status, e.g. tun0.
+	Interface string
+	// LAN ranges remain reachable outside the tunnel.
+	LAN []netip.Prefix
+	// Endpoints are the VPN servers the tunnel transport reaches over the
+	// uplink; a zero port permits every port of the address.
+	Endpoints []netip.AddrPort
+}
+
+// ParseLAN parses a comma-separated CIDR list. Blank items are skipped.
+func ParseLAN(value string) ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LAN range %q: %w", item, err)
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
+// ParseEndpoints parses a comma-separated list of VPN server addresses, each
+// an IP or an IP with a port (203.0.113.7:443, [2001:db8::1]:443). Blank
+// items are skipped.
+func ParseEndpoints(value string) ([]netip.AddrPort, error) {
+	var result []netip.AddrPort
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			result = append(result, netip.AddrPortFrom(addr.Unmap(), 0))
+			continue
+		}
+		endpoint, err := netip.ParseAddrPort(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VPN endpoint %q: %w", item, err)
+		}
+		result = append(result, netip.AddrPortFrom(endpoint.Addr().Unmap(), endpoint.Port()))
+	}
+	return result, nil
+}
+
+// Ruleset renders the nft script for cfg. The script replaces the adgui table
+// atomically: traffic is dropped unless it uses loopback, the tunnel interface,
+// a LAN range, DHCP or IPv6 neighbour discovery. Only the listed VPN endpoints
+// are reachable through the uplink, and replies to permitted traffic come
+// back, so the rules can stay in place while the CLI reconnects.
+func Ruleset(cfg Config) (string, error) {
+	if cfg.Interface == "" {
+		return "", ErrNoInterface
+	}
+	if !ifaceNameRegex.MatchString(cfg.Interface) {
+		return "", fmt.Errorf("invalid interface name %q", cfg.Interface)
+	}
+
+	var lan4, lan6 []string
+	seen := make(map[netip.Prefix]bool)
+	for _, prefix := range cfg.LAN {
+		prefix = prefix.Masked()
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		if prefix.Addr().Is4() {
+			lan4 = append(lan4, prefix.String())
+		} else {
+			lan6 = append(lan6, prefix.String())
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(DeleteScript())
+	fmt.Fprintf(&b, "table inet %s {\n", TableName)
+	writeSet(&b, "lan4", "ipv4_addr", lan4)
+	writeSet(&b, "lan6", "ipv6_addr", lan6)
+	writeChain(&b, "output", "oifname", "daddr", cfg.Interface, lan4, lan6,
+		endpointRules(cfg.Endpoints),
+		"udp sport 68 udp dport 67 accept")
+	writeChain(&b, "input", "iifname", "saddr", cfg.Interface, lan4, lan6,
+		[]string{"ct state established,related accept"},
+		"udp sport 67 udp dport 68 accept")
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// DeleteScript renders the nft script that removes the adgui table.
+// Declaring the table first makes the deletion succeed when it is absent.
+func DeleteScript() string {
+	return fmt.Sprintf("table inet %s\ndelete table inet %s\n", TableName, TableName)
+}
+
+func writeSet(b *strings.Builder, name, typ string, elements []string) {
+	if len(elements) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\tset %s {\n\t\ttype %s\n\t\tflags interval\n\t\telements = { %s }\n\t}\n",
+		name, typ, strings.Join(elements, ", "))
+}
+
+// endpointRules permits the tunnel transport to each endpoint, on its port
+// when one is given.
+func endpointRules(endpoints []netip.AddrPort) []string {
+	var rules []string
+	seen := make(map[netip.AddrPort]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		family := "ip"
+		if endpoint.Addr().Is6() {
+			family = "ip6"
+		}
+		rule := fmt.Sprintf("%s daddr %s", family, endpoint.Addr())
+		if endpoint.Port() != 0 {
+			rule += fmt.Sprintf(" meta l4proto { tcp, udp } th dport %d", endpoint.Port())
+		}
+		rules = append(rules, rule+" accept")
+	}
+	return rules
+}
+
+func writeChain(b *strings.Builder, hook, ifaceMatch, addrMatch, iface string, lan4, lan6, transport []string, dhcp string) {
+	fmt.Fprintf(b, "\tchain %s {\n", hook)
+	fmt.Fprintf(b, "\t\ttype filter hook %s priority filter; policy drop;\n", hook)
+	fmt.Fprintf(b, "\t\t%s \"lo\" accept\n", ifaceMatch)
+	fmt.Fprintf(b, "\t\t%s %q accept\n", ifaceMatch, iface)
+	for _, rule := range transport {
+		fmt.Fprintf(b, "\t\t%s\n", rule)
+	}
+	if len(lan4) > 0 {
+		fmt.Fprintf(b, "\t\tip %s @lan4 accept\n", addrMatch)
+	}
+	if len(lan6) > 0 {
+		fmt.Fprintf(b, "\t\tip6 %s @lan6 accept\n", addrMatch)
+	}
+	fmt.Fprintf(b, "\t\t%s\n", dhcp)
+	b.WriteString("\t\ticmpv6 type { nd-router-solicit, nd-router-advert, nd-neighbor-solicit, nd-neighbor-advert } accept\n")
+	b.WriteString("\t}\n")
+}
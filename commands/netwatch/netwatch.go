@@ -0,0 +1,183 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package netwatch reports link, address and route changes from the kernel
+// routing socket, so that a VPN tunnel appearing or disappearing is noticed
+// without polling adguardvpn-cli.
+package netwatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Kind tells what a Change is about.
+type Kind int
+
+const (
+	KindLink Kind = iota
+	KindAddress
+	KindRoute
+	// KindLost means the kernel dropped notifications because the socket buffer
+	// overflowed; the network state has to be re-read.
+	KindLost
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindLink:
+		return "link"
+	case KindAddress:
+		return "address"
+	case KindRoute:
+		return "route"
+	case KindLost:
+		return "lost"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is one notification from the routing socket.
+type Change struct {
+	Kind  Kind
+	Index int
+	// Interface is the device name; it may be empty when the device is already gone.
+	Interface string
+	// Removed is set for deleted links, addresses and routes.
+	Removed bool
+	// Up and PointToPoint carry the link flags; they are set for link changes only.
+	Up           bool
+	PointToPoint bool
+}
+
+// ErrUnsupported is returned by Watch on platforms without a routing socket.
+var ErrUnsupported = errors.New("network change notifications are not supported on this platform")
+
+// rtnetlink ABI, see linux/rtnetlink.h and linux/if_link.h.
+const (
+	nlmsgHdrLen   = 16
+	ifInfoMsgLen  = 16
+	ifAddrMsgLen  = 8
+	rtMsgLen      = 12
+	rtAttrHdrLen  = 4
+	nlmsgError    = 2
+	rtmNewLink    = 16
+	rtmDelLink    = 17
+	rtmNewAddr    = 20
+	rtmDelAddr    = 21
+	rtmNewRoute   = 24
+	rtmDelRoute   = 25
+	iflaIfName    = 3
+	ifaLabel      = 3
+	rtaOIf        = 4
+	iffUp         = 0x1
+	iffPointToPtr = 0x10
+)
+
+// ParseMessages decodes a datagram read from a NETLINK_ROUTE socket.
+// Messages other than link, address and route notifications are skipped.
+func ParseMessages(buf []byte) ([]Change, error) {
+	var changes []Change
+	for len(buf) >= nlmsgHdrLen {
+		size := int(binary.NativeEndian.Uint32(buf[0:4]))
+		typ := binary.NativeEndian.Uint16(buf[4:6])
+		if size < nlmsgHdrLen || size > len(buf) {
+			return changes, fmt.Errorf("invalid netlink message length %d", size)
+		}
+		payload := buf[nlmsgHdrLen:size]
+		if typ == nlmsgError {
+			return changes, errors.New("netlink error message")
+		}
+		if change, ok := parseMessage(typ, payload); ok {
+			changes = append(changes, change)
+		}
+		buf = buf[min(align(size), len(buf)):]
+	}
+	return changes, nil
+}
+
+func parseMessage(typ uint16, payload []byte) (Change, bool) {
+	var change Change
+	switch typ {
+	case rtmNewLink, rtmDelLink:
+		if len(payload) < ifInfoMsgLen {
+			return change, false
+		}
+		flags := binary.NativeEndian.Uint32(payload[8:12])
+		change = Change{
+			Kind:         KindLink,
+			Index:        int(int32(binary.NativeEndian.Uint32(payload[4:8]))),
+			Removed:      typ == rtmDelLink,
+			Up:           typ == rtmNewLink && flags&iffUp != 0,
+			PointToPoint: flags&iffPointToPtr != 0,
+		}
+		change.Interface = attrString(payload[ifInfoMsgLen:], iflaIfName)
+	case rtmNewAddr, rtmDelAddr:
+		if len(payload) < ifAddrMsgLen {
+			return change, false
+		}
+		change = Change{
+			Kind:    KindAddress,
+			Index:   int(binary.NativeEndian.Uint32(payload[4:8])),
+			Removed: typ == rtmDelAddr,
+		}
+		change.Interface = attrString(payload[ifAddrMsgLen:], ifaLabel)
+	case rtmNewRoute, rtmDelRoute:
+		if len(payload) < rtMsgLen {
+			return change, false
+		}
+		change = Change{Kind: KindRoute, Removed: typ == rtmDelRoute}
+		if value, ok := attr(payload[rtMsgLen:], rtaOIf); ok && len(value) >= 4 {
+			change.Index = int(binary.NativeEndian.Uint32(value))
+		}
+	default:
+		return change, false
+	}
+	return change, true
+}
+
+// attr returns the value of the first routing attribute of type typ.
+func attr(buf []byte, typ uint16) ([]byte, bool) {
+	for len(buf) >= rtAttrHdrLen {
+		size := int(binary.NativeEndian.Uint16(buf[0:2]))
+		if size < rtAttrHdrLen || size > len(buf) {
+			return nil, false
+		}
+		if binary.NativeEndian.Uint16(buf[2:4]) == typ {
+			return buf[rtAttrHdrLen:size], true
+		}
+		buf = buf[min(align(size), len(buf)):]
+	}
+	return nil, false
+}
+
+func attrString(buf []byte, typ uint16) string {
+	value, ok := attr(buf, typ)
+	if !ok {
+		return ""
+	}
+	for i, b := range value {
+		if b == 0 {
+			return string(value[:i])
+		}
+	}
+	return string(value)
+}
+
+func align(size int) int {
+	return (size + 3) &^ 3
+}
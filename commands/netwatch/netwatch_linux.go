@@ -0,0 +1,104 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Multicast groups from linux/rtnetlink.h; syscall does not export them.
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+
+	groups = rtmgrpLink | rtmgrpIPv4IfAddr | rtmgrpIPv4Route | rtmgrpIPv6IfAddr | rtmgrpIPv6Route
+)
+
+// Watch subscribes to routing socket notifications. The channel is closed
+// when ctx is cancelled or the socket fails.
+func Watch(ctx context.Context) (<-chan Change, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+	// A non-blocking descriptor goes through the runtime poller, so closing
+	// the file interrupts a pending read.
+	sock := os.NewFile(uintptr(fd), "netlink")
+
+	changes := make(chan Change, 16)
+	go func() {
+		<-ctx.Done()
+		_ = sock.Close()
+	}()
+	go readChanges(ctx, sock, changes)
+	return changes, nil
+}
+
+func readChanges(ctx context.Context, sock *os.File, changes chan<- Change) {
+	defer close(changes)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := sock.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, syscall.ENOBUFS) {
+				if !send(ctx, changes, Change{Kind: KindLost}) {
+					return
+				}
+				continue
+			}
+			fmt.Printf("netlink read error: %v\n", err)
+			return
+		}
+		parsed, err := ParseMessages(buf[:n])
+		if err != nil {
+			fmt.Printf("netlink parse error: %v\n", err)
+		}
+		for _, change := range parsed {
+			if change.Interface == "" && change.Index > 0 {
+				if iface, err := net.InterfaceByIndex(change.Index); err == nil {
+					change.Interface = iface.Name
+				}
+			}
+			if !send(ctx, changes, change) {
+				return
+			}
+		}
+	}
+}
+
+func send(ctx context.Context, changes chan<- Change, change Change) bool {
+	select {
+	case changes <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package netwatch_test
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strings"
+
+	"adgui/commands/netwatch"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// message builds a netlink message with a fixed header and routing attributes.
+func message(typ uint16, header []byte, attrs ...[]byte) []byte {
+	body := append([]byte(nil), header...)
+	for _, a := range attrs {
+		body = append(body, a...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	msg := make([]byte, 16, 16+len(body))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(16+len(body)))
+	binary.NativeEndian.PutUint16(msg[4:6], typ)
+	return append(msg, body...)
+}
+
+func rtattr(typ uint16, value []byte) []byte {
+	a := make([]byte, 4, 4+len(value))
+	binary.NativeEndian.PutUint16(a[0:2], uint16(4+len(value)))
+	binary.NativeEndian.PutUint16(a[2:4], typ)
+	return append(a, value...)
+}
+
+func u32(value uint32) []byte {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, value)
+	return b
+}
+
+func ifinfo(index int, flags uint32) []byte {
+	b := make([]byte, 16)
+	binary.NativeEndian.PutUint32(b[4:8], uint32(index))
+	binary.NativeEndian.PutUint32(b[8:12], flags)
+	return b
+}
+
+var _ = Describe("ParseMessages", func() {
+	It("decodes link, address and route notifications", func() {
+		var buf []byte
+		buf = append(buf, message(16, ifinfo(7, 0x1|0x10|0x40), rtattr(3, []byte("tun0\x00")))...)
+		buf = append(buf, message(17, ifinfo(7, 0x10), rtattr(3, []byte("tun0\x00")))...)
+		addr := make([]byte, 8)
+		binary.NativeEndian.PutUint32(addr[4:8], 2)
+		buf = append(buf, message(21, addr, rtattr(1, []byte{10, 0, 0, 2}), rtattr(3, []byte("eth0\x00")))...)
+		buf = append(buf, message(24, make([]byte, 12), rtattr(4, u32(7)))...)
+		buf = append(buf, message(3, nil)...)
+
+		changes, err := netwatch.ParseMessages(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]netwatch.Change{
+			{Kind: netwatch.KindLink, Index: 7, Interface: "tun0", Up: true, PointToPoint: true},
+			{Kind: netwatch.KindLink, Index: 7, Interface: "tun0", Removed: true, PointToPoint: true},
+			{Kind: netwatch.KindAddress, Index: 2, Interface: "eth0", Removed: true},
+			{Kind: netwatch.KindRoute, Index: 7},
+		}))
+	})
+
+	It("rejects truncated messages", func() {
+		msg := message(16, ifinfo(1, 0x1), rtattr(3, []byte("lo\x00")))
+		changes, err := netwatch.ParseMessages(msg[:len(msg)-4])
+		Expect(err).To(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("reports kernel errors", func() {
+		_, err := netwatch.ParseMessages(message(2, make([]byte, 20)))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseRouteTable", func() {
+	const table = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"tun0\t00000000\t00000000\t0001\t0\t0\t0\t00000000\t0\t0\t0\n" +
+		"wlan0\t00000000\t0101A8C0\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+		"eth0\t00000000\t010A000A\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"eth0\t000A000A\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+
+	isTun := func(iface string) bool { return iface == "tun0" }
+
+	It("picks the default route with the lowest metric outside the tunnel", func() {
+		uplink, ok, err := netwatch.ParseRouteTable(strings.NewReader(table), isTun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(uplink.Interface).To(Equal("eth0"))
+		Expect(uplink.Gateway).To(Equal(netip.MustParseAddr("10.0.10.1")))
+		Expect(uplink.String()).To(Equal("eth0 via 10.0.10.1"))
+	})
+
+	It("keeps the tunnel when nothing is skipped", func() {
+		uplink, ok, err := netwatch.ParseRouteTable(strings.NewReader(table), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(uplink.String()).To(Equal("tun0"))
+	})
+
+	It("reports a missing default route", func() {
+		uplink, ok, err := netwatch.ParseRouteTable(strings.NewReader(strings.Join(strings.Split(table, "\n")[4:], "\n")), isTun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(uplink.String()).To(Equal("none"))
+	})
+})
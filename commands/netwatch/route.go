@@ -0,0 +1,115 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package netwatch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RouteTablePath is the kernel IPv4 routing table in text form.
+const RouteTablePath = "/proc/net/route"
+
+const rtfUp = 0x1
+
+// Uplink is the interface and gateway of the IPv4 default route.
+type Uplink struct {
+	Interface string
+	Gateway   netip.Addr
+}
+
+func (u Uplink) String() string {
+	if u.Interface == "" {
+		return "none"
+	}
+	if !u.Gateway.IsValid() {
+		return u.Interface
+	}
+	return u.Interface + " via " + u.Gateway.String()
+}
+
+// DefaultRoute reads RouteTablePath and returns the default route with the
+// lowest metric. Interfaces for which skip returns true, such as the VPN
+// tunnel, are ignored; ok is false when no other default route exists.
+func DefaultRoute(skip func(iface string) bool) (Uplink, bool, error) {
+	f, err := os.Open(RouteTablePath)
+	if err != nil {
+		return Uplink{}, false, fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return ParseRouteTable(f, skip)
+}
+
+// ParseRouteTable finds the default route in the /proc/net/route format.
+func ParseRouteTable(r io.Reader, skip func(iface string) bool) (Uplink, bool, error) {
+	var best Uplink
+	bestMetric := -1
+	scanner := bufio.NewScanner(r)
+	for first := true; scanner.Scan(); first = false {
+		fields := strings.Fields(scanner.Text())
+		if first || len(fields) < 8 {
+			continue
+		}
+		iface, dest, gateway, flags, metric, mask := fields[0], fields[1], fields[2], fields[3], fields[6], fields[7]
+		if dest != "00000000" || mask != "00000000" {
+			continue
+		}
+		if skip != nil && skip(iface) {
+			continue
+		}
+		if f, err := strconv.ParseUint(flags, 16, 32); err != nil || f&rtfUp == 0 {
+			continue
+		}
+		m, err := strconv.Atoi(metric)
+		if err != nil {
+			return Uplink{}, false, fmt.Errorf("invalid route metric %q", metric)
+		}
+		if bestMetric >= 0 && m >= bestMetric {
+			continue
+		}
+		gw, err := parseHexAddr(gateway)
+		if err != nil {
+			return Uplink{}, false, err
+		}
+		best = Uplink{Interface: iface, Gateway: gw}
+		bestMetric = m
+	}
+	if err := scanner.Err(); err != nil {
+		return Uplink{}, false, fmt.Errorf("failed to read routing table: %w", err)
+	}
+	return best, bestMetric >= 0, nil
+}
+
+// parseHexAddr decodes an address printed by the kernel in host byte order.
+// A zero gateway means the route is on-link and yields an invalid Addr.
+func parseHexAddr(value string) (netip.Addr, error) {
+	n, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid route gateway %q", value)
+	}
+	if n == 0 {
+		return netip.Addr{}, nil
+	}
+	var b [4]byte
+	binary.NativeEndian.PutUint32(b[:], uint32(n))
+	return netip.AddrFrom4(b), nil
+}
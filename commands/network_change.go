@@ -0,0 +1,193 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"adgui/commands/netwatch"
+	"adgui/config"
+)
+
+// NetworkChangeAction selects what happens when the default route moves to
+// another uplink (Wi-Fi to ethernet, a new access point) while the VPN is up.
+type NetworkChangeAction string
+
+const (
+	NetworkChangeOff   NetworkChangeAction = "off"
+	NetworkChangeCheck NetworkChangeAction = "check"
+	// NetworkChangeReconnect also reconnects to the same location when the probe fails.
+	NetworkChangeReconnect NetworkChangeAction = "reconnect"
+)
+
+const probeTimeout = 5 * time.Second
+
+// NetworkChangePolicy configures the re-validation after an uplink change.
+type NetworkChangePolicy struct {
+	Action NetworkChangeAction
+	// Probe reports whether traffic passes the tunnel; nil skips the probe.
+	Probe func(ctx context.Context) error
+}
+
+// DefaultNetworkChangePolicy checks the tunnel and dials AdGuard DNS.
+func DefaultNetworkChangePolicy() NetworkChangePolicy {
+	return NetworkChangePolicy{Action: NetworkChangeCheck, Probe: DialProbe("94.140.14.14:53")}
+}
+
+// DialProbe returns a probe that opens a TCP connection to addr.
+func DialProbe(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func loadNetworkChangePolicy() (NetworkChangePolicy, error) {
+	policy := DefaultNetworkChangePolicy()
+	action, err := config.AdguardNetworkChange()
+	policy.Action = NetworkChangeAction(action)
+	if err != nil {
+		return policy, err
+	}
+	addr, err := config.AdguardNetworkProbe()
+	if err != nil {
+		return policy, err
+	}
+	policy.Probe = DialProbe(addr)
+	return policy, nil
+}
+
+// SetNetworkChangePolicy replaces the network change policy.
+func (v *VPNManager) SetNetworkChangePolicy(policy NetworkChangePolicy) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.networkChange = policy
+}
+
+func (v *VPNManager) networkChangePolicy() NetworkChangePolicy {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.networkChange
+}
+
+// isTunnelInterface reports whether iface is, or looks like, the VPN tunnel.
+func (v *VPNManager) isTunnelInterface(iface string) bool {
+	if tunnel := v.StatusDetails().Interface; tunnel != "" && iface == tunnel {
+		return true
+	}
+	return strings.HasPrefix(iface, "tun")
+}
+
+// currentUplink returns the default route outside the tunnel.
+func (v *VPNManager) currentUplink() (netwatch.Uplink, bool) {
+	uplink, ok, err := netwatch.DefaultRoute(v.isTunnelInterface)
+	if err != nil {
+		if v.shouldLogStatusCheck("uplink:" + err.Error()) {
+			fmt.Printf("default route check error: %v\n", err)
+		}
+		return netwatch.Uplink{}, false
+	}
+	return uplink, ok
+}
+
+// uplinkMove is a default route change waiting for the one being handled.
+type uplinkMove struct {
+	from, to netwatch.Uplink
+}
+
+// UplinkChanged runs HandleUplinkChange in the background, so the status loop
+// keeps serving requests while the tunnel is probed or reconnected. A change
+// arriving during the run is coalesced with later ones and handled once it
+// ends, from the uplink it started on to the latest one.
+func (v *VPNManager) UplinkChanged(ctx context.Context, from, to netwatch.Uplink) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	if v.uplinkBusy {
+		if v.uplinkNext != nil {
+			from = v.uplinkNext.from
+		}
+		v.uplinkNext = &uplinkMove{from: from, to: to}
+		return
+	}
+	v.uplinkBusy = true
+	go v.handleUplinkMoves(ctx, uplinkMove{from: from, to: to})
+}
+
+func (v *VPNManager) handleUplinkMoves(ctx context.Context, move uplinkMove) {
+	for {
+		if move.from != move.to {
+			v.HandleUplinkChange(ctx, move.from, move.to)
+		}
+		v.statemx.Lock()
+		next := v.uplinkNext
+		v.uplinkNext = nil
+		if next == nil || ctx.Err() != nil {
+			v.uplinkBusy = false
+			v.statemx.Unlock()
+			return
+		}
+		v.statemx.Unlock()
+		move = *next
+	}
+}
+
+// HandleUplinkChange re-validates a connected tunnel after the default route
+// moved from one uplink to another: it checks the CLI status, probes
+// connectivity and, with NetworkChangeReconnect, reconnects to the same
+// location when the probe fails. Each step is noted in the connection history.
+func (v *VPNManager) HandleUplinkChange(ctx context.Context, from, to netwatch.Uplink) {
+	policy := v.networkChangePolicy()
+	if policy.Action == NetworkChangeOff {
+		return
+	}
+	if _, connected := v.ConnectedLocation(); !connected {
+		return
+	}
+
+	fmt.Printf("network change: %s -> %s, checking the tunnel\n", from, to)
+	// Annotated first, so that a disconnect found by the status check
+	// closes the session with the cause.
+	v.annotateActiveConnection(fmt.Sprintf("network change %s → %s", from, to))
+	v.checkStatus(ctx)
+	loc, connected := v.ConnectedLocation()
+	if !connected || policy.Probe == nil {
+		return
+	}
+	err := policy.Probe(ctx)
+	if err == nil {
+		return
+	}
+
+	v.annotateActiveConnection("connectivity probe failed: " + err.Error())
+	if policy.Action != NetworkChangeReconnect || ctx.Err() != nil {
+		return
+	}
+	if err := v.connectToLocation(ctx, loc); err != nil {
+		v.annotateActiveConnection("reconnect to " + loc.City + " failed: " + failureMessage(err))
+		return
+	}
+	v.annotateActiveConnection("reconnected to " + loc.City)
+}
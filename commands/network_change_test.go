@@ -0,0 +1,204 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/commands/netwatch"
+	"adgui/locations"
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Network changes", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-netwatch-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	It("checks the status when the tunnel interface changes", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+		})
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+		mgr.RefreshStatus(context.Background())
+		Expect(mgr.StatusDetails().Interface).To(Equal("tun0"))
+		// The loop handles change requests after its first check.
+		Eventually(func() int { return runner.Starts("status") }, 5*time.Second).Should(Equal(2))
+
+		mgr.NetworkChanged(netwatch.Change{Kind: netwatch.KindAddress, Interface: "eth0"})
+		mgr.NetworkChanged(netwatch.Change{Kind: netwatch.KindLink, Interface: "wlan0", Up: true})
+		Consistently(func() int { return runner.Starts("status") }, time.Second).Should(Equal(2))
+
+		mgr.NetworkChanged(netwatch.Change{Kind: netwatch.KindRoute, Interface: "tun0", Removed: true})
+		Eventually(func() int { return runner.Starts("status") }, time.Second).Should(Equal(3))
+
+		mgr.NetworkChanged(netwatch.Change{Kind: netwatch.KindLink, Interface: "tun1", PointToPoint: true, Up: true})
+		Eventually(func() int { return runner.Starts("status") }, time.Second).Should(Equal(4))
+	})
+
+	Describe("uplink changes", func() {
+		riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+		eth0 := netwatch.Uplink{Interface: "eth0", Gateway: netip.MustParseAddr("10.0.0.1")}
+		wlan0 := netwatch.Uplink{Interface: "wlan0", Gateway: netip.MustParseAddr("192.168.1.1")}
+		stale := func(context.Context) error { return errors.New("i/o timeout") }
+		const locationList = "ISO   COUNTRY   CITY   PING ESTIMATE\nLV    Latvia    Riga   29\n"
+
+		It("notes a healthy tunnel in the history", func() {
+			runner := newHeldRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"list-locations"}, Output: locationList},
+			})
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			probes := 0
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{
+				Action: commands.NetworkChangeReconnect,
+				Probe:  func(context.Context) error { probes++; return nil },
+			})
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+			mgr.HandleUplinkChange(context.Background(), eth0, wlan0)
+			Expect(probes).To(Equal(1))
+			Expect(runner.Starts("connect -l Riga")).To(Equal(1))
+			Expect(mgr.ConnectionHistory()[0].Reason).To(Equal("network change eth0 via 10.0.0.1 → wlan0 via 192.168.1.1"))
+		})
+
+		It("handles changes in the background and coalesces those arriving meanwhile", func() {
+			runner := newHeldRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"list-locations"}, Output: locationList},
+			})
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			var probes atomic.Int32
+			gate := make(chan struct{})
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{
+				Action: commands.NetworkChangeCheck,
+				Probe: func(context.Context) error {
+					probes.Add(1)
+					<-gate
+					return nil
+				},
+			})
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+			wlan1 := netwatch.Uplink{Interface: "wlan1", Gateway: netip.MustParseAddr("172.16.0.1")}
+
+			// Returns while the first probe is still running.
+			mgr.UplinkChanged(context.Background(), eth0, wlan0)
+			Eventually(probes.Load, time.Second).Should(Equal(int32(1)))
+			mgr.UplinkChanged(context.Background(), wlan0, eth0)
+			mgr.UplinkChanged(context.Background(), eth0, wlan1)
+			Consistently(probes.Load, 200*time.Millisecond).Should(Equal(int32(1)))
+
+			gate <- struct{}{}
+			Eventually(probes.Load, time.Second).Should(Equal(int32(2)))
+			gate <- struct{}{}
+			Consistently(probes.Load, 200*time.Millisecond).Should(Equal(int32(2)))
+			Expect(mgr.ConnectionHistory()[0].Reason).To(Equal(
+				"network change eth0 via 10.0.0.1 → wlan0 via 192.168.1.1; network change wlan0 via 192.168.1.1 → wlan1 via 172.16.0.1"))
+		})
+
+		It("reconnects to the same location when the probe fails", func() {
+			runner := newHeldRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"list-locations"}, Output: locationList},
+			})
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{Action: commands.NetworkChangeReconnect, Probe: stale})
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+			mgr.HandleUplinkChange(context.Background(), eth0, wlan0)
+			Expect(runner.Starts("connect -l Riga")).To(Equal(2))
+			Expect(mgr.IsConnected()).To(BeTrue())
+			Expect(mgr.ConnectionHistory()[0].Reason).To(Equal(
+				"network change eth0 via 10.0.0.1 → wlan0 via 192.168.1.1; connectivity probe failed: i/o timeout; reconnected to Riga"))
+		})
+
+		It("only checks with the check action and does nothing when off", func() {
+			runner := newHeldRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"list-locations"}, Output: locationList},
+			})
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{Action: commands.NetworkChangeCheck, Probe: stale})
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+			mgr.HandleUplinkChange(context.Background(), eth0, wlan0)
+			Expect(runner.Starts("connect -l Riga")).To(Equal(1))
+			Expect(mgr.ConnectionHistory()[0].Reason).To(HaveSuffix("connectivity probe failed: i/o timeout"))
+
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{Action: commands.NetworkChangeOff, Probe: stale})
+			statuses := runner.Starts("status")
+			mgr.HandleUplinkChange(context.Background(), wlan0, eth0)
+			Expect(runner.Starts("status")).To(Equal(statuses))
+		})
+
+		It("lets the watchdog handle a tunnel that dropped", func() {
+			runner := newHeldRunner([]commands.TranscriptEntry{
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+				{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+			})
+			mgr := commands.New(runner)
+			defer func() { _ = mgr.Close() }()
+			mgr.SetNetworkChangePolicy(commands.NetworkChangePolicy{Action: commands.NetworkChangeReconnect, Probe: stale})
+			Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+			mgr.HandleUplinkChange(context.Background(), eth0, wlan0)
+			Expect(mgr.IsConnected()).To(BeFalse())
+			Expect(runner.Starts("connect -l Riga")).To(Equal(1))
+			Expect(mgr.ConnectionHistory()[0].Reason).To(Equal(
+				"network change eth0 via 10.0.0.1 → wlan0 via 192.168.1.1; unexpected disconnect"))
+		})
+	})
+})
@@ -0,0 +1,113 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetResumeReconnect selects whether a tunnel found down after a resume is
+// reconnected at once instead of being left to the watchdog policy.
+func (v *VPNManager) SetResumeReconnect(enabled bool) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.resumeReconnect = enabled
+}
+
+// WokeUp runs Resumed in the background, so the status loop keeps serving
+// requests while a reconnect after the resume waits for the CLI or a sudo
+// prompt. Resumes arriving during the run are coalesced and handled once it
+// ends, from the earliest of their sleeps.
+func (v *VPNManager) WokeUp(ctx context.Context, suspendedAt time.Time) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	if v.resumeBusy {
+		if v.resumeNext.IsZero() || suspendedAt.Before(v.resumeNext) {
+			v.resumeNext = suspendedAt
+		}
+		return
+	}
+	v.resumeBusy = true
+	go v.handleResumes(ctx, suspendedAt)
+}
+
+func (v *VPNManager) handleResumes(ctx context.Context, suspendedAt time.Time) {
+	for {
+		v.Resumed(ctx, suspendedAt)
+		v.statemx.Lock()
+		next := v.resumeNext
+		v.resumeNext = time.Time{}
+		if next.IsZero() || ctx.Err() != nil {
+			v.resumeBusy = false
+			v.statemx.Unlock()
+			return
+		}
+		v.statemx.Unlock()
+		suspendedAt = next
+	}
+}
+
+// Resumed checks the tunnel after the system woke up from a sleep that began
+// at suspendedAt. A session found down ends at suspendedAt rather than at the
+// time of the check; with resume reconnect on, the last location is
+// reconnected right away.
+func (v *VPNManager) Resumed(ctx context.Context, suspendedAt time.Time) {
+	loc, wasConnected := v.ConnectedLocation()
+	fmt.Printf("resumed after sleep since %s, checking the tunnel\n", suspendedAt.Format(time.DateTime))
+
+	v.historyMx.Lock()
+	v.suspendedAt = suspendedAt
+	v.historyMx.Unlock()
+	v.checkStatus(ctx)
+	v.historyMx.Lock()
+	v.suspendedAt = time.Time{}
+	v.historyMx.Unlock()
+
+	v.statemx.Lock()
+	reconnect := v.resumeReconnect
+	v.statemx.Unlock()
+	if !wasConnected || !reconnect || v.ConnectionState().State != StateDisconnected {
+		return
+	}
+
+	v.stopWatchdog()
+	reason := "reconnect to " + loc.City + " after resume"
+	if err := v.connectToLocation(ctx, loc); err != nil || !v.IsConnected() {
+		v.appendHistoryEntry(ConnectionHistoryEntry{
+			City:    loc.City,
+			Country: loc.Country,
+			Ping:    loc.Ping,
+			Reason:  reason + " failed: " + failureMessage(err),
+		})
+		return
+	}
+	v.annotateActiveConnection(reason)
+}
+
+// resuming reports whether a status check after a resume is running.
+func (v *VPNManager) resuming() bool {
+	v.historyMx.Lock()
+	defer v.historyMx.Unlock()
+	_, ok := v.sleepStartLocked()
+	return ok
+}
+
+// sleepStartLocked returns the start of the sleep being recovered from, if any.
+func (v *VPNManager) sleepStartLocked() (time.Time, bool) {
+	return v.suspendedAt, !v.suspendedAt.IsZero()
+}
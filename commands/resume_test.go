@@ -0,0 +1,149 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resume from suspend", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-resume-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	suspended := start.Add(time.Hour)
+	connectRiga := commands.TranscriptEntry{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"}
+
+	It("ends the session at the suspend time when the tunnel is gone", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			connectRiga,
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		clock := &fakeClock{now: start}
+		mgr.SetClock(clock)
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+		clock.now = start.Add(9 * time.Hour)
+		mgr.Resumed(context.Background(), suspended)
+
+		Expect(mgr.IsConnected()).To(BeFalse())
+		history := mgr.ConnectionHistory()
+		Expect(history).To(HaveLen(1))
+		Expect(*history[0].EndedAt).To(Equal(suspended))
+		Expect(history[0].Reason).To(Equal("disconnected during sleep"))
+	})
+
+	It("keeps a session that survived the sleep", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			connectRiga,
+			{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+			{Args: []string{"list-locations"}, Output: "ISO   COUNTRY   CITY   PING ESTIMATE\nLV    Latvia    Riga   29\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetClock(&fakeClock{now: start})
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+		mgr.Resumed(context.Background(), suspended)
+
+		Expect(mgr.IsConnected()).To(BeTrue())
+		history := mgr.ConnectionHistory()
+		Expect(history).To(HaveLen(1))
+		Expect(history[0].EndedAt).To(BeNil())
+		Expect(history[0].Reason).To(BeEmpty())
+	})
+
+	It("reconnects to the same location when enabled", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			connectRiga,
+			connectRiga,
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		})
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+		clock := &fakeClock{now: start}
+		mgr.SetClock(clock)
+		mgr.SetResumeReconnect(true)
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+
+		clock.now = start.Add(9 * time.Hour)
+		mgr.Resumed(context.Background(), suspended)
+
+		Expect(mgr.IsConnected()).To(BeTrue())
+		Expect(runner.Starts("connect -l Riga")).To(Equal(2))
+		history := mgr.ConnectionHistory()
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].StartedAt).To(Equal(clock.now))
+		Expect(history[0].Reason).To(Equal("reconnect to Riga after resume"))
+		Expect(*history[1].EndedAt).To(Equal(suspended))
+	})
+
+	It("reconnects off the status loop and coalesces resumes meanwhile", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			connectRiga,
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		})
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+		mgr.SetResumeReconnect(true)
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		runner.Hold("connect")
+
+		// Returns while the reconnect waits for the CLI.
+		mgr.WokeUp(context.Background(), suspended)
+		Eventually(func() int { return runner.Starts("connect -l Riga") }, 5*time.Second).Should(Equal(2))
+		mgr.WokeUp(context.Background(), suspended.Add(time.Minute))
+		mgr.WokeUp(context.Background(), suspended.Add(2*time.Minute))
+		Consistently(func() int { return runner.Starts("connect -l Riga") }, 200*time.Millisecond).Should(Equal(2))
+
+		close(runner.release)
+		Eventually(func() int { return runner.Starts("connect -l Riga") }, 5*time.Second).Should(Equal(3))
+		Consistently(func() int { return runner.Starts("connect -l Riga") }, 300*time.Millisecond).Should(Equal(3))
+	})
+})
@@ -0,0 +1,394 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoTranscript is returned by ReplayRunner when no recorded output matches the arguments.
+var ErrNoTranscript = errors.New("no recorded transcript for command")
+
+// CLIRequest describes one adguardvpn-cli invocation.
+type CLIRequest struct {
+	Args []string
+	// Env is the complete child environment; nil inherits the parent environment.
+	Env []string
+	// OnOutput, when set, receives each output line (without the newline) as
+	// the invocation produces it. Calls come from one goroutine at a time.
+	OnOutput func(line string)
+}
+
+// CLIProcess is a started adguardvpn-cli invocation.
+type CLIProcess interface {
+	// Path returns the executable serving the invocation.
+	Path() string
+	// PID returns the OS process ID, or 0 when no real process backs the invocation.
+	PID() int
+	// Signal delivers sig to the running invocation.
+	Signal(sig os.Signal) error
+	// Wait blocks until the invocation finishes and returns its combined output.
+	// Every line has been passed to CLIRequest.OnOutput when it returns.
+	Wait() (string, error)
+}
+
+// CLIRunner starts adguardvpn-cli invocations for VPNManager.
+type CLIRunner interface {
+	Start(req CLIRequest) (CLIProcess, error)
+}
+
+// ExitError reports a non-zero exit status from runners that do not spawn processes.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode extracts the exit status from an invocation error.
+// It returns 0 for nil and -1 when the error carries no exit status.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode()
+	}
+	return -1
+}
+
+// ExecRunner runs the real adguardvpn-cli resolved from ADGUARD_CMD.
+type ExecRunner struct{}
+
+// NewExecRunner returns a runner that spawns adguardvpn-cli processes.
+func NewExecRunner() *ExecRunner {
+	return &ExecRunner{}
+}
+
+// Start spawns the CLI detached from the controlling terminal.
+func (r *ExecRunner) Start(req CLIRequest) (CLIProcess, error) {
+	path := resolveCommandPath()
+	cmd := exec.Command(path, req.Args...)
+	cmd.Env = req.Env
+	prepareCLICommand(cmd)
+
+	proc := &execProcess{path: path, cmd: cmd, out: outputWriter{onLine: req.OnOutput}}
+	cmd.Stdout = &proc.out
+	cmd.Stderr = &proc.out
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
+
+type execProcess struct {
+	path string
+	cmd  *exec.Cmd
+	out  outputWriter
+}
+
+func (p *execProcess) Path() string {
+	return p.path
+}
+
+func (p *execProcess) PID() int {
+	if p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+func (p *execProcess) Signal(sig os.Signal) error {
+	if p.cmd.Process == nil {
+		return os.ErrProcessDone
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *execProcess) Wait() (string, error) {
+	err := p.cmd.Wait()
+	p.out.flush()
+	return p.out.buf.String(), err
+}
+
+// outputWriter keeps the combined output and passes complete lines to onLine.
+type outputWriter struct {
+	buf     bytes.Buffer
+	partial []byte
+	onLine  func(line string)
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.onLine == nil {
+		return len(p), nil
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(strings.TrimSuffix(string(w.partial[:i]), "\r"))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes an unterminated last line to onLine.
+func (w *outputWriter) flush() {
+	if w.onLine != nil && len(w.partial) > 0 {
+		w.onLine(strings.TrimSuffix(string(w.partial), "\r"))
+	}
+	w.partial = nil
+}
+
+// TranscriptEntry is one recorded CLI invocation.
+type TranscriptEntry struct {
+	Args     []string `json:"args"`
+	Output   string   `json:"output"`
+	ExitCode int      `json:"exit_code"`
+}
+
+// RecordingRunner delegates to another runner and appends every finished
+// invocation to a JSON Lines transcript file. The transcript is readable by
+// the owner only, and e-mail addresses, license keys and IPv4 addresses are
+// redacted from the output unless SetRaw turns redaction off.
+type RecordingRunner struct {
+	next CLIRunner
+	path string
+	raw  atomic.Bool
+	mx   sync.Mutex
+}
+
+// NewRecordingRunner wraps next and records its invocations to path.
+func NewRecordingRunner(next CLIRunner, path string) *RecordingRunner {
+	return &RecordingRunner{next: next, path: path}
+}
+
+// SetRaw records the output as the CLI printed it, account data included,
+// e.g. to capture reference output.
+func (r *RecordingRunner) SetRaw(raw bool) {
+	r.raw.Store(raw)
+}
+
+// Start starts the invocation on the wrapped runner.
+func (r *RecordingRunner) Start(req CLIRequest) (CLIProcess, error) {
+	proc, err := r.next.Start(req)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedProcess{CLIProcess: proc, runner: r, args: req.Args}, nil
+}
+
+func (r *RecordingRunner) append(entry TranscriptEntry) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if !r.raw.Load() {
+		entry.Output = redactPersonalData(entry.Output)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode transcript entry: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write transcript entry: %w", err)
+	}
+	return nil
+}
+
+type recordedProcess struct {
+	CLIProcess
+	runner *RecordingRunner
+	args   []string
+}
+
+func (p *recordedProcess) Wait() (string, error) {
+	output, err := p.CLIProcess.Wait()
+	entry := TranscriptEntry{
+		Args:     append([]string(nil), p.args...),
+		Output:   output,
+		ExitCode: ExitCode(err),
+	}
+	if recErr := p.runner.append(entry); recErr != nil {
+		fmt.Printf("record CLI transcript error: %v\n", recErr)
+	}
+	return output, err
+}
+
+// transcriptAliases maps file names used in cli-reference-output/ to CLI arguments.
+var transcriptAliases = map[string]string{
+	"list": "list-locations",
+}
+
+// LoadTranscript reads a JSON Lines transcript written by RecordingRunner.
+func LoadTranscript(path string) ([]TranscriptEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode transcript entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return entries, nil
+}
+
+// LoadTranscriptDir reads raw CLI output files like the ones in cli-reference-output/.
+// Each file name holds the arguments separated by underscores (site-exclusions_show);
+// the file content is served with exit code 0.
+func LoadTranscriptDir(dir string) ([]TranscriptEntry, error) {
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript directory: %w", err)
+	}
+
+	var entries []TranscriptEntry
+	for _, item := range items {
+		if item.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, item.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transcript %s: %w", item.Name(), err)
+		}
+		name := item.Name()
+		if alias, ok := transcriptAliases[name]; ok {
+			name = alias
+		}
+		entries = append(entries, TranscriptEntry{
+			Args:   strings.Split(name, "_"),
+			Output: string(data),
+		})
+	}
+	return entries, nil
+}
+
+// ReplayRunner serves recorded transcripts instead of spawning the CLI.
+// Entries with the same arguments are served in recording order; the last one
+// keeps being repeated once the earlier ones are consumed.
+type ReplayRunner struct {
+	mx      sync.Mutex
+	entries map[string][]TranscriptEntry
+}
+
+// NewReplayRunner builds a runner that answers from entries.
+func NewReplayRunner(entries []TranscriptEntry) *ReplayRunner {
+	r := &ReplayRunner{entries: make(map[string][]TranscriptEntry)}
+	for _, entry := range entries {
+		key := transcriptKey(entry.Args)
+		r.entries[key] = append(r.entries[key], entry)
+	}
+	return r
+}
+
+// Start returns a finished process with the next recorded output for req.Args.
+func (r *ReplayRunner) Start(req CLIRequest) (CLIProcess, error) {
+	key := transcriptKey(req.Args)
+
+	r.mx.Lock()
+	queue := r.entries[key]
+	if len(queue) == 0 {
+		r.mx.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNoTranscript, strings.Join(req.Args, " "))
+	}
+	entry := queue[0]
+	if len(queue) > 1 {
+		r.entries[key] = queue[1:]
+	}
+	r.mx.Unlock()
+
+	return &replayProcess{entry: entry, onLine: req.OnOutput}, nil
+}
+
+func transcriptKey(args []string) string {
+	return strings.Join(args, "\x00")
+}
+
+type replayProcess struct {
+	entry  TranscriptEntry
+	onLine func(line string)
+}
+
+func (p *replayProcess) Path() string {
+	return "replay"
+}
+
+func (p *replayProcess) PID() int {
+	return 0
+}
+
+func (p *replayProcess) Signal(os.Signal) error {
+	return os.ErrProcessDone
+}
+
+func (p *replayProcess) Wait() (string, error) {
+	if p.onLine != nil {
+		out := outputWriter{onLine: p.onLine}
+		_, _ = out.Write([]byte(p.entry.Output))
+		out.flush()
+	}
+	if p.entry.ExitCode != 0 {
+		return p.entry.Output, &ExitError{Code: p.entry.ExitCode}
+	}
+	return p.entry.Output, nil
+}
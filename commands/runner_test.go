@@ -0,0 +1,231 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CLI runners", func() {
+	Context("exec", func() {
+		It("streams output lines while the command runs", func() {
+			dir := GinkgoT().TempDir()
+			script := filepath.Join(dir, "adguardvpn-cli")
+			Expect(os.WriteFile(script, []byte("#!/bin/sh\necho one\nsleep 0.3\nprintf two\n"), 0o755)).To(Succeed())
+			oldCmd, hadCmd := os.LookupEnv("ADGUARD_CMD")
+			Expect(os.Setenv("ADGUARD_CMD", script)).To(Succeed())
+			defer func() {
+				if hadCmd {
+					_ = os.Setenv("ADGUARD_CMD", oldCmd)
+				} else {
+					_ = os.Unsetenv("ADGUARD_CMD")
+				}
+			}()
+
+			lines := make(chan string, 4)
+			proc, err := commands.NewExecRunner().Start(commands.CLIRequest{
+				Args:     []string{"status"},
+				OnOutput: func(line string) { lines <- line },
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(lines).Should(Receive(Equal("one")))
+
+			output, err := proc.Wait()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal("one\ntwo"))
+			Expect(lines).To(Receive(Equal("two")))
+		})
+	})
+
+	Context("replay", func() {
+		It("serves raw outputs from cli-reference-output", func() {
+			entries, err := commands.LoadTranscriptDir(filepath.Join("..", "cli-reference-output"))
+			Expect(err).NotTo(HaveOccurred())
+
+			runner := commands.NewReplayRunner(entries)
+			proc, err := runner.Start(commands.CLIRequest{Args: []string{"status"}})
+			Expect(err).NotTo(HaveOccurred())
+			output, err := proc.Wait()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.ParseLocationFromStatus(output)).To(Equal("FRANKFURT"))
+
+			proc, err = runner.Start(commands.CLIRequest{Args: []string{"list-locations"}})
+			Expect(err).NotTo(HaveOccurred())
+			output, err = proc.Wait()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(ContainSubstring("Frankfurt"))
+		})
+
+		It("serves entries in order and repeats the last one", func() {
+			runner := commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+				{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode\n"},
+			})
+
+			outputs := make([]string, 0, 3)
+			for range 3 {
+				proc, err := runner.Start(commands.CLIRequest{Args: []string{"status"}})
+				Expect(err).NotTo(HaveOccurred())
+				output, err := proc.Wait()
+				Expect(err).NotTo(HaveOccurred())
+				outputs = append(outputs, output)
+			}
+			Expect(outputs).To(Equal([]string{
+				"VPN is disconnected\n",
+				"Connected to RIGA in TUN mode\n",
+				"Connected to RIGA in TUN mode\n",
+			}))
+		})
+
+		It("reports recorded exit codes and missing transcripts", func() {
+			runner := commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"disconnect"}, Output: "failed\n", ExitCode: 2},
+			})
+
+			proc, err := runner.Start(commands.CLIRequest{Args: []string{"disconnect"}})
+			Expect(err).NotTo(HaveOccurred())
+			output, err := proc.Wait()
+			Expect(output).To(Equal("failed\n"))
+			Expect(commands.ExitCode(err)).To(Equal(2))
+
+			_, err = runner.Start(commands.CLIRequest{Args: []string{"license"}})
+			Expect(err).To(MatchError(commands.ErrNoTranscript))
+		})
+	})
+
+	Context("recording", func() {
+		It("writes a transcript that replays the same invocations", func() {
+			dir, err := os.MkdirTemp("", "adgui-transcript-*")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = os.RemoveAll(dir) }()
+			path := filepath.Join(dir, "session.jsonl")
+
+			source := commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"license"}, Output: "Logged in as user\n"},
+				{Args: []string{"connect", "-l", "Nowhere"}, Output: "unknown location\n", ExitCode: 1},
+			})
+			recorder := commands.NewRecordingRunner(source, path)
+			for _, args := range [][]string{{"license"}, {"connect", "-l", "Nowhere"}} {
+				proc, err := recorder.Start(commands.CLIRequest{Args: args})
+				Expect(err).NotTo(HaveOccurred())
+				_, _ = proc.Wait()
+			}
+
+			recorded, err := commands.LoadTranscript(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(Equal([]commands.TranscriptEntry{
+				{Args: []string{"license"}, Output: "Logged in as user\n"},
+				{Args: []string{"connect", "-l", "Nowhere"}, Output: "unknown location\n", ExitCode: 1},
+			}))
+		})
+
+		It("keeps the transcript private and redacts account data unless raw", func() {
+			dir, err := os.MkdirTemp("", "adgui-transcript-*")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = os.RemoveAll(dir) }()
+			path := filepath.Join(dir, "logs", "session.jsonl")
+
+			output := "Logged in as user@example.com\nLicense key: ABCD1234EFGH5678IJKL\n"
+			source := commands.NewReplayRunner([]commands.TranscriptEntry{{Args: []string{"license"}, Output: output}})
+			recorder := commands.NewRecordingRunner(source, path)
+			record := func() {
+				proc, err := recorder.Start(commands.CLIRequest{Args: []string{"license"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(proc.Wait()).To(Equal(output))
+			}
+			record()
+			recorder.SetRaw(true)
+			record()
+
+			recorded, err := commands.LoadTranscript(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(HaveLen(2))
+			Expect(recorded[0].Output).To(Equal("Logged in as <email>\nLicense key: <redacted>\n"))
+			Expect(recorded[1].Output).To(Equal(output))
+
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+			info, err = os.Stat(filepath.Dir(path))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o700)))
+		})
+	})
+
+	Context("VPNManager on a replay runner", func() {
+		var (
+			tempHome    string
+			oldHome     string
+			oldSudoWrap string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempHome, err = os.MkdirTemp("", "adgui-replay-home-*")
+			Expect(err).NotTo(HaveOccurred())
+			oldHome = os.Getenv("HOME")
+			oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+			Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+			Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if oldHome != "" {
+				_ = os.Setenv("HOME", oldHome)
+			}
+			if oldSudoWrap != "" {
+				_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+			} else {
+				_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+			}
+			_ = os.RemoveAll(tempHome)
+		})
+
+		It("runs connect and disconnect flows without the real CLI", func() {
+			list, err := os.ReadFile(filepath.Join("..", "cli-reference-output", "list"))
+			Expect(err).NotTo(HaveOccurred())
+
+			mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+				{Args: []string{"list-locations"}, Output: string(list)},
+				{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to Riga\n"},
+				{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+			}))
+			defer func() { _ = mgr.Close() }()
+
+			locs, err := mgr.ListLocations(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locs).NotTo(BeEmpty())
+			Expect(locs[0].City).To(Equal("Riga"))
+
+			Expect(mgr.ConnectToLocation(context.Background(), locs[0])).To(Succeed())
+			loc, connected := mgr.ConnectedLocation()
+			Expect(connected).To(BeTrue())
+			Expect(loc.Country).To(Equal("Latvia"))
+			Expect(mgr.StatusDetails().City).To(Equal("Riga"))
+
+			Expect(mgr.Disconnect(context.Background())).To(Succeed())
+			Expect(mgr.IsConnected()).To(BeFalse())
+			Expect(mgr.StatusDetails()).To(Equal(commands.Status{State: commands.StateDisconnected}))
+			Expect(mgr.PreviousConnectionHistory()).To(HaveLen(1))
+		})
+	})
+})
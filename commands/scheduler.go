@@ -0,0 +1,227 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// connectKey marks connect operations: a newer connect replaces a queued one.
+const connectKey = "connect"
+
+// ErrSuperseded is returned by a connect that was still waiting for its turn
+// when a newer connect request replaced it.
+var ErrSuperseded = errors.New("superseded by a newer request")
+
+// CommandClass tells the scheduler whether an adguardvpn-cli command changes
+// VPN or exclusion state.
+type CommandClass int
+
+const (
+	// CommandReadOnly commands may run concurrently; identical concurrent
+	// invocations share one process.
+	CommandReadOnly CommandClass = iota
+	// CommandMutating commands run one operation at a time.
+	CommandMutating
+)
+
+func (c CommandClass) String() string {
+	if c == CommandReadOnly {
+		return "read-only"
+	}
+	return "mutating"
+}
+
+// ClassifyCommand returns the class of the CLI invocation args.
+// Unknown commands count as mutating.
+func ClassifyCommand(args []string) CommandClass {
+	if len(args) == 0 {
+		return CommandMutating
+	}
+	switch args[0] {
+	case "status", "list-locations", "license", "--version":
+		return CommandReadOnly
+	case "site-exclusions":
+		if len(args) > 1 && args[1] == "show" {
+			return CommandReadOnly
+		}
+	}
+	return CommandMutating
+}
+
+// scheduler serializes mutating operations and coalesces concurrent
+// identical read-only commands.
+type scheduler struct {
+	mx      sync.Mutex
+	running bool
+	queue   []*schedTicket
+	// epoch changes when a mutating operation starts or finishes, so a
+	// read-only result can be checked for overlap with one.
+	epoch   uint64
+	flights map[string]*flight
+}
+
+// schedTicket is a mutating operation waiting for its turn.
+type schedTicket struct {
+	key   string
+	ready chan struct{}
+	// err is set instead of granting the turn; both happen under scheduler.mx.
+	err     error
+	granted bool
+}
+
+// flight is one running read-only command shared by its callers.
+type flight struct {
+	done   chan struct{}
+	cancel context.CancelCauseFunc
+	refs   int
+	epoch  uint64
+	output string
+	err    error
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{flights: make(map[string]*flight)}
+}
+
+// acquire waits until every mutating operation queued before this one has
+// finished. A non-empty key lets a newer operation with the same key replace
+// this one while it waits; the replaced one gets ErrSuperseded. Waiting ends
+// early with the context cause when ctx is done. Each successful acquire must
+// be followed by release.
+func (s *scheduler) acquire(ctx context.Context, key string) error {
+	s.mx.Lock()
+	if !s.running && len(s.queue) == 0 {
+		s.running = true
+		s.epoch++
+		s.mx.Unlock()
+		return nil
+	}
+	if key != "" {
+		for i, queued := range s.queue {
+			if queued.key == key {
+				queued.err = ErrSuperseded
+				close(queued.ready)
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	ticket := &schedTicket{key: key, ready: make(chan struct{})}
+	s.queue = append(s.queue, ticket)
+	s.mx.Unlock()
+
+	select {
+	case <-ticket.ready:
+		return ticket.err
+	case <-ctx.Done():
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if ticket.granted {
+		// The turn arrived together with the cancellation: pass it on.
+		s.handOverLocked()
+		return context.Cause(ctx)
+	}
+	if ticket.err != nil {
+		return ticket.err
+	}
+	for i, queued := range s.queue {
+		if queued == ticket {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	return context.Cause(ctx)
+}
+
+func (s *scheduler) release() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.handOverLocked()
+}
+
+// handOverLocked ends the running operation and starts the next queued one.
+func (s *scheduler) handOverLocked() {
+	s.epoch++
+	if len(s.queue) == 0 {
+		s.running = false
+		return
+	}
+	next := s.queue[0]
+	s.queue = s.queue[1:]
+	next.granted = true
+	close(next.ready)
+	s.epoch++
+}
+
+// Epoch returns the current mutating operation epoch.
+func (s *scheduler) Epoch() uint64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.epoch
+}
+
+// share runs run once for all concurrent callers with the same args and
+// returns its result with the epoch seen when it started. The command is
+// cancelled only when every caller waiting for it has given up.
+func (s *scheduler) share(ctx context.Context, args []string, run func(context.Context) (string, error)) (string, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return "", 0, context.Cause(ctx)
+	}
+	key := strings.Join(args, "\x00")
+
+	s.mx.Lock()
+	f, ok := s.flights[key]
+	if !ok {
+		fctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel, epoch: s.epoch}
+		s.flights[key] = f
+		go func() {
+			f.output, f.err = run(fctx)
+			s.mx.Lock()
+			if s.flights[key] == f {
+				delete(s.flights, key)
+			}
+			s.mx.Unlock()
+			cancel(nil)
+			close(f.done)
+		}()
+	}
+	f.refs++
+	s.mx.Unlock()
+
+	select {
+	case <-f.done:
+		return f.output, f.epoch, f.err
+	case <-ctx.Done():
+	}
+
+	s.mx.Lock()
+	f.refs--
+	if f.refs == 0 {
+		f.cancel(context.Cause(ctx))
+		if s.flights[key] == f {
+			delete(s.flights, key)
+		}
+	}
+	s.mx.Unlock()
+	return "", f.epoch, context.Cause(ctx)
+}
@@ -0,0 +1,228 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// heldRunner counts invocations and holds the listed commands until release is closed.
+type heldRunner struct {
+	next    commands.CLIRunner
+	hold    map[string]bool
+	release chan struct{}
+
+	mx     sync.Mutex
+	starts map[string]int
+}
+
+func newHeldRunner(entries []commands.TranscriptEntry, hold ...string) *heldRunner {
+	r := &heldRunner{
+		next:    commands.NewReplayRunner(entries),
+		hold:    make(map[string]bool),
+		release: make(chan struct{}),
+		starts:  make(map[string]int),
+	}
+	for _, command := range hold {
+		r.hold[command] = true
+	}
+	return r
+}
+
+func (r *heldRunner) Start(req commands.CLIRequest) (commands.CLIProcess, error) {
+	r.mx.Lock()
+	r.starts[strings.Join(req.Args, " ")]++
+	held := len(req.Args) > 0 && r.hold[req.Args[0]]
+	r.mx.Unlock()
+	if held {
+		<-r.release
+	}
+	return r.next.Start(req)
+}
+
+// Hold starts holding command, e.g. after the setup ran it.
+func (r *heldRunner) Hold(command string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.hold[command] = true
+}
+
+func (r *heldRunner) Starts(command string) int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.starts[command]
+}
+
+var _ = Describe("Command scheduler", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-scheduler-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+
+	DescribeTable("classifies CLI commands",
+		func(args []string, class commands.CommandClass) {
+			Expect(commands.ClassifyCommand(args)).To(Equal(class))
+		},
+		Entry("status", []string{"status"}, commands.CommandReadOnly),
+		Entry("list-locations", []string{"list-locations"}, commands.CommandReadOnly),
+		Entry("license", []string{"license"}, commands.CommandReadOnly),
+		Entry("site-exclusions show", []string{"site-exclusions", "show"}, commands.CommandReadOnly),
+		Entry("connect", []string{"connect", "-l", "Riga"}, commands.CommandMutating),
+		Entry("disconnect", []string{"disconnect"}, commands.CommandMutating),
+		Entry("site-exclusions add", []string{"site-exclusions", "add", "example.com"}, commands.CommandMutating),
+		Entry("unknown", []string{"login"}, commands.CommandMutating),
+	)
+
+	It("runs concurrent identical read-only commands once", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"list-locations"}, Output: "ISO   COUNTRY   CITY   PING ESTIMATE\nLV    Latvia    Riga   29\n"},
+		}, "list-locations")
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+
+		results := make(chan []locations.Location, 3)
+		for range 3 {
+			go func() {
+				defer GinkgoRecover()
+				locs, err := mgr.ListLocations(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				results <- locs
+			}()
+		}
+		Eventually(func() int { return runner.Starts("list-locations") }).Should(Equal(1))
+		Consistently(results, 50*time.Millisecond).ShouldNot(Receive())
+
+		close(runner.release)
+		for range 3 {
+			Eventually(results).Should(Receive(HaveLen(1)))
+		}
+		Expect(runner.Starts("list-locations")).To(Equal(1))
+	})
+
+	It("replaces a queued connect with a newer one", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"connect"}, Output: "Successfully Connected to FRANKFURT\n"},
+			{Args: []string{"list-locations"}, Output: "ISO   COUNTRY   CITY        PING ESTIMATE\nDE    Germany   Frankfurt   40\n"},
+		}, "connect")
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+
+		first := make(chan error, 1)
+		go func() { first <- mgr.ConnectToLocation(context.Background(), riga) }()
+		Eventually(func() int { return runner.Starts("connect -l Riga") }).Should(Equal(1))
+
+		queued := make(chan error, 1)
+		go func() { queued <- mgr.ConnectToLocation(context.Background(), riga) }()
+		Consistently(queued, 50*time.Millisecond).ShouldNot(Receive())
+
+		newest := make(chan error, 1)
+		go func() { newest <- mgr.ConnectAuto(context.Background()) }()
+		var err error
+		Eventually(queued).Should(Receive(&err))
+		Expect(errors.Is(err, commands.ErrSuperseded)).To(BeTrue())
+
+		close(runner.release)
+		Eventually(first).Should(Receive(BeNil()))
+		Eventually(newest).Should(Receive(BeNil()))
+		Expect(mgr.Location()).To(Equal("Frankfurt"))
+		Expect(runner.Starts("connect -l Riga")).To(Equal(1))
+	})
+
+	It("gives up a queued operation when its context is cancelled", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+		}, "connect")
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+
+		first := make(chan error, 1)
+		go func() { first <- mgr.ConnectToLocation(context.Background(), riga) }()
+		Eventually(func() int { return runner.Starts("connect -l Riga") }).Should(Equal(1))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		queued := make(chan error, 1)
+		go func() { queued <- mgr.Disconnect(ctx) }()
+		Consistently(queued, 50*time.Millisecond).ShouldNot(Receive())
+		cancel()
+		var err error
+		Eventually(queued).Should(Receive(&err))
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorCanceled))
+
+		close(runner.release)
+		Eventually(first).Should(Receive(BeNil()))
+		Expect(mgr.IsConnected()).To(BeTrue())
+		Expect(runner.Starts("disconnect")).To(BeZero())
+	})
+
+	It("drops a status result that overlapped a connect", func() {
+		runner := newHeldRunner([]commands.TranscriptEntry{
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+		}, "status")
+		mgr := commands.New(runner)
+		defer func() { _ = mgr.Close() }()
+
+		refreshed := make(chan struct{})
+		go func() {
+			defer close(refreshed)
+			mgr.RefreshStatus(context.Background())
+		}()
+		Eventually(func() int { return runner.Starts("status") }).Should(Equal(1))
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		close(runner.release)
+		Eventually(refreshed).Should(BeClosed())
+		Expect(mgr.IsConnected()).To(BeTrue())
+		Expect(mgr.Location()).To(Equal("Riga"))
+	})
+})
@@ -0,0 +1,95 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sleepwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindName      = "org.freedesktop.login1"
+	logindPath      = dbus.ObjectPath("/org/freedesktop/login1")
+	logindInterface = "org.freedesktop.login1.Manager"
+)
+
+// ErrNoLogind is returned by WatchLogind when nobody owns org.freedesktop.login1.
+var ErrNoLogind = errors.New("logind is not running")
+
+// WatchLogind reports resumes from PrepareForSleep signals on conn, which
+// should be the system bus. The channel is closed when ctx ends.
+func WatchLogind(ctx context.Context, conn *dbus.Conn) (<-chan Event, error) {
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, logindName).Store(&running); err != nil {
+		return nil, fmt.Errorf("failed to look up logind: %w", err)
+	}
+	if !running {
+		return nil, ErrNoLogind
+	}
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindInterface),
+		dbus.WithMatchMember("PrepareForSleep"),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to logind signals: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 4)
+	conn.Signal(signals)
+
+	events := make(chan Event, 1)
+	go func() {
+		defer close(events)
+		defer func() {
+			conn.RemoveSignal(signals)
+			_ = conn.RemoveMatchSignal(match...)
+		}()
+		var suspended time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if sig.Name != logindInterface+".PrepareForSleep" || len(sig.Body) != 1 {
+					continue
+				}
+				start, _ := sig.Body[0].(bool)
+				now := time.Now().Round(0)
+				if start {
+					suspended = now
+					continue
+				}
+				if suspended.IsZero() {
+					suspended = now
+				}
+				select {
+				case events <- Event{Suspended: suspended, Resumed: now}:
+				case <-ctx.Done():
+					return
+				}
+				suspended = time.Time{}
+			}
+		}
+	}()
+	return events, nil
+}
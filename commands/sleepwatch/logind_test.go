@@ -0,0 +1,93 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sleepwatch_test
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"adgui/commands/sleepwatch"
+
+	"github.com/godbus/dbus/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// startPrivateBus runs a throwaway dbus-daemon standing in for the system bus.
+func startPrivateBus() string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cmd.Start()).To(Succeed())
+	DeferCleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	Expect(err).NotTo(HaveOccurred())
+	return strings.TrimSpace(address)
+}
+
+func connectBus(address string) *dbus.Conn {
+	conn, err := dbus.Connect(address)
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+var _ = Describe("logind watcher", func() {
+	It("fails without logind on the bus", func() {
+		conn := connectBus(startPrivateBus())
+		_, err := sleepwatch.WatchLogind(context.Background(), conn)
+		Expect(err).To(MatchError(sleepwatch.ErrNoLogind))
+	})
+
+	It("reports a resume with the suspend time", func() {
+		address := startPrivateBus()
+		logind := connectBus(address)
+		reply, err := logind.RequestName("org.freedesktop.login1", dbus.NameFlagDoNotQueue)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reply).To(Equal(dbus.RequestNameReplyPrimaryOwner))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := sleepwatch.WatchLogind(ctx, connectBus(address))
+		Expect(err).NotTo(HaveOccurred())
+
+		emit := func(start bool) {
+			Expect(logind.Emit("/org/freedesktop/login1", "org.freedesktop.login1.Manager.PrepareForSleep", start)).To(Succeed())
+		}
+		before := time.Now().Round(0)
+		emit(true)
+		time.Sleep(50 * time.Millisecond)
+		emit(false)
+
+		var event sleepwatch.Event
+		Eventually(events, 2*time.Second).Should(Receive(&event))
+		Expect(event.Suspended).To(BeTemporally(">=", before))
+		Expect(event.Resumed.Sub(event.Suspended)).To(BeNumerically(">=", 40*time.Millisecond))
+
+		cancel()
+		Eventually(events).Should(BeClosed())
+	})
+})
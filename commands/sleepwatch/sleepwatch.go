@@ -0,0 +1,103 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package sleepwatch reports system resumes from suspend, from the logind
+// PrepareForSleep signal or, without logind, from the wall clock running
+// ahead of the monotonic clock, which stops while the system sleeps.
+package sleepwatch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// ClockInterval is how often the clock detector compares the clocks.
+	ClockInterval = 10 * time.Second
+	// ClockThreshold is the smallest clock gap reported as a sleep, so that
+	// small NTP adjustments are not.
+	ClockThreshold = 30 * time.Second
+)
+
+// Event describes one finished sleep.
+type Event struct {
+	// Suspended is when the system went to sleep; the clock detector
+	// knows it only to within ClockInterval.
+	Suspended time.Time
+	Resumed   time.Time
+}
+
+// Watch reports resumes through logind on the system bus when it is running,
+// otherwise through the clock detector. The channel is closed when ctx ends.
+func Watch(ctx context.Context) <-chan Event {
+	events, err := watchSystemLogind(ctx)
+	if err == nil {
+		return events
+	}
+	fmt.Printf("logind sleep signals unavailable (%v), watching the clock\n", err)
+	return WatchClock(ctx, ClockInterval, ClockThreshold)
+}
+
+func watchSystemLogind(ctx context.Context) (<-chan Event, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	events, err := WatchLogind(ctx, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	return events, nil
+}
+
+// WatchClock compares wall and monotonic time every interval and reports
+// a sleep when the wall clock ran ahead by threshold or more.
+func WatchClock(ctx context.Context, interval, threshold time.Duration) <-chan Event {
+	events := make(chan Event, 1)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				// Round(0) drops the monotonic reading, so Sub compares wall time.
+				monotonic := now.Sub(prev)
+				gap := now.Round(0).Sub(prev.Round(0)) - monotonic
+				if gap >= threshold {
+					event := Event{Suspended: prev.Round(0).Add(monotonic / 2), Resumed: now.Round(0)}
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+				prev = now
+			}
+		}
+	}()
+	return events
+}
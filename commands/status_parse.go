@@ -15,7 +15,11 @@
 
 package commands
 
-import "strings"
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
 
 // ParseLocationFromStatus extracts the connected city/location name from CLI status output.
 func ParseLocationFromStatus(output string) string {
@@ -37,3 +41,83 @@ func ParseLocationFromStatus(output string) string {
 	}
 	return ""
 }
+
+// ErrUnknownStatus is returned by ParseStatus when the output matches neither
+// the connected nor the disconnected form.
+var ErrUnknownStatus = errors.New("unrecognized status output")
+
+// TunnelMode is the VPN mode reported by the CLI.
+type TunnelMode string
+
+const (
+	TunnelModeTUN   TunnelMode = "TUN"
+	TunnelModeSOCKS TunnelMode = "SOCKS"
+)
+
+// Status is the structured form of `adguardvpn-cli status` (or connect) output.
+type Status struct {
+	State ConnectionState // StateConnected or StateDisconnected
+	City  string
+	Mode  TunnelMode
+	// Interface is the TUN device name; empty in SOCKS mode.
+	Interface string
+	// SOCKSPort is the local proxy port in SOCKS mode; zero in TUN mode.
+	SOCKSPort int
+	// Warnings holds "Warning:" lines without the prefix.
+	Warnings []string
+	// DisconnectHint is the command the CLI suggests for disconnecting.
+	DisconnectHint string
+}
+
+// ParseStatus parses the full status output. It also accepts connect output,
+// which carries the same "Connected to" line and warnings.
+func ParseStatus(output string) (Status, error) {
+	var st Status
+	recognized := false
+	for line := range strings.SplitSeq(ansiStripRegex.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.Contains(line, statusDisconnected):
+			st.State = StateDisconnected
+			recognized = true
+		case strings.Contains(line, "Connected to "):
+			st.State = StateConnected
+			recognized = true
+			parseConnectedLine(&st, line)
+		case strings.HasPrefix(line, "Warning:"):
+			st.Warnings = append(st.Warnings, strings.TrimSpace(strings.TrimPrefix(line, "Warning:")))
+		case strings.HasPrefix(line, "You can disconnect by running"):
+			hint := strings.TrimPrefix(line, "You can disconnect by running")
+			st.DisconnectHint = strings.Trim(strings.TrimSpace(hint), "`")
+		}
+	}
+	if !recognized {
+		return Status{}, ErrUnknownStatus
+	}
+	return st, nil
+}
+
+// parseConnectedLine reads "Connected to CITY in MODE mode, running on IFACE|HOST:PORT".
+func parseConnectedLine(st *Status, line string) {
+	rest := line[strings.Index(line, "Connected to ")+len("Connected to "):]
+	rest, running, _ := strings.Cut(rest, ", running on ")
+	city, mode, found := strings.Cut(rest, " in ")
+	st.City = strings.TrimSpace(city)
+	if found {
+		mode = strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(mode), "mode")))
+		if strings.HasPrefix(mode, string(TunnelModeSOCKS)) {
+			st.Mode = TunnelModeSOCKS
+		} else {
+			st.Mode = TunnelMode(mode)
+		}
+	}
+	running = strings.TrimSpace(running)
+	if idx := strings.LastIndex(running, ":"); idx >= 0 {
+		if n, err := strconv.Atoi(running[idx+1:]); err == nil {
+			st.SOCKSPort = n
+		}
+		return
+	}
+	st.Interface = running
+}
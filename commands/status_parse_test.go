@@ -0,0 +1,64 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseStatus", func() {
+	It("parses the reference connected output", func() {
+		output, err := os.ReadFile(filepath.Join("..", "cli-reference-output", "status"))
+		Expect(err).NotTo(HaveOccurred())
+
+		st, err := commands.ParseStatus(string(output))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st).To(Equal(commands.Status{
+			State:          commands.StateConnected,
+			City:           "FRANKFURT",
+			Mode:           commands.TunnelModeTUN,
+			Interface:      "tun0",
+			Warnings:       []string{"System DNS could not be configured. DNS queries may bypass the VPN tunnel"},
+			DisconnectHint: "/opt/adguardvpn_cli/adguardvpn-cli disconnect",
+		}))
+	})
+
+	It("parses SOCKS mode from connect output", func() {
+		st, err := commands.ParseStatus("Successfully Connected to \x1b[1mNEW YORK\x1b[0m in \x1b[1mSOCKS5\x1b[0m mode, running on \x1b[1m127.0.0.1:1080\x1b[0m\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.State).To(Equal(commands.StateConnected))
+		Expect(st.City).To(Equal("NEW YORK"))
+		Expect(st.Mode).To(Equal(commands.TunnelModeSOCKS))
+		Expect(st.SOCKSPort).To(Equal(1080))
+		Expect(st.Interface).To(BeEmpty())
+	})
+
+	It("parses the disconnected output", func() {
+		st, err := commands.ParseStatus("VPN is disconnected\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st).To(Equal(commands.Status{State: commands.StateDisconnected}))
+	})
+
+	It("rejects unrelated output", func() {
+		_, err := commands.ParseStatus("You are not logged in\n")
+		Expect(err).To(MatchError(commands.ErrUnknownStatus))
+	})
+})
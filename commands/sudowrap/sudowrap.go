@@ -197,6 +197,21 @@ func (e *Env) Dir() string {
 	return e.dir
 }
 
+// SudoCommand returns the sudo command line for root helpers run by adgui
+// itself and its environment. While the wrapper is active that is the
+// wrapper's absolute path with the child environment, so askpass applies;
+// otherwise the real sudo with -n, as there is no terminal to prompt on.
+func (e *Env) SudoCommand() ([]string, []string) {
+	if e == nil || !e.enabled || e.dir == "" {
+		realSudo := sudoName
+		if e != nil && e.realSudo != "" {
+			realSudo = e.realSudo
+		}
+		return []string{realSudo, "-n"}, nil
+	}
+	return []string{filepath.Join(e.dir, sudoName)}, e.ChildEnv()
+}
+
 // HasPassword reports whether a session password is stored in memory.
 func (e *Env) HasPassword() bool {
 	if e == nil {
@@ -247,10 +262,21 @@ func ValidTicket(realSudo string) bool {
 // Apply injects a filtered child environment with wrapper PATH and SUDO_ASKPASS.
 // Parent process environment is never modified.
 func (e *Env) Apply(cmd *exec.Cmd) {
-	if e == nil || !e.enabled || e.dir == "" || cmd == nil {
+	if cmd == nil {
 		return
 	}
-	cmd.Env = buildChildEnv(e.dir, e.askpass)
+	if env := e.ChildEnv(); env != nil {
+		cmd.Env = env
+	}
+}
+
+// ChildEnv returns the filtered child environment used by Apply.
+// It returns nil when the wrapper is inactive, meaning the parent environment is inherited.
+func (e *Env) ChildEnv() []string {
+	if e == nil || !e.enabled || e.dir == "" {
+		return nil
+	}
+	return buildChildEnv(e.dir, e.askpass)
 }
 
 // buildChildEnv builds a whitelist-based environment for CLI and sudo children.
@@ -204,6 +204,23 @@ var _ = Describe("sudowrap", func() {
 		Expect(env.PassFileExists()).To(BeFalse())
 	})
 
+	It("SudoCommand runs the wrapper by absolute path with the child env", func() {
+		env, err := sudowrap.Setup(true, true)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = env.Close() }()
+
+		argv, childEnv := env.SudoCommand()
+		Expect(argv).To(Equal([]string{filepath.Join(env.Dir(), "sudo")}))
+		Expect(childEnv).To(ContainElement("SUDO_ASKPASS=" + filepath.Join(env.Dir(), "askpass")))
+
+		disabled, err := sudowrap.Setup(false, false)
+		Expect(err).NotTo(HaveOccurred())
+		argv, childEnv = disabled.SudoCommand()
+		Expect(argv).To(HaveLen(2))
+		Expect(argv[1]).To(Equal("-n"))
+		Expect(childEnv).To(BeNil())
+	})
+
 	It("disabled Setup returns env without runtime dir", func() {
 		env, err := sudowrap.Setup(false, false)
 		Expect(err).NotTo(HaveOccurred())
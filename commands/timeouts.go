@@ -0,0 +1,165 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"adgui/config"
+)
+
+// killGrace is how long a cancelled invocation gets between SIGTERM and SIGKILL.
+const killGrace = 500 * time.Millisecond
+
+// CommandTimeouts limits how long one adguardvpn-cli invocation may run.
+// A zero duration disables the limit.
+type CommandTimeouts struct {
+	Connect    time.Duration
+	Disconnect time.Duration
+	Status     time.Duration
+	// Default applies to every other command.
+	Default time.Duration
+}
+
+// DefaultCommandTimeouts returns the limits used when adguirc sets none.
+func DefaultCommandTimeouts() CommandTimeouts {
+	return CommandTimeouts{
+		Connect:    2 * time.Minute,
+		Disconnect: 30 * time.Second,
+		Status:     15 * time.Second,
+		Default:    30 * time.Second,
+	}
+}
+
+// loadCommandTimeouts reads the limits from adguirc. Keys that fail to parse
+// keep their defaults; the first error is returned.
+func loadCommandTimeouts() (CommandTimeouts, error) {
+	timeouts := DefaultCommandTimeouts()
+	var firstErr error
+	for _, item := range []struct {
+		dst  *time.Duration
+		load func() (time.Duration, error)
+	}{
+		{&timeouts.Connect, config.AdguardConnectTimeout},
+		{&timeouts.Disconnect, config.AdguardDisconnectTimeout},
+		{&timeouts.Status, config.AdguardStatusTimeout},
+		{&timeouts.Default, config.AdguardTimeout},
+	} {
+		value, err := item.load()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		*item.dst = value
+	}
+	return timeouts, firstErr
+}
+
+// For returns the limit for the CLI command named by the first argument.
+func (t CommandTimeouts) For(command string) time.Duration {
+	switch command {
+	case "connect":
+		return t.Connect
+	case "disconnect":
+		return t.Disconnect
+	case "status":
+		return t.Status
+	default:
+		return t.Default
+	}
+}
+
+// SetCommandTimeouts replaces the per-command limits for future invocations.
+func (v *VPNManager) SetCommandTimeouts(timeouts CommandTimeouts) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.timeouts = timeouts
+}
+
+func (v *VPNManager) commandTimeout(command string) time.Duration {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.timeouts.For(command)
+}
+
+// withCommandTimeout bounds ctx by the limit for args; the error returned after
+// the limit names the command and wraps context.DeadlineExceeded.
+func (v *VPNManager) withCommandTimeout(ctx context.Context, args []string) (context.Context, context.CancelFunc) {
+	if len(args) == 0 {
+		return context.WithCancel(ctx)
+	}
+	timeout := v.commandTimeout(args[0])
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	cause := fmt.Errorf("adguardvpn-cli %s timed out after %s: %w", strings.Join(args, " "), timeout, context.DeadlineExceeded)
+	return context.WithTimeoutCause(ctx, timeout, cause)
+}
+
+type waitResult struct {
+	output string
+	err    error
+}
+
+// waitContext waits for proc until ctx ends, then terminates its process group.
+// A process that ignores SIGKILL as well (e.g. a child holding the output pipe)
+// is abandoned after killGrace so the caller is never blocked.
+func waitContext(ctx context.Context, proc CLIProcess) (string, error) {
+	done := make(chan waitResult, 1)
+	go func() {
+		output, err := proc.Wait()
+		done <- waitResult{output: output, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.output, res.err
+	case <-ctx.Done():
+	}
+
+	var res waitResult
+	_ = signalGroup(proc, syscall.SIGTERM)
+	select {
+	case res = <-done:
+	case <-time.After(killGrace):
+		_ = signalGroup(proc, syscall.SIGKILL)
+		select {
+		case res = <-done:
+		case <-time.After(killGrace):
+		}
+	}
+	return res.output, context.Cause(ctx)
+}
+
+// signalGroup delivers sig to the process group of proc. The CLI runs with
+// Setsid, so its PID is the group ID shared with sudo and other children.
+// Processes without a group of their own get the signal directly.
+func signalGroup(proc CLIProcess, sig syscall.Signal) error {
+	if pid := proc.PID(); pid > 0 {
+		err := syscall.Kill(-pid, sig)
+		if !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return proc.Signal(sig)
+}
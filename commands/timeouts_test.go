@@ -0,0 +1,137 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cancellable CLI invocations", func() {
+	var (
+		tempDir  string
+		pidFile  string
+		savedEnv map[string]string
+		mgr      *commands.VPNManager
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "adgui-timeouts-*")
+		Expect(err).NotTo(HaveOccurred())
+		pidFile = filepath.Join(tempDir, "child.pid")
+		script := filepath.Join(tempDir, "adguardvpn-cli")
+		// The CLI leaves a child behind that only a process group kill reaches.
+		Expect(os.WriteFile(script, []byte("#!/bin/sh\nsleep 30 &\necho $! > \"$ADGUARD_TEST_PIDFILE\"\nwait\n"), 0o755)).To(Succeed())
+
+		savedEnv = make(map[string]string)
+		for key, value := range map[string]string{
+			"HOME":                 tempDir,
+			"ADGUARD_CMD":          script,
+			"ADGUARD_SUDO_WRAP":    "0",
+			"ADGUARD_TEST_PIDFILE": pidFile,
+		} {
+			savedEnv[key] = os.Getenv(key)
+			Expect(os.Setenv(key, value)).To(Succeed())
+		}
+		mgr = commands.New(commands.NewExecRunner())
+	})
+
+	AfterEach(func() {
+		mgr.KillAllCommands()
+		_ = mgr.Close()
+		for key, value := range savedEnv {
+			if value != "" {
+				_ = os.Setenv(key, value)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}
+		_ = os.RemoveAll(tempDir)
+	})
+
+	childAlive := func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		Expect(err).NotTo(HaveOccurred())
+		// A killed child may stay a zombie when nothing reaps it.
+		stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		if err != nil {
+			return false
+		}
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		return len(fields) > 0 && fields[0] != "Z"
+	}
+
+	It("kills the process group when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- mgr.ConnectAuto(ctx)
+		}()
+
+		Eventually(childAlive, 2*time.Second, 10*time.Millisecond).Should(BeTrue())
+		Expect(mgr.ConnectionState().State).To(Equal(commands.StateConnecting))
+		cancel()
+
+		var err error
+		Eventually(result, 2*time.Second).Should(Receive(&err))
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorCanceled))
+		Eventually(childAlive, 2*time.Second, 10*time.Millisecond).Should(BeFalse())
+		Expect(mgr.ConnectionState().State).To(Equal(commands.StateDisconnected))
+		Expect(mgr.RunningCommands()).To(BeEmpty())
+	})
+
+	It("stops a command after its timeout", func() {
+		timeouts := commands.DefaultCommandTimeouts()
+		timeouts.Default = 200 * time.Millisecond
+		mgr.SetCommandTimeouts(timeouts)
+
+		started := time.Now()
+		_, err := mgr.ListLocations(context.Background())
+		Expect(time.Since(started)).To(BeNumerically("<", 2*time.Second))
+		Expect(commands.ErrorKindOf(err)).To(Equal(commands.ErrorTimeout))
+		Expect(err).To(MatchError(ContainSubstring("list-locations timed out after 200ms")))
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Eventually(childAlive, 2*time.Second, 10*time.Millisecond).Should(BeFalse())
+	})
+
+	It("does not start a command for a finished context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := mgr.GetSiteExclusions(ctx)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(pidFile).NotTo(BeAnExistingFile())
+	})
+
+	It("maps durations per command", func() {
+		timeouts := commands.DefaultCommandTimeouts()
+		Expect(timeouts.For("connect")).To(Equal(2 * time.Minute))
+		Expect(timeouts.For("status")).To(Equal(15 * time.Second))
+		Expect(timeouts.For("site-exclusions")).To(Equal(timeouts.Default))
+	})
+})
@@ -0,0 +1,98 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"slices"
+	"strings"
+)
+
+// WarningKind classifies CLI warnings so the UI can explain them.
+type WarningKind string
+
+const (
+	// WarningDNS means system DNS was not switched to the tunnel and queries may leak.
+	WarningDNS   WarningKind = "dns"
+	WarningOther WarningKind = "other"
+)
+
+// Warning is a warning reported by the last status or connect output.
+// It stays active until a later status check no longer reports it.
+type Warning struct {
+	Kind      WarningKind
+	Text      string
+	Dismissed bool
+}
+
+// ClassifyWarning maps a "Warning:" line (without the prefix) to its kind.
+func ClassifyWarning(text string) WarningKind {
+	if strings.Contains(strings.ToUpper(text), "DNS") {
+		return WarningDNS
+	}
+	return WarningOther
+}
+
+// Warnings returns the active warnings with their dismissal flag.
+func (v *VPNManager) Warnings() []Warning {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	return v.warningsLocked()
+}
+
+func (v *VPNManager) warningsLocked() []Warning {
+	result := make([]Warning, 0, len(v.statusDetails.Warnings))
+	for _, text := range v.statusDetails.Warnings {
+		result = append(result, Warning{
+			Kind:      ClassifyWarning(text),
+			Text:      text,
+			Dismissed: v.dismissedWarnings[text],
+		})
+	}
+	return result
+}
+
+// DismissWarning hides an active warning until it clears and is reported again.
+func (v *VPNManager) DismissWarning(text string) {
+	v.statemx.Lock()
+	if !slices.Contains(v.statusDetails.Warnings, text) || v.dismissedWarnings[text] {
+		v.statemx.Unlock()
+		return
+	}
+	v.dismissedWarnings[text] = true
+	warnings := v.warningsLocked()
+	v.statemx.Unlock()
+
+	v.events.publish(WarningsChanged{Warnings: warnings})
+}
+
+// setStatusDetails replaces the parsed status, forgets dismissals of cleared
+// warnings and publishes WarningsChanged when the warning set differs.
+func (v *VPNManager) setStatusDetails(details Status) {
+	v.statemx.Lock()
+	changed := !slices.Equal(v.statusDetails.Warnings, details.Warnings)
+	v.statusDetails = details
+	for text := range v.dismissedWarnings {
+		if !slices.Contains(details.Warnings, text) {
+			delete(v.dismissedWarnings, text)
+		}
+	}
+	warnings := v.warningsLocked()
+	v.statemx.Unlock()
+
+	if changed {
+		v.events.publish(WarningsChanged{Warnings: warnings})
+	}
+}
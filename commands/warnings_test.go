@@ -0,0 +1,87 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CLI warnings", func() {
+	const dnsWarning = "System DNS could not be configured. DNS queries may bypass the VPN tunnel"
+
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-warnings-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	It("classifies DNS warnings", func() {
+		Expect(commands.ClassifyWarning(dnsWarning)).To(Equal(commands.WarningDNS))
+		Expect(commands.ClassifyWarning("Update available")).To(Equal(commands.WarningOther))
+	})
+
+	It("keeps a dismissal until the warning clears", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\nWarning: " + dnsWarning + "\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		events, unsubscribe := mgr.Subscribe(32)
+		defer unsubscribe()
+		riga := locations.Location{Country: "Latvia", City: "Riga"}
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.Warnings()).To(Equal([]commands.Warning{{Kind: commands.WarningDNS, Text: dnsWarning}}))
+		Expect(drainEvents[commands.WarningsChanged](events)).To(HaveLen(1))
+
+		mgr.DismissWarning(dnsWarning)
+		Expect(mgr.Warnings()).To(Equal([]commands.Warning{{Kind: commands.WarningDNS, Text: dnsWarning, Dismissed: true}}))
+
+		Expect(mgr.Disconnect(context.Background())).To(Succeed())
+		Expect(mgr.Warnings()).To(BeEmpty())
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.Warnings()).To(Equal([]commands.Warning{{Kind: commands.WarningDNS, Text: dnsWarning}}))
+	})
+})
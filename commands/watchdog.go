@@ -0,0 +1,169 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"adgui/locations"
+)
+
+// ReconnectPolicy configures the watchdog that restores the tunnel after a
+// disconnect the user did not ask for (noticed by the status check).
+type ReconnectPolicy struct {
+	Enabled bool
+	// InitialDelay is the wait before the first attempt; it doubles up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	// LocationAttempts is how many reconnects to the last location are tried
+	// before the watchdog falls back to ConnectAuto.
+	LocationAttempts int
+	// MaxAttempts stops the watchdog after this many attempts; 0 means no limit.
+	MaxAttempts int
+}
+
+// DefaultReconnectPolicy returns the disabled policy with production delays.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialDelay:     5 * time.Second,
+		MaxDelay:         5 * time.Minute,
+		LocationAttempts: 3,
+		MaxAttempts:      10,
+	}
+}
+
+// SetReconnectPolicy replaces the watchdog policy. A running watchdog keeps its old policy.
+func (v *VPNManager) SetReconnectPolicy(policy ReconnectPolicy) {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	v.reconnect = policy
+}
+
+// startWatchdog begins reconnecting to loc unless the policy is disabled
+// or a watchdog is already running.
+func (v *VPNManager) startWatchdog(loc locations.Location) {
+	v.statemx.Lock()
+	policy := v.reconnect
+	if !policy.Enabled || v.watchdogStop != nil {
+		v.statemx.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	v.watchdogStop = stop
+	v.statemx.Unlock()
+
+	fmt.Printf("watchdog: unexpected disconnect from %s, reconnecting\n", loc.City)
+	go v.runWatchdog(policy, loc, stop)
+}
+
+// stopWatchdog cancels pending reconnects; user-initiated operations call it first.
+func (v *VPNManager) stopWatchdog() {
+	v.statemx.Lock()
+	defer v.statemx.Unlock()
+	if v.watchdogStop != nil {
+		close(v.watchdogStop)
+		v.watchdogStop = nil
+	}
+}
+
+func (v *VPNManager) runWatchdog(policy ReconnectPolicy, loc locations.Location, stop chan struct{}) {
+	defer func() {
+		v.statemx.Lock()
+		if v.watchdogStop == stop {
+			v.watchdogStop = nil
+		}
+		v.statemx.Unlock()
+	}()
+
+	// Stopping the watchdog also cancels a connect it is running.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delay := policy.InitialDelay
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		// A status check may have seen the tunnel come back on its own.
+		if state := v.ConnectionState().State; state != StateDisconnected && state != StateError {
+			return
+		}
+
+		var reason string
+		var err error
+		// The fallback leaves the choice to the CLI, so its failures are
+		// recorded without the last location.
+		target := locations.Location{}
+		if attempt <= policy.LocationAttempts && loc.City != "" {
+			target = loc
+			reason = fmt.Sprintf("watchdog reconnect to %s, attempt %d", loc.City, attempt)
+			err = v.connectToLocation(ctx, loc)
+		} else {
+			reason = fmt.Sprintf("watchdog fallback to best location, attempt %d", attempt)
+			err = v.connectAuto(ctx)
+		}
+		if err == nil && v.IsConnected() {
+			v.annotateActiveConnection(reason)
+			return
+		}
+
+		v.recordFailedAttempt(target, reason+" failed: "+failureMessage(err))
+
+		delay *= 2
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+	fmt.Printf("watchdog: giving up after %d attempts\n", policy.MaxAttempts)
+}
+
+// annotateActiveConnection attaches a reason to the running session.
+func (v *VPNManager) annotateActiveConnection(reason string) {
+	v.historyMx.Lock()
+	defer v.historyMx.Unlock()
+	if v.activeConnection != nil {
+		v.activeConnection.Reason = joinReason(v.activeConnection.Reason, reason)
+	}
+}
+
+// appendHistoryEntry records a failed attempt as a zero-length session.
+func (v *VPNManager) appendHistoryEntry(entry ConnectionHistoryEntry) {
+	now := v.now()
+	entry.StartedAt = now
+	entry.EndedAt = &now
+
+	v.historyMx.Lock()
+	defer v.historyMx.Unlock()
+	v.prependHistoryLocked(entry)
+}
+
+func joinReason(existing, reason string) string {
+	if existing == "" {
+		return reason
+	}
+	return existing + "; " + reason
+}
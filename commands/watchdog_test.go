@@ -0,0 +1,135 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package commands_test
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reconnect watchdog", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-watchdog-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	riga := locations.Location{ISO: "LV", Country: "Latvia", City: "Riga", Ping: 29}
+	fastPolicy := commands.ReconnectPolicy{
+		Enabled:          true,
+		InitialDelay:     10 * time.Millisecond,
+		MaxDelay:         40 * time.Millisecond,
+		LocationAttempts: 2,
+		MaxAttempts:      4,
+	}
+
+	It("reconnects to the last location after an unexpected disconnect", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Failed to connect: server did not respond\n", ExitCode: 1},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetReconnectPolicy(fastPolicy)
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		mgr.RefreshStatus(context.Background())
+
+		Eventually(mgr.IsConnected, time.Second, 5*time.Millisecond).Should(BeTrue())
+		history := mgr.ConnectionHistory()
+		Expect(history).To(HaveLen(3))
+		Expect(history[0].Reason).To(Equal("watchdog reconnect to Riga, attempt 2"))
+		Expect(history[0].EndedAt).To(BeNil())
+		Expect(history[1].Reason).To(Equal("watchdog reconnect to Riga, attempt 1 failed: Failed to connect: server did not respond"))
+		Expect(history[2].Reason).To(Equal("unexpected disconnect"))
+	})
+
+	It("falls back to the best location after the location attempts", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Location RIGA not found\n", ExitCode: 1},
+			{Args: []string{"connect"}, Output: "Failed to connect: no servers available\n", ExitCode: 1},
+			{Args: []string{"connect"}, Output: "Successfully Connected to FRANKFURT\n"},
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetReconnectPolicy(fastPolicy)
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		mgr.RefreshStatus(context.Background())
+
+		Eventually(mgr.IsConnected, time.Second, 5*time.Millisecond).Should(BeTrue())
+		Expect(mgr.Location()).To(Equal("FRANKFURT"))
+		history := mgr.ConnectionHistory()
+		Expect(history[0].Reason).To(Equal("watchdog fallback to best location, attempt 4"))
+		// The failed fallback was never a reconnect to Riga.
+		Expect(history[1].City).To(Equal("the best location"))
+		Expect(history[1].Country).To(BeEmpty())
+		Expect(history[1].Reason).To(Equal("watchdog fallback to best location, attempt 3 failed: Failed to connect: no servers available"))
+		Expect(history[2].City).To(Equal("Riga"))
+	})
+
+	It("stays off after a user disconnect and when disabled", func() {
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+			{Args: []string{"status"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+		mgr.SetReconnectPolicy(fastPolicy)
+
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		Expect(mgr.Disconnect(context.Background())).To(Succeed())
+		Consistently(mgr.IsConnected, 100*time.Millisecond).Should(BeFalse())
+		Expect(mgr.PreviousConnectionHistory()[0].Reason).To(BeEmpty())
+
+		disabled := fastPolicy
+		disabled.Enabled = false
+		mgr.SetReconnectPolicy(disabled)
+		Expect(mgr.ConnectToLocation(context.Background(), riga)).To(Succeed())
+		mgr.RefreshStatus(context.Background())
+		Consistently(mgr.IsConnected, 100*time.Millisecond).Should(BeFalse())
+	})
+})
@@ -17,9 +17,12 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -32,8 +35,37 @@ const (
 	keyAdguardKillCmd     = "ADGUARD_KILL_CMD"
 	keyAdguardSudoWrap    = "ADGUARD_SUDO_WRAP"
 	keyAdguardSudoAskpass = "ADGUARD_SUDO_ASKPASS"
+	keyAdguardCLIRecord   = "ADGUARD_CLI_RECORD"
+	keyAdguardCLIRecRaw   = "ADGUARD_CLI_RECORD_RAW"
+	keyAdguardCLIReplay   = "ADGUARD_CLI_REPLAY"
+	keyAdguardReconnect   = "ADGUARD_RECONNECT"
+	keyAdguardReconnectN  = "ADGUARD_RECONNECT_ATTEMPTS"
+	keyAdguardKillSwitch  = "ADGUARD_KILLSWITCH"
+	keyAdguardKillSwitchL = "ADGUARD_KILLSWITCH_LAN"
+	keyAdguardKillSwitchE = "ADGUARD_KILLSWITCH_ENDPOINTS"
+	keyAdguardTimeout     = "ADGUARD_TIMEOUT"
+	keyAdguardTimeoutConn = "ADGUARD_TIMEOUT_CONNECT"
+	keyAdguardTimeoutDisc = "ADGUARD_TIMEOUT_DISCONNECT"
+	keyAdguardTimeoutStat = "ADGUARD_TIMEOUT_STATUS"
+	keyAdguardNetChange   = "ADGUARD_NETWORK_CHANGE"
+	keyAdguardNetProbe    = "ADGUARD_NETWORK_PROBE"
+	keyAdguardResumeRecon = "ADGUARD_RESUME_RECONNECT"
+	keyAdguardFailover    = "ADGUARD_FAILOVER"
+
+	defaultReconnectAttempts = 3
+	defaultTimeout           = 30 * time.Second
+	defaultConnectTimeout    = 2 * time.Minute
+	defaultDisconnectTimeout = 30 * time.Second
+	defaultStatusTimeout     = 15 * time.Second
+	defaultNetworkChange     = "check"
+	// defaultNetworkProbe is AdGuard DNS, reachable over TCP on port 53.
+	defaultNetworkProbe = "94.140.14.14:53"
 )
 
+// DefaultKillSwitchLAN lists private and link-local ranges that stay reachable
+// while the kill switch is on.
+const DefaultKillSwitchLAN = "10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,169.254.0.0/16,fc00::/7,fe80::/10"
+
 // EnsureAdguirc creates ~/.config/adgui/adguirc when it is missing.
 // The file contains a localized header comment and all known keys with default
 // values, each preceded by a localized description and commented out with #.
@@ -84,6 +116,22 @@ func buildAdguircTemplate(headerComment string, keyComments map[string]string) s
 		{keyAdguardKillCmd, ""},
 		{keyAdguardSudoWrap, "true"},
 		{keyAdguardSudoAskpass, "true"},
+		{keyAdguardCLIRecord, ""},
+		{keyAdguardCLIRecRaw, "false"},
+		{keyAdguardCLIReplay, ""},
+		{keyAdguardReconnect, "false"},
+		{keyAdguardReconnectN, strconv.Itoa(defaultReconnectAttempts)},
+		{keyAdguardKillSwitch, "false"},
+		{keyAdguardKillSwitchL, DefaultKillSwitchLAN},
+		{keyAdguardKillSwitchE, ""},
+		{keyAdguardTimeout, defaultTimeout.String()},
+		{keyAdguardTimeoutConn, defaultConnectTimeout.String()},
+		{keyAdguardTimeoutDisc, defaultDisconnectTimeout.String()},
+		{keyAdguardTimeoutStat, defaultStatusTimeout.String()},
+		{keyAdguardNetChange, defaultNetworkChange},
+		{keyAdguardNetProbe, defaultNetworkProbe},
+		{keyAdguardResumeRecon, "false"},
+		{keyAdguardFailover, ""},
 	}
 	for _, item := range defaults {
 		if comment := strings.TrimSpace(keyComments[item.key]); comment != "" {
@@ -118,6 +166,131 @@ func AdguardSudoAskpassEnabled() (bool, error) {
 	return boolConfigDefaultTrue(keyAdguardSudoAskpass)
 }
 
+// AdguardReconnectEnabled reports whether the watchdog reconnects after an unexpected
+// disconnect. Default is false. Set ADGUARD_RECONNECT=1/true/yes to enable.
+func AdguardReconnectEnabled() (bool, error) {
+	return boolConfigDefaultFalse(keyAdguardReconnect)
+}
+
+// AdguardReconnectAttempts resolves ADGUARD_RECONNECT_ATTEMPTS: how many reconnects to
+// the last location the watchdog tries before falling back to the best location. Default is 3.
+func AdguardReconnectAttempts() (int, error) {
+	return intConfig(keyAdguardReconnectN, defaultReconnectAttempts)
+}
+
+// AdguardKillSwitchEnabled reports whether adgui blocks traffic outside the tunnel while
+// the VPN should be up. Default is false. Set ADGUARD_KILLSWITCH=1/true/yes to enable.
+func AdguardKillSwitchEnabled() (bool, error) {
+	return boolConfigDefaultFalse(keyAdguardKillSwitch)
+}
+
+// AdguardKillSwitchLAN resolves ADGUARD_KILLSWITCH_LAN: comma-separated CIDR ranges
+// that stay reachable while the kill switch is engaged. Default is the private and link-local ranges.
+func AdguardKillSwitchLAN() (string, error) {
+	return stringConfig(keyAdguardKillSwitchL, DefaultKillSwitchLAN)
+}
+
+// AdguardKillSwitchEndpoints resolves ADGUARD_KILLSWITCH_ENDPOINTS: comma-separated
+// VPN server addresses, optionally with a port, that the tunnel may reach through
+// the uplink while the kill switch is engaged. Default is none.
+func AdguardKillSwitchEndpoints() (string, error) {
+	return stringConfig(keyAdguardKillSwitchE, "")
+}
+
+// AdguardTimeout resolves ADGUARD_TIMEOUT: the time limit for adguardvpn-cli commands
+// without a key of their own (list-locations, site-exclusions, license). Default is 30s.
+func AdguardTimeout() (time.Duration, error) {
+	return durationConfig(keyAdguardTimeout, defaultTimeout)
+}
+
+// AdguardConnectTimeout resolves ADGUARD_TIMEOUT_CONNECT. Default is 2m.
+func AdguardConnectTimeout() (time.Duration, error) {
+	return durationConfig(keyAdguardTimeoutConn, defaultConnectTimeout)
+}
+
+// AdguardDisconnectTimeout resolves ADGUARD_TIMEOUT_DISCONNECT. Default is 30s.
+func AdguardDisconnectTimeout() (time.Duration, error) {
+	return durationConfig(keyAdguardTimeoutDisc, defaultDisconnectTimeout)
+}
+
+// AdguardStatusTimeout resolves ADGUARD_TIMEOUT_STATUS. Default is 15s.
+func AdguardStatusTimeout() (time.Duration, error) {
+	return durationConfig(keyAdguardTimeoutStat, defaultStatusTimeout)
+}
+
+// AdguardNetworkChange resolves ADGUARD_NETWORK_CHANGE: what adgui does when the
+// default route moves to another uplink while the VPN is connected.
+// "off" ignores it, "check" re-validates the tunnel (default), "reconnect" also
+// reconnects to the same location when the tunnel no longer passes traffic.
+func AdguardNetworkChange() (string, error) {
+	value, err := stringConfig(keyAdguardNetChange, defaultNetworkChange)
+	value = strings.ToLower(value)
+	switch value {
+	case "off", "check", "reconnect":
+		return value, err
+	default:
+		return defaultNetworkChange, fmt.Errorf("invalid %s value %q: expected off, check or reconnect", keyAdguardNetChange, value)
+	}
+}
+
+// AdguardNetworkProbe resolves ADGUARD_NETWORK_PROBE: the host:port dialed over TCP
+// to confirm that the tunnel passes traffic after a network change.
+func AdguardNetworkProbe() (string, error) {
+	return stringConfig(keyAdguardNetProbe, defaultNetworkProbe)
+}
+
+// AdguardResumeReconnect reports whether a tunnel found down after a resume from
+// suspend is reconnected to the same location at once. Default is false.
+// Set ADGUARD_RESUME_RECONNECT=1/true/yes to enable.
+func AdguardResumeReconnect() (bool, error) {
+	return boolConfigDefaultFalse(keyAdguardResumeRecon)
+}
+
+// AdguardFailover resolves ADGUARD_FAILOVER: the comma-separated steps tried after a
+// failed connect (bookmarks, country, auto) or off. Empty means not set, so the
+// chain chosen in the UI applies.
+func AdguardFailover() (string, error) {
+	return stringConfig(keyAdguardFailover, "")
+}
+
+func boolConfigDefaultFalse(key string) (bool, error) {
+	value, err := stringConfig(key, "")
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, err
+	default:
+		return false, err
+	}
+}
+
+func intConfig(key string, defaultValue int) (int, error) {
+	value, err := stringConfig(key, "")
+	if err != nil || value == "" {
+		return defaultValue, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return defaultValue, fmt.Errorf("invalid %s value %q: expected a non-negative integer", key, value)
+	}
+	return n, nil
+}
+
+// durationConfig accepts Go durations (90s, 2m) and plain seconds; 0 disables the limit.
+func durationConfig(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, err := stringConfig(key, "")
+	if err != nil || value == "" {
+		return defaultValue, err
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return defaultValue, fmt.Errorf("invalid %s value %q: expected a duration such as 90s or 2m", key, value)
+	}
+	return d, nil
+}
+
 func boolConfigDefaultTrue(key string) (bool, error) {
 	if env := strings.TrimSpace(os.Getenv(key)); env != "" {
 		return parseBoolDefaultTrue(env), nil
@@ -149,6 +322,25 @@ func AdguardKillCmd() (string, error) {
 	return stringConfig(keyAdguardKillCmd, "")
 }
 
+// AdguardCLIRecord resolves ADGUARD_CLI_RECORD: a transcript file where every
+// adguardvpn-cli invocation is recorded. Empty disables recording.
+func AdguardCLIRecord() (string, error) {
+	return stringConfig(keyAdguardCLIRecord, "")
+}
+
+// AdguardCLIRecordRaw reports whether the transcript keeps the CLI output
+// unredacted, account e-mail and license key included. Default is false.
+// Set ADGUARD_CLI_RECORD_RAW=1/true/yes to enable.
+func AdguardCLIRecordRaw() (bool, error) {
+	return boolConfigDefaultFalse(keyAdguardCLIRecRaw)
+}
+
+// AdguardCLIReplay resolves ADGUARD_CLI_REPLAY: a transcript file or a directory
+// of raw outputs served instead of running adguardvpn-cli. Empty disables replay.
+func AdguardCLIReplay() (string, error) {
+	return stringConfig(keyAdguardCLIReplay, "")
+}
+
 func stringConfig(key, defaultValue string) (string, error) {
 	if env := strings.TrimSpace(os.Getenv(key)); env != "" {
 		return env, nil
@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEnsureAdguircCreatesFile(t *testing.T) {
@@ -269,3 +270,314 @@ func writeConfigFile(t *testing.T, home, content string) {
 		t.Fatal(err)
 	}
 }
+
+func TestAdguardReconnectDisabledByDefault(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_RECONNECT", "")
+
+	enabled, err := AdguardReconnectEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Fatal("expected reconnect watchdog disabled by default")
+	}
+}
+
+func TestAdguardReconnectConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_RECONNECT", "")
+	t.Setenv("ADGUARD_RECONNECT_ATTEMPTS", "")
+
+	writeConfigFile(t, home, "ADGUARD_RECONNECT=yes\nADGUARD_RECONNECT_ATTEMPTS=5\n")
+
+	enabled, err := AdguardReconnectEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Fatal("expected reconnect watchdog enabled from config")
+	}
+	attempts, err := AdguardReconnectAttempts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 5 {
+		t.Fatalf("expected 5 attempts, got %d", attempts)
+	}
+}
+
+func TestAdguardReconnectAttemptsInvalid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_RECONNECT_ATTEMPTS", "many")
+
+	attempts, err := AdguardReconnectAttempts()
+	if err == nil {
+		t.Fatal("expected error for invalid attempts value")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected default 3 attempts, got %d", attempts)
+	}
+}
+
+func TestAdguardKillSwitchDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_KILLSWITCH", "")
+	t.Setenv("ADGUARD_KILLSWITCH_LAN", "")
+	t.Setenv("ADGUARD_KILLSWITCH_ENDPOINTS", "")
+
+	enabled, err := AdguardKillSwitchEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Fatal("expected kill switch disabled by default")
+	}
+	lan, err := AdguardKillSwitchLAN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(lan, "192.168.0.0/16") {
+		t.Fatalf("expected private ranges in default LAN, got %q", lan)
+	}
+	endpoints, err := AdguardKillSwitchEndpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints != "" {
+		t.Fatalf("expected no endpoints by default, got %q", endpoints)
+	}
+}
+
+func TestAdguardKillSwitchConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_KILLSWITCH", "")
+	t.Setenv("ADGUARD_KILLSWITCH_LAN", "")
+	t.Setenv("ADGUARD_KILLSWITCH_ENDPOINTS", "")
+
+	writeConfigFile(t, home, "ADGUARD_KILLSWITCH=on\nADGUARD_KILLSWITCH_LAN=192.168.1.0/24\nADGUARD_KILLSWITCH_ENDPOINTS=203.0.113.7:443\n")
+
+	enabled, err := AdguardKillSwitchEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Fatal("expected kill switch enabled from config")
+	}
+	lan, err := AdguardKillSwitchLAN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lan != "192.168.1.0/24" {
+		t.Fatalf("expected LAN from config, got %q", lan)
+	}
+	endpoints, err := AdguardKillSwitchEndpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints != "203.0.113.7:443" {
+		t.Fatalf("expected endpoints from config, got %q", endpoints)
+	}
+}
+
+func TestAdguardTimeoutDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_TIMEOUT", "")
+	t.Setenv("ADGUARD_TIMEOUT_CONNECT", "")
+
+	timeout, err := AdguardTimeout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 30*time.Second {
+		t.Fatalf("expected default timeout 30s, got %s", timeout)
+	}
+	connect, err := AdguardConnectTimeout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connect != 2*time.Minute {
+		t.Fatalf("expected default connect timeout 2m, got %s", connect)
+	}
+}
+
+func TestAdguardTimeoutValues(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_TIMEOUT", "")
+	t.Setenv("ADGUARD_TIMEOUT_CONNECT", "")
+	t.Setenv("ADGUARD_TIMEOUT_STATUS", "0")
+
+	writeConfigFile(t, home, "ADGUARD_TIMEOUT=45\nADGUARD_TIMEOUT_CONNECT=90s\n")
+
+	timeout, err := AdguardTimeout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 45*time.Second {
+		t.Fatalf("expected plain seconds from config, got %s", timeout)
+	}
+	connect, err := AdguardConnectTimeout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connect != 90*time.Second {
+		t.Fatalf("expected duration from config, got %s", connect)
+	}
+	status, err := AdguardStatusTimeout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("expected disabled status timeout, got %s", status)
+	}
+}
+
+func TestAdguardTimeoutInvalid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_TIMEOUT_DISCONNECT", "soon")
+
+	timeout, err := AdguardDisconnectTimeout()
+	if err == nil {
+		t.Fatal("expected error for invalid timeout value")
+	}
+	if timeout != 30*time.Second {
+		t.Fatalf("expected default 30s on error, got %s", timeout)
+	}
+}
+
+func TestAdguardNetworkChangeDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_NETWORK_CHANGE", "")
+	t.Setenv("ADGUARD_NETWORK_PROBE", "")
+
+	action, err := AdguardNetworkChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "check" {
+		t.Fatalf("expected check by default, got %q", action)
+	}
+	probe, err := AdguardNetworkProbe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probe != "94.140.14.14:53" {
+		t.Fatalf("unexpected default probe %q", probe)
+	}
+}
+
+func TestAdguardNetworkChangeConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_NETWORK_CHANGE", "")
+	t.Setenv("ADGUARD_NETWORK_PROBE", "")
+
+	writeConfigFile(t, home, "ADGUARD_NETWORK_CHANGE=Reconnect\nADGUARD_NETWORK_PROBE=example.com:443\n")
+
+	action, err := AdguardNetworkChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "reconnect" {
+		t.Fatalf("expected reconnect from config, got %q", action)
+	}
+	probe, err := AdguardNetworkProbe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probe != "example.com:443" {
+		t.Fatalf("expected probe from config, got %q", probe)
+	}
+}
+
+func TestAdguardNetworkChangeInvalid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_NETWORK_CHANGE", "sometimes")
+
+	action, err := AdguardNetworkChange()
+	if err == nil {
+		t.Fatal("expected error for invalid network change value")
+	}
+	if action != "check" {
+		t.Fatalf("expected check on error, got %q", action)
+	}
+}
+
+func TestAdguardResumeReconnect(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_RESUME_RECONNECT", "")
+
+	enabled, err := AdguardResumeReconnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Fatal("expected resume reconnect disabled by default")
+	}
+
+	writeConfigFile(t, home, "ADGUARD_RESUME_RECONNECT=on\n")
+	enabled, err = AdguardResumeReconnect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Fatal("expected resume reconnect enabled from config")
+	}
+}
+
+func TestAdguardCLIRecordRaw(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_CLI_RECORD_RAW", "")
+
+	raw, err := AdguardCLIRecordRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw {
+		t.Fatal("expected redacted transcripts by default")
+	}
+
+	t.Setenv("ADGUARD_CLI_RECORD_RAW", "yes")
+	raw, err = AdguardCLIRecordRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !raw {
+		t.Fatal("expected raw transcripts from env")
+	}
+}
+
+func TestAdguardFailover(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ADGUARD_FAILOVER", "")
+
+	value, err := AdguardFailover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "" {
+		t.Fatalf("expected failover unset by default, got %q", value)
+	}
+
+	writeConfigFile(t, home, "ADGUARD_FAILOVER=country,auto\n")
+	value, err = AdguardFailover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "country,auto" {
+		t.Fatalf("expected failover chain from config, got %q", value)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client calls a running adgui instance over its control socket.
+// Calls are serialized; a client that subscribed only delivers events.
+type Client struct {
+	conn    net.Conn
+	mx      sync.Mutex
+	enc     *json.Encoder
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+// Dial connects to the control socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	return &Client{conn: conn, enc: json.NewEncoder(conn), scanner: scanner}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call invokes method with params and decodes the result into result (may be nil).
+// A failed call returns *Error.
+func (c *Client) Call(method string, params, result any) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	req := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  any             `json:"params,omitempty"`
+	}{"2.0", id, method, params}
+	if err := c.enc.Encode(req); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return fmt.Errorf("failed to read %s response: %w", method, err)
+		}
+		// Event notifications may arrive between responses.
+		if msg.Method != "" || string(msg.ID) != string(id) {
+			continue
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	}
+}
+
+// Subscribe asks for event notifications and calls fn for each until the
+// connection closes. It blocks; the client cannot make calls afterwards.
+func (c *Client) Subscribe(fn func(EventMessage)) error {
+	if err := c.Call(MethodSubscribe, nil, nil); err != nil {
+		return err
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return err
+		}
+		if msg.Method != NotificationEvent {
+			continue
+		}
+		var ev EventMessage
+		if err := json.Unmarshal(msg.Params, &ev); err == nil {
+			fn(ev)
+		}
+	}
+}
+
+type clientMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func (c *Client) read() (clientMessage, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return clientMessage{}, err
+		}
+		return clientMessage{}, net.ErrClosed
+	}
+	var msg clientMessage
+	if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+		return clientMessage{}, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,261 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package control exposes a running VPNManager to other local processes over a
+// Unix socket speaking JSON-RPC 2.0, one JSON object per line. Only processes
+// of the same user may connect.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"adgui/commands"
+	"adgui/locations"
+)
+
+const socketFile = "control.sock"
+
+// Method names served by Server.
+const (
+	MethodStatus            = "status"
+	MethodConnect           = "connect"
+	MethodDisconnect        = "disconnect"
+	MethodLocations         = "locations"
+	MethodExclusions        = "exclusions.list"
+	MethodExclusionAdd      = "exclusions.add"
+	MethodExclusionRemove   = "exclusions.remove"
+	MethodExclusionsSetMode = "exclusions.set_mode"
+	MethodSubscribe         = "subscribe"
+	// NotificationEvent carries an EventMessage to subscribers.
+	NotificationEvent = "event"
+)
+
+// JSON-RPC error codes; CodeFailed reports an operation that ran and failed.
+const (
+	CodeParse          = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeFailed         = -32000
+)
+
+// SocketPath returns $XDG_RUNTIME_DIR/adgui/control.sock, or a per-user
+// directory under the system temp dir when XDG_RUNTIME_DIR is not set.
+func SocketPath() string {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		return filepath.Join(os.TempDir(), "adgui-"+strconv.Itoa(os.Getuid()), socketFile)
+	}
+	return filepath.Join(base, "adgui", socketFile)
+}
+
+// Status is the connection snapshot returned by the status, connect and disconnect methods.
+type Status struct {
+	State      string   `json:"state"`
+	Reason     string   `json:"reason,omitempty"`
+	City       string   `json:"city,omitempty"`
+	Country    string   `json:"country,omitempty"`
+	ISO        string   `json:"iso,omitempty"`
+	Mode       string   `json:"mode,omitempty"`
+	Interface  string   `json:"interface,omitempty"`
+	SOCKSPort  int      `json:"socks_port,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	KillSwitch bool     `json:"kill_switch"`
+}
+
+// Connected reports whether the snapshot describes an established tunnel.
+func (s Status) Connected() bool {
+	return s.State == commands.StateConnected.String()
+}
+
+// StatusOf takes a snapshot of mgr without running the CLI.
+func StatusOf(mgr *commands.VPNManager) Status {
+	state := mgr.ConnectionState()
+	details := mgr.StatusDetails()
+	st := Status{
+		State:      state.State.String(),
+		Reason:     state.Reason,
+		Mode:       string(details.Mode),
+		Interface:  details.Interface,
+		SOCKSPort:  details.SOCKSPort,
+		Warnings:   details.Warnings,
+		KillSwitch: mgr.KillSwitchEngaged(),
+	}
+	if loc, ok := mgr.ConnectedLocation(); ok {
+		st.City = loc.City
+		st.Country = loc.Country
+		st.ISO = loc.ISO
+	}
+	return st
+}
+
+// Location is the JSON form of locations.Location.
+type Location struct {
+	ISO        string `json:"iso"`
+	Country    string `json:"country"`
+	City       string `json:"city"`
+	Ping       int    `json:"ping"`
+	Bookmarked bool   `json:"bookmarked"`
+}
+
+// Exclusions is the result of exclusions.list.
+type Exclusions struct {
+	Mode    string   `json:"mode"`
+	Domains []string `json:"domains"`
+}
+
+// ConnectParams selects the connect target: a city, an ISO country code
+// (its fastest city) or the best location when empty.
+type ConnectParams struct {
+	Target string `json:"target,omitempty"`
+}
+
+// StatusParams asks the server to run a status check before answering.
+type StatusParams struct {
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// LocationsParams orders the locations result; Sort is iso, country, city or ping (default).
+type LocationsParams struct {
+	Sort string `json:"sort,omitempty"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+var sortColumns = map[string]locations.SortColumn{
+	"":        locations.SortByPing,
+	"iso":     locations.SortByISO,
+	"country": locations.SortByCountry,
+	"city":    locations.SortByCity,
+	"ping":    locations.SortByPing,
+}
+
+// ParseSortColumn maps a LocationsParams.Sort value to a sort column.
+func ParseSortColumn(name string) (locations.SortColumn, bool) {
+	column, ok := sortColumns[strings.ToLower(strings.TrimSpace(name))]
+	return column, ok
+}
+
+// DomainParams names one site exclusion.
+type DomainParams struct {
+	Domain string `json:"domain"`
+}
+
+// ModeParams selects the site exclusions mode: general or selective.
+type ModeParams struct {
+	Mode string `json:"mode"`
+}
+
+// EventMessage is the params of an event notification sent to subscribers.
+type EventMessage struct {
+	Type string         `json:"type"`
+	Data map[string]any `json:"data"`
+}
+
+// ResolveTarget matches a city name first, then an ISO country code,
+// picking the fastest location of that country.
+func ResolveTarget(locs []locations.Location, target string) *locations.Location {
+	if loc := locations.FindByCity(locs, target); loc != nil {
+		return loc
+	}
+	var country []locations.Location
+	for _, loc := range locs {
+		if strings.EqualFold(loc.ISO, strings.TrimSpace(target)) {
+			country = append(country, loc)
+		}
+	}
+	return locations.FindFastestLocation(country)
+}
+
+// Connect runs a connect on mgr for target (see ConnectParams) and returns the resulting status.
+func Connect(ctx context.Context, mgr *commands.VPNManager, target string) (Status, error) {
+	var err error
+	if target != "" {
+		locs, lerr := mgr.ListLocations(ctx)
+		if lerr != nil {
+			return Status{}, lerr
+		}
+		loc := ResolveTarget(locs, target)
+		if loc == nil {
+			return Status{}, &commands.CLIError{
+				Kind:     commands.ErrorLocationUnknown,
+				Op:       "connect",
+				ExitCode: -1,
+				Message:  fmt.Sprintf("unknown location %q", target),
+			}
+		}
+		err = mgr.ConnectWithFailover(ctx, *loc)
+	} else {
+		err = mgr.ConnectAuto(ctx)
+	}
+	st := StatusOf(mgr)
+	if err != nil {
+		return st, err
+	}
+	if !st.Connected() {
+		return st, statusError(st, "not connected")
+	}
+	return st, nil
+}
+
+// Disconnect adopts a running tunnel (so the session lands in the history),
+// disconnects and returns the resulting status.
+func Disconnect(ctx context.Context, mgr *commands.VPNManager) (Status, error) {
+	mgr.RefreshStatus(ctx)
+	err := mgr.Disconnect(ctx)
+	st := StatusOf(mgr)
+	if err != nil {
+		return st, err
+	}
+	if st.State != commands.StateDisconnected.String() {
+		return st, statusError(st, "still connected")
+	}
+	return st, nil
+}
+
+// Locations lists locations with bookmark flags, bookmarked first when any exist.
+func Locations(ctx context.Context, mgr *commands.VPNManager, column locations.SortColumn, ascending bool) ([]Location, error) {
+	locs, err := mgr.ListLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := commands.LoadLocationBookmarks()
+	if err != nil {
+		return nil, err
+	}
+	set := commands.LocationBookmarkSet(bookmarks)
+	locs = locations.ApplyBookmarkFlags(locs, func(loc locations.Location) bool {
+		_, ok := set[commands.LocationBookmarkKey(loc.ISO, loc.Country, loc.City)]
+		return ok
+	})
+	locs = locations.SortLocationsWithBookmarks(locs, column, ascending, len(set) > 0)
+	result := make([]Location, 0, len(locs))
+	for _, loc := range locs {
+		result = append(result, Location(loc))
+	}
+	return result, nil
+}
+
+func statusError(st Status, fallback string) error {
+	if st.Reason != "" {
+		return errors.New(st.Reason)
+	}
+	return errors.New(fallback)
+}
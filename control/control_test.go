@@ -0,0 +1,147 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package control_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"adgui/commands"
+	"adgui/control"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Control socket", func() {
+	var (
+		tempDir     string
+		oldHome     string
+		oldSudoWrap string
+		socketPath  string
+		mgr         *commands.VPNManager
+		server      *control.Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "adgui-control-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempDir)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+		socketPath = filepath.Join(tempDir, "run", "control.sock")
+
+		list, err := os.ReadFile(filepath.Join("..", "cli-reference-output", "list"))
+		Expect(err).NotTo(HaveOccurred())
+		mgr = commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"list-locations"}, Output: string(list)},
+			{Args: []string{"connect", "-l", "Frankfurt"}, Output: "Successfully Connected to FRANKFURT in TUN mode, running on tun0\n"},
+			{Args: []string{"site-exclusions", "add", "example.org"}, Output: "Site exclusion added\n"},
+		}))
+		server, err = control.Listen(socketPath, mgr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = server.Close()
+		_ = mgr.Close()
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempDir)
+	})
+
+	It("creates a socket only the owner can use", func() {
+		info, err := os.Stat(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		dir, err := os.Stat(filepath.Dir(socketPath))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dir.Mode().Perm()).To(Equal(os.FileMode(0o700)))
+	})
+
+	It("refuses a second instance and replaces a stale socket", func() {
+		_, err := control.Listen(socketPath, mgr)
+		Expect(err).To(MatchError(control.ErrAlreadyRunning))
+
+		Expect(server.Close()).To(Succeed())
+		Expect(os.WriteFile(socketPath, nil, 0o600)).To(Succeed())
+		server, err = control.Listen(socketPath, mgr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("connects by ISO code and streams events to subscribers", func() {
+		subscriber, err := control.Dial(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = subscriber.Close() }()
+		events := make(chan control.EventMessage, 32)
+		go func() {
+			defer GinkgoRecover()
+			_ = subscriber.Subscribe(func(ev control.EventMessage) { events <- ev })
+		}()
+		// Poke the manager until the subscription is registered.
+		Eventually(func() bool {
+			Expect(mgr.AddSiteExclusion(context.Background(), "example.org")).To(Succeed())
+			select {
+			case ev := <-events:
+				return ev.Type == "exclusions_changed"
+			case <-time.After(20 * time.Millisecond):
+				return false
+			}
+		}, time.Second).Should(BeTrue())
+
+		client, err := control.Dial(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = client.Close() }()
+
+		var st control.Status
+		Expect(client.Call(control.MethodConnect, control.ConnectParams{Target: "de"}, &st)).To(Succeed())
+		Expect(st.Connected()).To(BeTrue())
+		Expect(st.City).To(Equal("Frankfurt"))
+		Expect(st.Interface).To(Equal("tun0"))
+
+		Eventually(events, time.Second).Should(Receive(And(
+			HaveField("Type", "status_changed"),
+			HaveField("Data", HaveKeyWithValue("to", "connected")),
+		)))
+	})
+
+	It("reports JSON-RPC errors", func() {
+		client, err := control.Dial(socketPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = client.Close() }()
+
+		var rpcErr *control.Error
+		err = client.Call("reboot", nil, nil)
+		Expect(errors.As(err, &rpcErr)).To(BeTrue())
+		Expect(rpcErr.Code).To(Equal(control.CodeMethodNotFound))
+
+		err = client.Call(control.MethodConnect, control.ConnectParams{Target: "Atlantis"}, nil)
+		Expect(errors.As(err, &rpcErr)).To(BeTrue())
+		Expect(rpcErr.Code).To(Equal(control.CodeFailed))
+		Expect(rpcErr.Message).To(ContainSubstring("Atlantis"))
+	})
+})
@@ -0,0 +1,89 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package control
+
+import (
+	"adgui/commands"
+)
+
+// EncodeEvent converts a manager event into the notification payload.
+func EncodeEvent(ev commands.Event) EventMessage {
+	switch e := ev.(type) {
+	case commands.StatusChanged:
+		return EventMessage{Type: "status_changed", Data: map[string]any{
+			"from":     e.From.String(),
+			"to":       e.To.String(),
+			"reason":   e.Reason,
+			"location": Location(e.Location),
+		}}
+	case commands.CommandStarted:
+		return EventMessage{Type: "command_started", Data: map[string]any{
+			"id":         e.ID,
+			"args":       e.Args,
+			"started_at": e.StartedAt,
+		}}
+	case commands.CommandOutput:
+		return EventMessage{Type: "command_output", Data: map[string]any{
+			"id":   e.ID,
+			"line": e.Line,
+		}}
+	case commands.CommandFinished:
+		return EventMessage{Type: "command_finished", Data: map[string]any{
+			"id":          e.ID,
+			"args":        e.Args,
+			"exit_code":   e.ExitCode,
+			"duration_ms": e.Duration.Milliseconds(),
+		}}
+	case commands.ConnectProgress:
+		return EventMessage{Type: "connect_progress", Data: map[string]any{
+			"stage":    e.Stage.String(),
+			"location": Location(e.Location),
+		}}
+	case commands.FailoverAttempt:
+		return EventMessage{Type: "failover_attempt", Data: map[string]any{
+			"attempt":  e.Attempt,
+			"step":     string(e.Step),
+			"failed":   Location(e.Failed),
+			"failure":  e.Failure,
+			"location": Location(e.Location),
+		}}
+	case commands.ExclusionsChanged:
+		return EventMessage{Type: "exclusions_changed", Data: map[string]any{
+			"mode":    e.Mode.String(),
+			"added":   e.Added,
+			"removed": e.Removed,
+		}}
+	case commands.WarningsChanged:
+		warnings := make([]map[string]any, 0, len(e.Warnings))
+		for _, w := range e.Warnings {
+			warnings = append(warnings, map[string]any{
+				"kind":      string(w.Kind),
+				"text":      w.Text,
+				"dismissed": w.Dismissed,
+			})
+		}
+		return EventMessage{Type: "warnings_changed", Data: map[string]any{"warnings": warnings}}
+	case commands.HistoryAppended:
+		return EventMessage{Type: "history_appended", Data: map[string]any{"entry": e.Entry}}
+	case commands.KillSwitchChanged:
+		return EventMessage{Type: "kill_switch_changed", Data: map[string]any{
+			"engaged":   e.Engaged,
+			"interface": e.Interface,
+		}}
+	default:
+		return EventMessage{Type: "unknown", Data: map[string]any{}}
+	}
+}
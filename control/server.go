@@ -0,0 +1,353 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"adgui/commands"
+)
+
+const (
+	maxMessageSize = 1 << 20
+	eventBuffer    = 64
+)
+
+// ErrAlreadyRunning is returned by Listen when another live instance owns the socket.
+var ErrAlreadyRunning = errors.New("another adgui instance owns the control socket")
+
+// Error is a JSON-RPC error object; Client.Call returns it for failed calls.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server serves one VPNManager on a Unix socket.
+type Server struct {
+	mgr      *commands.VPNManager
+	path     string
+	listener *net.UnixListener
+
+	// ctx is cancelled by Close and stops CLI commands still running for clients.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mx     sync.Mutex
+	conns  map[*net.UnixConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Listen creates the socket directory (mode 0700) and starts serving mgr on path.
+// A socket left by a crashed instance is replaced; a live one yields ErrAlreadyRunning.
+func Listen(path string, mgr *commands.VPNManager) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return nil, ErrAlreadyRunning
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		mgr:      mgr,
+		path:     path,
+		listener: listener,
+		ctx:      ctx,
+		cancel:   cancel,
+		conns:    make(map[*net.UnixConn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Path returns the socket path.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Close stops accepting connections, cancels running requests, drops the open
+// connections and removes the socket.
+func (s *Server) Close() error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	err := s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mx.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			s.mx.Lock()
+			closed := s.closed
+			s.mx.Unlock()
+			if !closed {
+				fmt.Printf("control socket accept error: %v\n", err)
+			}
+			return
+		}
+		if !s.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) track(conn *net.UnixConn) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn *net.UnixConn) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.conns, conn)
+}
+
+// serverConn serializes writes of responses and event notifications.
+type serverConn struct {
+	conn        *net.UnixConn
+	mx          sync.Mutex
+	enc         *json.Encoder
+	unsubscribe func()
+}
+
+func (c *serverConn) write(msg response) {
+	msg.JSONRPC = "2.0"
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if err := c.enc.Encode(msg); err != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *serverConn) reply(id json.RawMessage, result any, rpcErr *Error) {
+	msg := response{ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &Error{Code: CodeFailed, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	if msg.ID == nil {
+		msg.ID = json.RawMessage("null")
+	}
+	c.write(msg)
+}
+
+func (s *Server) handle(conn *net.UnixConn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer func() { _ = conn.Close() }()
+
+	if err := checkPeer(conn); err != nil {
+		fmt.Printf("control socket: rejected connection: %v\n", err)
+		return
+	}
+
+	c := &serverConn{conn: conn, enc: json.NewEncoder(conn)}
+	defer func() {
+		if c.unsubscribe != nil {
+			c.unsubscribe()
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			c.reply(nil, nil, &Error{Code: CodeParse, Message: err.Error()})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			c.reply(req.ID, nil, &Error{Code: CodeInvalidRequest, Message: "expected a JSON-RPC 2.0 request"})
+			continue
+		}
+		result, rpcErr := s.dispatch(c, req)
+		// Requests without an id are notifications and get no response.
+		if req.ID != nil {
+			c.reply(req.ID, result, rpcErr)
+		}
+	}
+}
+
+func (s *Server) dispatch(c *serverConn, req request) (any, *Error) {
+	switch req.Method {
+	case MethodStatus:
+		var params StatusParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.Refresh {
+			s.mgr.RefreshStatus(s.ctx)
+		}
+		return StatusOf(s.mgr), nil
+	case MethodConnect:
+		var params ConnectParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return failed(Connect(s.ctx, s.mgr, params.Target))
+	case MethodDisconnect:
+		return failed(Disconnect(s.ctx, s.mgr))
+	case MethodLocations:
+		var params LocationsParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		column, ok := ParseSortColumn(params.Sort)
+		if !ok {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown sort column %q", params.Sort)}
+		}
+		return failed(Locations(s.ctx, s.mgr, column, !params.Desc))
+	case MethodExclusions:
+		mode, domains, err := s.mgr.GetSiteExclusions(s.ctx)
+		if domains == nil {
+			domains = []string{}
+		}
+		return failed(Exclusions{Mode: mode.String(), Domains: domains}, err)
+	case MethodExclusionAdd, MethodExclusionRemove:
+		var params DomainParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		domain := strings.TrimSpace(params.Domain)
+		if domain == "" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "domain is required"}
+		}
+		if req.Method == MethodExclusionAdd {
+			return failed(true, s.mgr.AddSiteExclusion(s.ctx, domain))
+		}
+		return failed(true, s.mgr.RemoveSiteExclusion(s.ctx, domain))
+	case MethodExclusionsSetMode:
+		var params ModeParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		mode := commands.SiteExclusionMode(strings.ToLower(params.Mode))
+		if mode != commands.SiteExclusionModeGeneral && mode != commands.SiteExclusionModeSelective {
+			return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("unknown mode %q", params.Mode)}
+		}
+		_, domains, err := s.mgr.GetSiteExclusions(s.ctx)
+		if err != nil {
+			return failed(false, err)
+		}
+		return failed(true, s.mgr.SetSiteExclusionsMode(s.ctx, mode, domains))
+	case MethodSubscribe:
+		if c.unsubscribe == nil {
+			events, unsubscribe := s.mgr.Subscribe(eventBuffer)
+			c.unsubscribe = unsubscribe
+			go forwardEvents(c, events)
+		}
+		return true, nil
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+}
+
+func forwardEvents(c *serverConn, events <-chan commands.Event) {
+	for ev := range events {
+		c.write(response{Method: NotificationEvent, Params: EncodeEvent(ev)})
+	}
+}
+
+func decodeParams(raw json.RawMessage, dst any) *Error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func failed[T any](result T, err error) (any, *Error) {
+	if err != nil {
+		return nil, &Error{Code: CodeFailed, Message: err.Error()}
+	}
+	return result, nil
+}
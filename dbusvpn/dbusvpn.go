@@ -0,0 +1,246 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package dbusvpn publishes VPN state and actions of a running adgui as the
+// org.adgui.VPN object on the D-Bus session bus, so desktop widgets can follow
+// the connection through PropertiesChanged instead of polling.
+package dbusvpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"adgui/commands"
+	"adgui/control"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// BusName is the well-known name owned by the running instance.
+	BusName = "org.adgui.VPN"
+	// Interface holds the properties and methods below.
+	Interface = "org.adgui.VPN"
+	// Path is the object path of the VPN object.
+	Path = dbus.ObjectPath("/org/adgui/VPN")
+
+	errorFailed = Interface + ".Error.Failed"
+
+	// exclusionsDelay coalesces bursts of exclusion changes (imports, mode
+	// switches) into one site-exclusions show call.
+	exclusionsDelay = 300 * time.Millisecond
+)
+
+// ErrNameTaken is returned when another process already owns BusName.
+var ErrNameTaken = errors.New("D-Bus name " + BusName + " is already owned")
+
+// Service keeps the org.adgui.VPN properties in sync with a VPNManager.
+type Service struct {
+	mgr         *commands.VPNManager
+	conn        *dbus.Conn
+	ownConn     bool
+	props       *prop.Properties
+	unsubscribe func()
+	exclusions  chan struct{}
+	// ctx is cancelled by Close and stops CLI commands started for bus clients.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartSession connects to the session bus and starts the service there.
+func StartSession(mgr *commands.VPNManager) (*Service, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	s, err := Start(conn, mgr)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	s.ownConn = true
+	return s, nil
+}
+
+// Start exports the VPN object on conn and requests BusName.
+func Start(conn *dbus.Conn, mgr *commands.VPNManager) (*Service, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		mgr:        mgr,
+		conn:       conn,
+		exclusions: make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	st := control.StatusOf(mgr)
+	props, err := prop.Export(conn, Path, prop.Map{
+		Interface: {
+			"State":         {Value: st.State, Emit: prop.EmitTrue},
+			"Location":      {Value: st.City, Emit: prop.EmitTrue},
+			"ISO":           {Value: st.ISO, Emit: prop.EmitTrue},
+			"ExclusionMode": {Value: mgr.SiteExclusionsMode().String(), Emit: prop.EmitTrue},
+			"DomainsCount":  {Value: uint32(0), Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to export D-Bus properties: %w", err)
+	}
+	s.props = props
+
+	methods := &vpnObject{ctx: ctx, mgr: mgr}
+	if err := conn.Export(methods, Path, Interface); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to export D-Bus methods: %w", err)
+	}
+	node := &introspect.Node{
+		Name: string(Path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    introspect.Methods(methods),
+				Properties: props.Introspection(Interface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to export D-Bus introspection: %w", err)
+	}
+
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to request D-Bus name: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		cancel()
+		return nil, ErrNameTaken
+	}
+
+	events, unsubscribe := mgr.Subscribe(64)
+	s.unsubscribe = unsubscribe
+	s.wg.Add(2)
+	go s.watchEvents(events)
+	go s.countExclusions()
+	s.requestExclusions()
+	return s, nil
+}
+
+// Close releases the bus name and stops following the manager.
+func (s *Service) Close() error {
+	s.unsubscribe()
+	s.cancel()
+	s.wg.Wait()
+	_, err := s.conn.ReleaseName(BusName)
+	if s.ownConn {
+		if closeErr := s.conn.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (s *Service) watchEvents(events <-chan commands.Event) {
+	defer s.wg.Done()
+	for ev := range events {
+		switch e := ev.(type) {
+		case commands.StatusChanged:
+			st := control.StatusOf(s.mgr)
+			s.set("State", st.State)
+			s.set("Location", st.City)
+			s.set("ISO", st.ISO)
+		case commands.ExclusionsChanged:
+			s.set("ExclusionMode", e.Mode.String())
+			s.requestExclusions()
+		}
+	}
+}
+
+func (s *Service) requestExclusions() {
+	select {
+	case s.exclusions <- struct{}{}:
+	default:
+	}
+}
+
+// countExclusions refreshes DomainsCount from the CLI after exclusions change.
+func (s *Service) countExclusions() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.exclusions:
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(exclusionsDelay):
+		}
+		mode, domains, err := s.mgr.GetSiteExclusions(s.ctx)
+		if err != nil {
+			fmt.Printf("dbus: exclusions refresh error: %v\n", err)
+			continue
+		}
+		s.set("ExclusionMode", mode.String())
+		s.set("DomainsCount", uint32(len(domains)))
+	}
+}
+
+// set updates a property; PropertiesChanged is emitted only for real changes.
+func (s *Service) set(name string, value any) {
+	if s.props.GetMust(Interface, name) == value {
+		return
+	}
+	s.props.SetMust(Interface, name, value)
+}
+
+// vpnObject carries the org.adgui.VPN methods. Each returns the state after the action.
+type vpnObject struct {
+	ctx context.Context
+	mgr *commands.VPNManager
+}
+
+// Connect connects to a city, or to the fastest city of an ISO country code.
+func (o *vpnObject) Connect(city string) (string, *dbus.Error) {
+	return reply(control.Connect(o.ctx, o.mgr, city))
+}
+
+// ConnectBest connects to the location adguardvpn-cli considers best.
+func (o *vpnObject) ConnectBest() (string, *dbus.Error) {
+	return reply(control.Connect(o.ctx, o.mgr, ""))
+}
+
+// Disconnect disconnects the VPN.
+func (o *vpnObject) Disconnect() (string, *dbus.Error) {
+	return reply(control.Disconnect(o.ctx, o.mgr))
+}
+
+func reply(st control.Status, err error) (string, *dbus.Error) {
+	if err != nil {
+		return st.State, dbus.NewError(errorFailed, []any{err.Error()})
+	}
+	return st.State, nil
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dbusvpn_test
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"adgui/commands"
+	"adgui/dbusvpn"
+
+	"github.com/godbus/dbus/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// startPrivateBus runs a throwaway session dbus-daemon and returns its address.
+func startPrivateBus() string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cmd.Start()).To(Succeed())
+	DeferCleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	Expect(err).NotTo(HaveOccurred())
+	return strings.TrimSpace(address)
+}
+
+func connectBus(address string) *dbus.Conn {
+	conn, err := dbus.Connect(address)
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+var _ = Describe("D-Bus service", func() {
+	var (
+		tempHome    string
+		oldHome     string
+		oldSudoWrap string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempHome, err = os.MkdirTemp("", "adgui-dbus-home-*")
+		Expect(err).NotTo(HaveOccurred())
+		oldHome = os.Getenv("HOME")
+		oldSudoWrap = os.Getenv("ADGUARD_SUDO_WRAP")
+		Expect(os.Setenv("HOME", tempHome)).To(Succeed())
+		Expect(os.Setenv("ADGUARD_SUDO_WRAP", "0")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if oldHome != "" {
+			_ = os.Setenv("HOME", oldHome)
+		}
+		if oldSudoWrap != "" {
+			_ = os.Setenv("ADGUARD_SUDO_WRAP", oldSudoWrap)
+		} else {
+			_ = os.Unsetenv("ADGUARD_SUDO_WRAP")
+		}
+		_ = os.RemoveAll(tempHome)
+	})
+
+	It("exposes state, emits PropertiesChanged and runs actions", func() {
+		address := startPrivateBus()
+		list, err := os.ReadFile(filepath.Join("..", "cli-reference-output", "list"))
+		Expect(err).NotTo(HaveOccurred())
+		mgr := commands.New(commands.NewReplayRunner([]commands.TranscriptEntry{
+			{Args: []string{"site-exclusions", "show"}, Output: "Exclusions for GENERAL mode:\nexample.org\nexample.net\n"},
+			{Args: []string{"list-locations"}, Output: string(list)},
+			{Args: []string{"connect", "-l", "Riga"}, Output: "Successfully Connected to RIGA in TUN mode, running on tun0\n"},
+			{Args: []string{"status"}, Output: "Connected to RIGA in TUN mode, running on tun0\n"},
+			{Args: []string{"disconnect"}, Output: "VPN is disconnected\n"},
+		}))
+		defer func() { _ = mgr.Close() }()
+
+		service, err := dbusvpn.Start(connectBus(address), mgr)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = service.Close() }()
+		_, err = dbusvpn.Start(connectBus(address), mgr)
+		Expect(err).To(MatchError(dbusvpn.ErrNameTaken))
+
+		client := connectBus(address)
+		Expect(client.AddMatchSignal(
+			dbus.WithMatchObjectPath(dbusvpn.Path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+		)).To(Succeed())
+		signals := make(chan *dbus.Signal, 16)
+		client.Signal(signals)
+
+		obj := client.Object(dbusvpn.BusName, dbusvpn.Path)
+		property := func(name string) func() any {
+			return func() any {
+				value, err := obj.GetProperty(dbusvpn.Interface + "." + name)
+				Expect(err).NotTo(HaveOccurred())
+				return value.Value()
+			}
+		}
+		Expect(property("State")()).To(Equal("disconnected"))
+		Eventually(property("DomainsCount"), time.Second).Should(Equal(uint32(2)))
+
+		var state string
+		Expect(obj.Call(dbusvpn.Interface+".Connect", 0, "Riga").Store(&state)).To(Succeed())
+		Expect(state).To(Equal("connected"))
+		Eventually(property("Location"), time.Second).Should(Equal("Riga"))
+		Eventually(property("ISO"), time.Second).Should(Equal("LV"))
+		Eventually(signals, time.Second).Should(Receive(HaveField("Body", ContainElement(
+			HaveKeyWithValue("State", dbus.MakeVariant("connected")),
+		))))
+
+		Expect(obj.Call(dbusvpn.Interface+".Disconnect", 0).Store(&state)).To(Succeed())
+		Expect(state).To(Equal("disconnected"))
+		Eventually(property("Location"), time.Second).Should(Equal(""))
+
+		call := obj.Call(dbusvpn.Interface+".Connect", 0, "Atlantis")
+		Expect(call.Err).To(HaveOccurred())
+		Expect(call.Err.Error()).To(ContainSubstring("Atlantis"))
+	})
+})
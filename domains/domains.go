@@ -0,0 +1,204 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package domains extracts site exclusion domains from the list formats users
+// import: plain lists, hosts files, Adblock filters, CSV, JSON and AdGuard VPN
+// exports. Every result lists the domains to add and the skipped entries
+// with the reason. Normalize also validates the domains users enter.
+package domains
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Skip reasons. Reasons with details wrap one of these.
+var (
+	ErrInvalidDomain   = errors.New("not a valid domain")
+	ErrIPAddress       = errors.New("IP address")
+	ErrLocalName       = errors.New("local host name")
+	ErrUnsupportedRule = errors.New("unsupported filter rule")
+	ErrDisabled        = errors.New("disabled in the export")
+	ErrDuplicate       = errors.New("duplicate")
+	ErrExisting        = errors.New("already in the list")
+)
+
+// Reasons a name is not a valid domain; each wraps ErrInvalidDomain.
+var (
+	ErrEmptyLabel   = fmt.Errorf("%w: empty label", ErrInvalidDomain)
+	ErrLongName     = fmt.Errorf("%w: longer than 253 characters", ErrInvalidDomain)
+	ErrLongLabel    = fmt.Errorf("%w: label longer than 63 characters", ErrInvalidDomain)
+	ErrHyphen       = fmt.Errorf("%w: label starts or ends with a hyphen", ErrInvalidDomain)
+	ErrBadCharacter = fmt.Errorf("%w: character not allowed", ErrInvalidDomain)
+	ErrWildcard     = fmt.Errorf("%w: wildcard is only allowed as the first label", ErrInvalidDomain)
+	ErrNumericTLD   = fmt.Errorf("%w: numeric top-level label", ErrInvalidDomain)
+	ErrIDN          = fmt.Errorf("%w: invalid internationalized name", ErrInvalidDomain)
+)
+
+// localNames are hosts file entries of the machine itself.
+var localNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+// Skipped is an entry of the source that gives no domain.
+type Skipped struct {
+	// Line is the 1-based line of the entry, 0 for JSON values.
+	Line   int
+	Text   string
+	Reason error
+}
+
+// Result is what an import would add.
+type Result struct {
+	Format  Format
+	Domains []string
+	Skipped []Skipped
+}
+
+// Exclude moves the domains already in existing to the skipped entries.
+func (r *Result) Exclude(existing []string) {
+	have := make(map[string]bool, len(existing))
+	for _, domain := range existing {
+		have[Key(domain)] = true
+	}
+	kept := r.Domains[:0]
+	for _, domain := range r.Domains {
+		if have[domain] {
+			r.Skipped = append(r.Skipped, Skipped{Text: domain, Reason: ErrExisting})
+			continue
+		}
+		kept = append(kept, domain)
+	}
+	r.Domains = kept
+}
+
+// Normalize turns a domain, a "*." wildcard domain or a URL into the form of
+// the exclusion list: lower case, without scheme, port, path or trailing dot.
+// Internationalized names are converted to punycode; ToUnicode reverses it
+// for display.
+func Normalize(raw string) (string, error) {
+	host := strings.ToLower(strings.TrimSpace(raw))
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidDomain, err)
+		}
+		host = u.Hostname()
+	} else {
+		if i := strings.IndexAny(host, "/?#"); i >= 0 {
+			host = host[:i]
+		}
+		if trimmed := strings.Trim(host, "[]"); trimmed != host {
+			host = trimmed
+		} else if name, port, ok := strings.Cut(host, ":"); ok && !strings.Contains(port, ":") {
+			host = name
+		}
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	if _, err := netip.ParseAddr(host); err == nil {
+		return "", ErrIPAddress
+	}
+	name, wildcard := strings.CutPrefix(host, "*.")
+	name, err := toASCII(name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkDomain(name); err != nil {
+		return "", err
+	}
+	if localNames[name] || !strings.Contains(name, ".") {
+		return "", ErrLocalName
+	}
+	if wildcard {
+		return "*." + name, nil
+	}
+	return name, nil
+}
+
+// checkDomain checks the letters, digits and hyphens rules of DNS names
+// and explains the first violation.
+func checkDomain(host string) error {
+	if host == "" {
+		return ErrEmptyLabel
+	}
+	if len(host) > 253 {
+		return ErrLongName
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		switch {
+		case label == "":
+			return ErrEmptyLabel
+		case label == "*":
+			return ErrWildcard
+		case len(label) > 63:
+			return fmt.Errorf("%w: %q", ErrLongLabel, label)
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("%w: %q", ErrHyphen, label)
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("%w: %q in %q", ErrBadCharacter, r, label)
+			}
+		}
+	}
+	// A numeric top-level label is a malformed address, not a name.
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return ErrNumericTLD
+	}
+	return nil
+}
+
+// collector builds a Result, dropping repeated domains.
+type collector struct {
+	result Result
+	seen   map[string]bool
+}
+
+func newCollector(format Format) *collector {
+	return &collector{result: Result{Format: format}, seen: make(map[string]bool)}
+}
+
+func (c *collector) add(line int, text, candidate string) {
+	domain, err := Normalize(candidate)
+	if err != nil {
+		c.skip(line, text, err)
+		return
+	}
+	if c.seen[domain] {
+		c.skip(line, text, ErrDuplicate)
+		return
+	}
+	c.seen[domain] = true
+	c.result.Domains = append(c.result.Domains, domain)
+}
+
+func (c *collector) skip(line int, text string, reason error) {
+	c.result.Skipped = append(c.result.Skipped, Skipped{Line: line, Text: strings.TrimSpace(text), Reason: reason})
+}
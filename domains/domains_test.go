@@ -0,0 +1,211 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package domains_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+
+	"adgui/domains"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Domain import", func() {
+	reasons := func(result domains.Result) map[string]error {
+		byText := make(map[string]error)
+		for _, skipped := range result.Skipped {
+			byText[skipped.Text] = skipped.Reason
+		}
+		return byText
+	}
+
+	DescribeTable("normalizes domains",
+		func(raw, want string, reason error) {
+			got, err := domains.Normalize(raw)
+			if reason != nil {
+				Expect(errors.Is(err, reason)).To(BeTrue(), "got %v", err)
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(want))
+		},
+		Entry("plain", "Example.COM", "example.com", nil),
+		Entry("URL", "https://www.example.com:8443/path?q=1", "www.example.com", nil),
+		Entry("port and path", "example.com:80/index.html", "example.com", nil),
+		Entry("trailing dot", "example.com.", "example.com", nil),
+		Entry("wildcard", "*.example.com", "*.example.com", nil),
+		Entry("IPv4", "10.0.0.1", "", domains.ErrIPAddress),
+		Entry("IPv6", "[::1]", "", domains.ErrIPAddress),
+		Entry("single label", "router", "", domains.ErrLocalName),
+		Entry("localhost", "localhost.localdomain", "", domains.ErrLocalName),
+		Entry("bad characters", "exa mple.com", "", domains.ErrInvalidDomain),
+		Entry("inner wildcard", "ads.*.com", "", domains.ErrInvalidDomain),
+		Entry("hyphen edge", "-example.com", "", domains.ErrInvalidDomain),
+		Entry("numeric top level", "example.123", "", domains.ErrNumericTLD),
+		Entry("Unicode URL", "https://Пример.рф/path", "xn--e1afmkfd.xn--p1ai", nil),
+		Entry("Unicode wildcard", "*.bücher.example", "*.xn--bcher-kva.example", nil),
+		Entry("full-width dots", "пример。рф", "xn--e1afmkfd.xn--p1ai", nil),
+		Entry("punycode", "XN--E1AFMKFD.xn--p1ai.", "xn--e1afmkfd.xn--p1ai", nil),
+		Entry("broken punycode", "xn--zz.example.com", "", domains.ErrIDN),
+		Entry("empty label", "example..com", "", domains.ErrEmptyLabel),
+		Entry("long label", strings.Repeat("a", 64)+".com", "", domains.ErrLongLabel),
+		Entry("underscore", "my_host.example.com", "", domains.ErrBadCharacter),
+		Entry("trailing wildcard", "example.*", "", domains.ErrWildcard),
+	)
+
+	It("shows punycode domains in Unicode", func() {
+		Expect(domains.ToUnicode("xn--e1afmkfd.xn--p1ai")).To(Equal("пример.рф"))
+		Expect(domains.ToUnicode("*.xn--bcher-kva.example")).To(Equal("*.bücher.example"))
+		Expect(domains.ToUnicode("example.com")).To(Equal("example.com"))
+		Expect(domains.ToUnicode("xn--zz.example.com")).To(Equal("xn--zz.example.com"))
+	})
+
+	It("compares entries by their normalized form", func() {
+		Expect(domains.Key("Пример.РФ")).To(Equal(domains.Key("xn--e1afmkfd.xn--p1ai")))
+		Expect(domains.Key(" Not A Domain ")).To(Equal("not a domain"))
+	})
+
+	It("splits pasted lists", func() {
+		Expect(domains.Split(" a.com b.com,c.com;\nd.com\t")).To(Equal([]string{"a.com", "b.com", "c.com", "d.com"}))
+	})
+
+	DescribeTable("detects formats",
+		func(name, content string, want domains.Format) {
+			Expect(domains.Detect(name, []byte(content))).To(Equal(want))
+		},
+		Entry("plain list", "list.adgui", "example.com\nexample.org\n", domains.FormatPlain),
+		Entry("hosts file", "blocklist.txt", "# ads\n127.0.0.1 localhost\n0.0.0.0 ads.example.com\n", domains.FormatHosts),
+		Entry("hosts by name", "/etc/hosts", "", domains.FormatHosts),
+		Entry("adblock header", "filters.txt", "[Adblock Plus 2.0]\nexample.com\n", domains.FormatAdblock),
+		Entry("adblock rules", "filters.txt", "! Title: ads\n||ads.example.com^\n||tracker.example.org^$third-party\n", domains.FormatAdblock),
+		Entry("CSV by extension", "sites.csv", "example.com\n", domains.FormatCSV),
+		Entry("CSV by content", "sites.txt", "name;domain\nShop;shop.example.com\n", domains.FormatCSV),
+		Entry("JSON by content", "export.txt", `["example.com"]`, domains.FormatJSON),
+		Entry("ZIP archive", "export.txt", "PK\x03\x04rest", domains.FormatAdGuard),
+	)
+
+	It("reads plain lists with comments and URLs", func() {
+		result, err := domains.Parse("list.adgui", []byte("# mine\nexample.com\nhttps://Example.org/page # docs\nexample.com\nnot a domain\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatPlain))
+		Expect(result.Domains).To(Equal([]string{"example.com", "example.org"}))
+		Expect(result.Skipped).To(HaveLen(2))
+		Expect(result.Skipped[0]).To(Equal(domains.Skipped{Line: 4, Text: "example.com", Reason: domains.ErrDuplicate}))
+		Expect(result.Skipped[1].Line).To(Equal(5))
+		Expect(result.Skipped[1].Reason).To(MatchError(domains.ErrInvalidDomain))
+	})
+
+	It("reads hosts files", func() {
+		result, err := domains.Parse("hosts", []byte(
+			"127.0.0.1 localhost localhost.localdomain\n::1 ip6-localhost\n"+
+				"0.0.0.0 ads.example.com tracker.example.com # block\n0.0.0.0 0.0.0.0\nbroken-line\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatHosts))
+		Expect(result.Domains).To(Equal([]string{"ads.example.com", "tracker.example.com"}))
+		skipped := reasons(result)
+		Expect(skipped["localhost"]).To(MatchError(domains.ErrLocalName))
+		Expect(skipped["ip6-localhost"]).To(MatchError(domains.ErrLocalName))
+		Expect(skipped["0.0.0.0"]).To(MatchError(domains.ErrIPAddress))
+		Expect(skipped["broken-line"]).To(MatchError(domains.ErrInvalidDomain))
+	})
+
+	It("reads basic Adblock rules and skips the others", func() {
+		result, err := domains.Parse("filters.txt", []byte(
+			"[Adblock Plus 2.0]\n! comment\n||ads.example.com^\n||cdn.example.org^$third-party\n|https://pixel.example.net^\n"+
+				"@@||good.example.com^\nexample.com##.banner\n||example.com/ads/*\n/banner\\d+/\nplain.example.com\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatAdblock))
+		Expect(result.Domains).To(Equal([]string{"ads.example.com", "cdn.example.org", "pixel.example.net", "plain.example.com"}))
+		Expect(result.Skipped).To(HaveLen(4))
+		for _, skipped := range result.Skipped {
+			Expect(skipped.Reason).To(MatchError(domains.ErrUnsupportedRule))
+		}
+		Expect(result.Skipped[0].Reason).To(MatchError("unsupported filter rule: exception"))
+	})
+
+	It("reads the domain column of CSV files", func() {
+		result, err := domains.Parse("sites.csv", []byte("Name;Website;Notes\nShop;https://shop.example.com/;\"a; b\"\nEmpty;;\nBad;none;x\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatCSV))
+		Expect(result.Domains).To(Equal([]string{"shop.example.com"}))
+		Expect(result.Skipped).To(ConsistOf(domains.Skipped{Line: 4, Text: "none", Reason: domains.ErrLocalName}))
+
+		result, err = domains.Parse("sites.csv", []byte("1,example.com,x\n2,example.org,y\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Domains).To(Equal([]string{"example.com", "example.org"}))
+	})
+
+	It("reads JSON arrays and objects", func() {
+		result, err := domains.Parse("export.json", []byte(`{"exclusions": [
+			"example.com",
+			{"hostname": "example.org", "enabled": true},
+			{"hostname": "off.example.org", "enabled": false},
+			{"id": 3, "url": "https://example.net/x"}
+		]}`), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatJSON))
+		Expect(result.Domains).To(Equal([]string{"example.com", "example.org", "example.net"}))
+		Expect(result.Skipped).To(Equal([]domains.Skipped{{Text: "off.example.org", Reason: domains.ErrDisabled}}))
+
+		_, err = domains.Parse("broken.json", []byte(`["example.com"`), domains.Options{})
+		Expect(err).To(MatchError(ContainSubstring("failed to parse JSON")))
+	})
+
+	It("picks the list of the current mode from AdGuard exports", func() {
+		export := []byte(`{"general": ["general.example.com"], "selective": [{"value": "selective.example.com"}]}`)
+		result, err := domains.Parse("exclusions.json", export, domains.Options{Mode: "selective"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatAdGuard))
+		Expect(result.Domains).To(Equal([]string{"selective.example.com"}))
+
+		var archive bytes.Buffer
+		w := zip.NewWriter(&archive)
+		for name, content := range map[string]string{
+			"exclusions/general.txt":   "general.example.com\n",
+			"exclusions/selective.txt": "selective.example.com\nselective.example.org\n",
+		} {
+			f, err := w.Create(name)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = f.Write([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(w.Close()).To(Succeed())
+
+		result, err = domains.Parse("exclusions.zip", archive.Bytes(), domains.Options{Mode: "general"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Format).To(Equal(domains.FormatAdGuard))
+		Expect(result.Domains).To(Equal([]string{"general.example.com"}))
+
+		result, err = domains.Parse("exclusions.zip", archive.Bytes(), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Domains).To(ConsistOf("general.example.com", "selective.example.com", "selective.example.org"))
+	})
+
+	It("moves domains already in the list to the skipped entries", func() {
+		result, err := domains.Parse("list.adgui", []byte("example.com\nexample.org\nxn--e1afmkfd.xn--p1ai\n"), domains.Options{})
+		Expect(err).NotTo(HaveOccurred())
+		result.Exclude([]string{"Example.ORG", "пример.рф"})
+		Expect(result.Domains).To(Equal([]string{"example.com"}))
+		Expect(result.Skipped).To(Equal([]domains.Skipped{
+			{Text: "example.org", Reason: domains.ErrExisting},
+			{Text: "xn--e1afmkfd.xn--p1ai", Reason: domains.ErrExisting},
+		}))
+	})
+})
@@ -0,0 +1,449 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package domains
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Format is a list syntax recognized by Parse.
+type Format string
+
+const (
+	FormatPlain   Format = "plain"
+	FormatHosts   Format = "hosts"
+	FormatAdblock Format = "adblock"
+	FormatCSV     Format = "csv"
+	FormatJSON    Format = "json"
+	// FormatAdGuard is an AdGuard VPN export: a ZIP archive of per-mode
+	// text files or a JSON object with general and selective lists.
+	FormatAdGuard Format = "adguard"
+)
+
+// sniffLines is how many meaningful lines Detect looks at.
+const sniffLines = 50
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	// domainColumns are CSV header names of the domain column.
+	domainColumns = []string{"domain", "domains", "hostname", "host", "url", "site", "website"}
+	// domainKeys are JSON object keys holding a domain, most specific first.
+	domainKeys = []string{"domain", "hostname", "host", "value", "url", "site"}
+	// modeKeys are the JSON object keys of per-mode lists in AdGuard exports.
+	modeKeys = []string{"general", "selective"}
+)
+
+// Options tune Parse.
+type Options struct {
+	// Mode selects the list of an AdGuard export that holds both modes,
+	// "general" or "selective"; empty takes both.
+	Mode string
+}
+
+// Parse detects the format of data, named name, and extracts its domains.
+// The error is set when data is structured but malformed, e.g. broken JSON.
+func Parse(name string, data []byte, opts Options) (Result, error) {
+	return ParseFormat(Detect(name, data), data, opts)
+}
+
+// ParseReader reads up to MaxFileSize bytes from r and parses them like Parse.
+func ParseReader(name string, r io.Reader, opts Options) (Result, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > MaxFileSize {
+		return Result{}, fmt.Errorf("%s is larger than %d MiB", name, MaxFileSize>>20)
+	}
+	return Parse(name, data, opts)
+}
+
+// ParseFormat extracts the domains of data in the given format.
+func ParseFormat(format Format, data []byte, opts Options) (Result, error) {
+	switch format {
+	case FormatHosts:
+		return parseLines(FormatHosts, data, hostsLine), nil
+	case FormatAdblock:
+		return parseLines(FormatAdblock, data, adblockLine), nil
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		return parseJSON(data, opts)
+	case FormatAdGuard:
+		if bytes.HasPrefix(data, zipMagic) {
+			return parseZip(data, opts)
+		}
+		return parseJSON(data, opts)
+	default:
+		return parseLines(FormatPlain, data, plainLine), nil
+	}
+}
+
+// Detect guesses the format from the file name and the content.
+func Detect(name string, data []byte) Format {
+	if bytes.HasPrefix(data, zipMagic) {
+		return FormatAdGuard
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv", ".tsv":
+		return FormatCSV
+	}
+	if strings.EqualFold(path.Base(name), "hosts") {
+		return FormatHosts
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') && json.Valid(trimmed) {
+		return FormatJSON
+	}
+
+	var sampled, adblock, hosts, csvRows int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() && sampled < sniffLines {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "[Adblock"):
+			return FormatAdblock
+		case strings.HasPrefix(line, "!"):
+			// Only Adblock lists use "!" comments.
+			adblock++
+			continue
+		case strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "##"):
+			continue
+		}
+		sampled++
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "||"), strings.HasPrefix(line, "@@"), strings.HasPrefix(line, "|"),
+			strings.Contains(line, "##"), strings.HasSuffix(line, "^"):
+			adblock++
+		case len(fields) >= 2 && isAddr(fields[0]):
+			hosts++
+		case strings.ContainsAny(line, ",;\t"):
+			csvRows++
+		}
+	}
+	switch {
+	case adblock > 0 && adblock*2 >= sampled:
+		return FormatAdblock
+	case hosts*2 > sampled:
+		return FormatHosts
+	case csvRows*2 > sampled:
+		return FormatCSV
+	default:
+		return FormatPlain
+	}
+}
+
+func isAddr(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// parseLines feeds every line of data to parse, which adds domains or skips the line.
+func parseLines(format Format, data []byte, parse func(c *collector, n int, line string)) Result {
+	c := newCollector(format)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		parse(c, n, scanner.Text())
+	}
+	return c.result
+}
+
+// plainLine takes one domain or URL per line; "#" starts a comment.
+func plainLine(c *collector, n int, line string) {
+	text := line
+	if i := strings.Index(line, "#"); i >= 0 && !strings.Contains(line[:i], "://") {
+		line = line[:i]
+	}
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	c.add(n, text, line)
+}
+
+// hostsLine takes the host names after the address of a hosts file entry.
+func hostsLine(c *collector, n int, line string) {
+	text := line
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	if !isAddr(fields[0]) || len(fields) < 2 {
+		c.skip(n, text, fmt.Errorf("%w: not a hosts entry", ErrInvalidDomain))
+		return
+	}
+	for _, host := range fields[1:] {
+		c.add(n, host, host)
+	}
+}
+
+// adblockLine takes the domain of basic rules such as "||example.com^".
+// Exceptions, cosmetic rules, regular expressions and rules limited to a
+// path block less than a domain, so they are skipped.
+func adblockLine(c *collector, n int, line string) {
+	text := line
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "", strings.HasPrefix(line, "!"), strings.HasPrefix(line, "["):
+		return
+	case strings.HasPrefix(line, "@@"):
+		c.skip(n, text, fmt.Errorf("%w: exception", ErrUnsupportedRule))
+		return
+	case strings.Contains(line, "##"), strings.Contains(line, "#@#"), strings.Contains(line, "#?#"),
+		strings.Contains(line, "#0"), strings.Contains(line, "#%#"):
+		c.skip(n, text, fmt.Errorf("%w: cosmetic", ErrUnsupportedRule))
+		return
+	case strings.HasPrefix(line, "#"):
+		return
+	case len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/"):
+		c.skip(n, text, fmt.Errorf("%w: regular expression", ErrUnsupportedRule))
+		return
+	}
+
+	rule, _, _ := strings.Cut(line, "$")
+	rule = strings.TrimPrefix(rule, "||")
+	rule = strings.TrimPrefix(rule, "|")
+	rule = strings.TrimSuffix(rule, "|")
+	rule = strings.TrimSuffix(rule, "^")
+	if _, rest, ok := strings.Cut(rule, "://"); ok {
+		rule = rest
+	}
+	if strings.ContainsAny(rule, "/^") {
+		c.skip(n, text, fmt.Errorf("%w: path", ErrUnsupportedRule))
+		return
+	}
+	c.add(n, text, rule)
+}
+
+func parseCSV(data []byte) (Result, error) {
+	c := newCollector(FormatCSV)
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = csvDelimiter(data)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	column := -1
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return c.result, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		if first {
+			if column = headerColumn(record); column >= 0 {
+				continue
+			}
+		}
+		if column < 0 {
+			column = guessColumn(record)
+		}
+		if column < 0 || column >= len(record) {
+			c.skip(line, strings.Join(record, string(r.Comma)), fmt.Errorf("%w: no domain column", ErrInvalidDomain))
+			continue
+		}
+		if strings.TrimSpace(record[column]) == "" {
+			continue
+		}
+		c.add(line, record[column], record[column])
+	}
+	return c.result, nil
+}
+
+// csvDelimiter picks the most frequent of comma, semicolon and tab in the first line.
+func csvDelimiter(data []byte) rune {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(first, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func headerColumn(record []string) int {
+	for i, cell := range record {
+		for _, name := range domainColumns {
+			if strings.EqualFold(strings.TrimSpace(cell), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// guessColumn returns the first column holding a domain.
+func guessColumn(record []string) int {
+	for i, cell := range record {
+		if _, err := Normalize(cell); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseJSON(data []byte, opts Options) (Result, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Result{Format: FormatJSON}, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	format := FormatJSON
+	if obj, ok := value.(map[string]any); ok && hasModeKeys(obj) {
+		format = FormatAdGuard
+	}
+	c := newCollector(format)
+	walkJSON(c, value, opts)
+	return c.result, nil
+}
+
+func hasModeKeys(obj map[string]any) bool {
+	for _, key := range modeKeys {
+		if _, ok := obj[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkJSON collects strings, objects with a domain key and the lists nested in objects.
+func walkJSON(c *collector, value any, opts Options) {
+	switch v := value.(type) {
+	case string:
+		c.add(0, v, v)
+	case []any:
+		for _, item := range v {
+			walkJSON(c, item, opts)
+		}
+	case map[string]any:
+		if hasModeKeys(v) {
+			for _, key := range modeKeys {
+				if opts.Mode == "" || opts.Mode == key {
+					walkJSON(c, v[key], opts)
+				}
+			}
+			return
+		}
+		for _, key := range domainKeys {
+			if domain, ok := v[key].(string); ok {
+				if enabled, ok := v["enabled"].(bool); ok && !enabled {
+					c.skip(0, domain, ErrDisabled)
+					return
+				}
+				c.add(0, domain, domain)
+				return
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch v[key].(type) {
+			case []any, map[string]any:
+				walkJSON(c, v[key], opts)
+			}
+		}
+	}
+}
+
+// parseZip reads the text files of an AdGuard VPN export archive, named after
+// the modes; with opts.Mode only the file of that mode is read.
+func parseZip(data []byte, opts Options) (Result, error) {
+	c := newCollector(FormatAdGuard)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return c.result, fmt.Errorf("failed to open ZIP archive: %w", err)
+	}
+	var files []*zip.File
+	for _, file := range archive.File {
+		base := strings.ToLower(path.Base(file.Name))
+		if file.FileInfo().IsDir() || path.Ext(base) != ".txt" {
+			continue
+		}
+		if opts.Mode != "" && hasModeName(archive.File) && !strings.Contains(base, opts.Mode) {
+			continue
+		}
+		files = append(files, file)
+	}
+	for _, file := range files {
+		content, err := readZipFile(file)
+		if err != nil {
+			return c.result, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(nil, 1<<20)
+		for n := 1; scanner.Scan(); n++ {
+			plainLine(c, n, scanner.Text())
+		}
+	}
+	return c.result, nil
+}
+
+func hasModeName(files []*zip.File) bool {
+	for _, file := range files {
+		base := strings.ToLower(path.Base(file.Name))
+		for _, mode := range modeKeys {
+			if strings.Contains(base, mode) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaxFileSize bounds an imported file and each decompressed archive entry.
+const MaxFileSize = 64 << 20
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in the archive: %w", file.Name, err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	content, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s in the archive: %w", file.Name, err)
+	}
+	if len(content) > MaxFileSize {
+		return nil, fmt.Errorf("%s in the archive is larger than %d MiB", file.Name, MaxFileSize>>20)
+	}
+	return content, nil
+}
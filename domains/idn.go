@@ -0,0 +1,85 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package domains
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// lookup maps and validates internationalized names like a resolver does:
+// case folding, full-width dots and the bidi rule.
+var lookup = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false))
+
+// toASCII converts the Unicode labels of host to punycode. Punycode labels
+// are decoded and checked too, so a broken "xn--" label is rejected.
+func toASCII(host string) (string, error) {
+	if !needsIDNA(host) {
+		return host, nil
+	}
+	ascii, err := lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIDN, err)
+	}
+	return strings.TrimSuffix(ascii, "."), nil
+}
+
+func needsIDNA(host string) bool {
+	for _, r := range host {
+		if r >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--")
+}
+
+// ToUnicode returns the display form of a normalized domain; names that
+// are not valid punycode are returned unchanged.
+func ToUnicode(domain string) string {
+	name, wildcard := strings.CutPrefix(domain, "*.")
+	if !strings.Contains(name, "xn--") {
+		return domain
+	}
+	display, err := idna.Display.ToUnicode(name)
+	if err != nil {
+		return domain
+	}
+	if wildcard {
+		return "*." + display
+	}
+	return display
+}
+
+// Key returns the comparison form of an exclusion entry: the normalized
+// domain, or the trimmed lower-case text when it does not normalize.
+func Key(entry string) string {
+	if domain, err := Normalize(entry); err == nil {
+		return domain
+	}
+	return strings.ToLower(strings.TrimSpace(entry))
+}
+
+// Split breaks pasted text into entries separated by white space, commas
+// or semicolons.
+func Split(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ';'
+	})
+}
@@ -16,7 +16,13 @@
 package theme
 
 import (
+	"bytes"
 	_ "embed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
 )
 
 //go:embed icon-off.png
@@ -36,6 +42,9 @@ var (
 	ConnectedIcon        = connectedIcon{}
 	MenuDisconnectedIcon = menuDisconnectedIcon{}
 	MenuConnectedIcon    = menuConnectedIcon{}
+	// PendingIcon and MenuPendingIcon mark connect/disconnect in progress.
+	PendingIcon     = pendingIcon{}
+	MenuPendingIcon = menuPendingIcon{}
 )
 
 type menuDisconnectedIcon struct{}
@@ -78,6 +87,55 @@ func (connectedIcon) Content() []byte {
 	return iconOn
 }
 
+var (
+	pendingOnce     sync.Once
+	pendingIconData []byte
+	menuPendingData []byte
+)
+
+type pendingIcon struct{}
+
+func (pendingIcon) Name() string {
+	return "pending"
+}
+
+func (pendingIcon) Content() []byte {
+	pendingOnce.Do(renderPendingIcons)
+	return pendingIconData
+}
+
+type menuPendingIcon struct{}
+
+func (menuPendingIcon) Name() string {
+	return "vpn-pending"
+}
+
+func (menuPendingIcon) Content() []byte {
+	pendingOnce.Do(renderPendingIcons)
+	return menuPendingData
+}
+
+func renderPendingIcons() {
+	pendingIconData = translucent(iconOn)
+	menuPendingData = translucent(menuIconOn)
+}
+
+// translucent returns the PNG at half opacity, or the source when it cannot be decoded.
+func translucent(src []byte) []byte {
+	img, err := png.Decode(bytes.NewReader(src))
+	if err != nil {
+		return src
+	}
+	dst := image.NewNRGBA(img.Bounds())
+	mask := image.NewUniform(color.Alpha{A: 0x80})
+	draw.DrawMask(dst, dst.Bounds(), img, img.Bounds().Min, mask, image.Point{}, draw.Over)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return src
+	}
+	return buf.Bytes()
+}
+
 // func main() {
 // 	myApp := app.NewWithID("Test")
 // 	myApp.SetIcon(ConnectedIcon)
@@ -29,7 +29,7 @@ func (u *UI) aboutPanel(appVersion string) *fyne.Container {
 	cliLabel := widget.NewLabel(lang.X("about.cli.loading", "adguardvpn-cli: loading..."))
 
 	go func() {
-		version := u.vpnmgr.CLIVersion()
+		version := u.vpnmgr.CLIVersion(u.ctx)
 		fyne.Do(func() {
 			cliLabel.SetText(lang.X("about.cli.version", "adguardvpn-cli: {{.Version}}", map[string]any{"Version": version}))
 		})
@@ -16,6 +16,7 @@
 package ui
 
 import (
+	"context"
 	"strings"
 
 	"adgui/commands"
@@ -28,28 +29,26 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+// cmdQueueOutputLines is how many of the latest output lines each command shows.
+const cmdQueueOutputLines = 3
+
 func (u *UI) cmdQueuePanel() *fyne.Container {
 	var running []commands.RunningCommand
 	var list *widget.List
 
+	finished, refreshFinished := u.finishedCommandsPanel()
 	refreshQueue := func() {
 		running = u.vpnmgr.RunningCommands()
 		if list != nil {
 			list.Refresh()
 		}
+		refreshFinished()
 	}
 
 	u.cmdQueuemx.Lock()
 	u.cmdQueueRefreshFunc = refreshQueue
 	u.cmdQueuemx.Unlock()
 
-	u.vpnmgr.SetCommandQueueChangeCallback(func() {
-		select {
-		case u.updateReqs <- struct{}{}:
-		default:
-		}
-	})
-
 	list = widget.NewList(
 		func() int {
 			return len(running)
@@ -59,11 +58,19 @@ func (u *UI) cmdQueuePanel() *fyne.Container {
 			cmdLabel := widget.NewLabel("adguardvpn-cli status")
 			timeLabel := widget.NewLabel("Started: 00:00:00")
 			killBtn := widget.NewButton(lang.X("cmd_queue.kill", "Kill"), nil)
-
-			return container.NewHBox(pidLabel, cmdLabel, timeLabel, layout.NewSpacer(), killBtn)
+			// Reserve room for the latest output lines
+			outputLabel := widget.NewLabelWithStyle(strings.Repeat("\n", cmdQueueOutputLines-1), fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+			outputLabel.Truncation = fyne.TextTruncateEllipsis
+
+			return container.NewVBox(
+				container.NewHBox(pidLabel, cmdLabel, timeLabel, layout.NewSpacer(), killBtn),
+				outputLabel,
+			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			cont := obj.(*fyne.Container)
+			item := obj.(*fyne.Container)
+			cont := item.Objects[0].(*fyne.Container)
+			outputLabel := item.Objects[1].(*widget.Label)
 			pidLabel := cont.Objects[0].(*widget.Label)
 			cmdLabel := cont.Objects[1].(*widget.Label)
 			timeLabel := cont.Objects[2].(*widget.Label)
@@ -86,6 +93,12 @@ func (u *UI) cmdQueuePanel() *fyne.Container {
 				"Time": cmd.StartedAt.Format("15:04:05"),
 			}))
 
+			output := cmd.Output
+			if len(output) > cmdQueueOutputLines {
+				output = output[len(output)-cmdQueueOutputLines:]
+			}
+			outputLabel.SetText(strings.Join(output, "\n"))
+
 			killBtn.OnTapped = func() {
 				dialog.ShowConfirm(
 					lang.X("cmd_queue.kill.confirm.title", "Kill Command"),
@@ -123,6 +136,120 @@ func (u *UI) cmdQueuePanel() *fyne.Container {
 	refreshQueue()
 
 	bottomControls := container.NewHBox(layout.NewSpacer(), killAllBtn, layout.NewSpacer())
-	content := container.NewBorder(nil, bottomControls, nil, nil, list)
-	return content
+	split := container.NewVSplit(container.NewBorder(nil, bottomControls, nil, nil, list), finished)
+	split.Offset = 0.4
+	return container.NewStack(split)
+}
+
+// finishedCommandsPanel lists the command log with a filter, the output of
+// each entry and a button running it again. The returned function reloads the log.
+func (u *UI) finishedCommandsPanel() (fyne.CanvasObject, func()) {
+	var all, shown []commands.CommandLogEntry
+	var list *widget.List
+	filterEntry := widget.NewEntry()
+	filterEntry.SetPlaceHolder(lang.X("cmd_queue.finished.filter", "Filter by command or output"))
+
+	applyFilter := func() {
+		query := strings.ToLower(strings.TrimSpace(filterEntry.Text))
+		shown = shown[:0]
+		for _, entry := range all {
+			if query == "" || strings.Contains(strings.ToLower(strings.Join(entry.Args, " ")), query) ||
+				strings.Contains(strings.ToLower(entry.Output), query) {
+				shown = append(shown, entry)
+			}
+		}
+		if list != nil {
+			list.Refresh()
+		}
+	}
+	filterEntry.OnChanged = func(string) {
+		applyFilter()
+	}
+
+	list = widget.NewList(
+		func() int {
+			return len(shown)
+		},
+		func() fyne.CanvasObject {
+			timeLabel := widget.NewLabel("00:00:00")
+			exitLabel := widget.NewLabel("exit 255")
+			cmdLabel := widget.NewLabel("adguardvpn-cli status")
+			outputBtn := widget.NewButton(lang.X("cmd_queue.finished.output", "Output"), nil)
+			rerunBtn := widget.NewButton(lang.X("cmd_queue.finished.rerun", "Run again"), nil)
+			return container.NewHBox(timeLabel, exitLabel, cmdLabel, layout.NewSpacer(), outputBtn, rerunBtn)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			cont := obj.(*fyne.Container)
+			timeLabel := cont.Objects[0].(*widget.Label)
+			exitLabel := cont.Objects[1].(*widget.Label)
+			cmdLabel := cont.Objects[2].(*widget.Label)
+			outputBtn := cont.Objects[4].(*widget.Button)
+			rerunBtn := cont.Objects[5].(*widget.Button)
+
+			if id >= len(shown) {
+				return
+			}
+			entry := shown[id]
+
+			timeLabel.SetText(entry.EndedAt.Local().Format("15:04:05"))
+			if entry.Error != "" {
+				exitLabel.SetText(lang.X("cmd_queue.finished.failed", "failed"))
+			} else {
+				exitLabel.SetText(lang.X("cmd_queue.finished.exit", "exit {{.Code}}", map[string]any{"Code": entry.ExitCode}))
+			}
+			fullCmd := strings.Join(entry.Args, " ")
+			if len(fullCmd) > 50 {
+				fullCmd = fullCmd[:47] + "..."
+			}
+			cmdLabel.SetText(fullCmd)
+
+			outputBtn.OnTapped = func() {
+				u.showCommandLogOutput(entry)
+			}
+			rerunBtn.OnTapped = func() {
+				u.rerunCommand(entry)
+			}
+		},
+	)
+
+	refresh := func() {
+		all = u.vpnmgr.CommandLog()
+		applyFilter()
+	}
+	refresh()
+
+	header := widget.NewLabelWithStyle(lang.X("cmd_queue.finished.title", "Finished"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return container.NewBorder(container.NewVBox(header, filterEntry), nil, nil, nil, list), refresh
+}
+
+func (u *UI) showCommandLogOutput(entry commands.CommandLogEntry) {
+	text := entry.Output
+	if entry.Error != "" {
+		text = strings.TrimRight(text, "\n") + "\n" + entry.Error
+	}
+	output := widget.NewLabelWithStyle(strings.TrimSpace(text), fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	scroll := container.NewScroll(output)
+	scroll.SetMinSize(fyne.NewSize(560, 320))
+	dialog.ShowCustom(
+		"adguardvpn-cli "+strings.Join(entry.Args, " "),
+		lang.X("cmd_queue.finished.close", "Close"),
+		scroll,
+		u.dashboardWindow,
+	)
+}
+
+// rerunCommand runs a logged command again; commands that change the VPN
+// or exclusions go through the sudo prompt like the dashboard buttons.
+func (u *UI) rerunCommand(entry commands.CommandLogEntry) {
+	if commands.ClassifyCommand(entry.Args) == commands.CommandMutating {
+		u.runPrivileged(func(ctx context.Context) error {
+			return u.vpnmgr.RerunCommand(ctx, entry)
+		})
+		return
+	}
+	go func() {
+		if err := u.vpnmgr.RerunCommand(u.ctx, entry); err != nil {
+			u.showOperationError(err)
+		}
+	}()
 }
@@ -0,0 +1,127 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"adgui/commands"
+	"adgui/locations"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showConnectProgress shows the stages of a connect to loc reported by
+// ConnectProgress events and follows FailoverAttempt events to the next
+// location. The Cancel button stops the running operation.
+// The returned function closes the dialog.
+func (u *UI) showConnectProgress(loc locations.Location) func() {
+	events, unsubscribe := u.vpnmgr.Subscribe(16)
+
+	title := lang.X("connect_progress.title", "Connecting")
+	if loc.City != "" {
+		title = lang.X("connect_progress.title_location", "Connecting to {{.City}}", map[string]any{"City": loc.City})
+	}
+	stageLabel := widget.NewLabel(connectStageLabel(commands.StageResolvingLocation))
+	failoverLabel := widget.NewLabel("")
+	failoverLabel.Wrapping = fyne.TextWrapWord
+	failoverLabel.Hide()
+	bar := widget.NewProgressBar()
+	bar.Max = float64(commands.ConnectStageCount)
+
+	var d *dialog.CustomDialog
+	fyne.DoAndWait(func() {
+		d = dialog.NewCustom(title, lang.X("connect_progress.cancel", "Cancel"),
+			container.NewVBox(failoverLabel, stageLabel, bar), u.activeWindow())
+		d.SetOnClosed(func() {
+			u.cancelOperation()
+		})
+		d.Resize(fyne.NewSize(360, d.MinSize().Height))
+		d.Show()
+	})
+
+	go func() {
+		current := loc
+		for ev := range events {
+			switch e := ev.(type) {
+			case commands.FailoverAttempt:
+				current = e.Location
+				text := failoverAttemptLabel(e)
+				fyne.Do(func() {
+					failoverLabel.SetText(text)
+					failoverLabel.Show()
+					stageLabel.SetText(connectStageLabel(commands.StageResolvingLocation))
+					bar.SetValue(0)
+				})
+			case commands.ConnectProgress:
+				if !sameLocation(e.Location, current) {
+					continue
+				}
+				fyne.Do(func() {
+					stageLabel.SetText(connectStageLabel(e.Stage))
+					bar.SetValue(float64(e.Stage) + 1)
+				})
+			}
+		}
+	}()
+
+	return func() {
+		unsubscribe()
+		fyne.Do(func() {
+			d.SetOnClosed(nil)
+			d.Hide()
+		})
+	}
+}
+
+func connectStageLabel(stage commands.ConnectStage) string {
+	switch stage {
+	case commands.StageResolvingLocation:
+		return lang.X("connect_progress.resolving_location", "Resolving location…")
+	case commands.StageRequestingElevation:
+		return lang.X("connect_progress.requesting_elevation", "Requesting administrator privileges…")
+	case commands.StageEstablishingTunnel:
+		return lang.X("connect_progress.establishing_tunnel", "Establishing tunnel…")
+	case commands.StageConfiguringDNS:
+		return lang.X("connect_progress.configuring_dns", "Configuring DNS…")
+	default:
+		return stage.String()
+	}
+}
+
+func failoverAttemptLabel(e commands.FailoverAttempt) string {
+	next := e.Location.City
+	if next == "" {
+		next = lang.X("connect_progress.failover.best", "the best location")
+	}
+	failed := e.Failed.City
+	if failed == "" {
+		failed = lang.X("connect_progress.failover.best", "the best location")
+	}
+	return lang.X("connect_progress.failover", "{{.Failed}}: {{.Failure}}\nAttempt {{.Attempt}}: {{.Next}}", map[string]any{
+		"Failed":  failed,
+		"Failure": e.Failure,
+		"Attempt": e.Attempt,
+		"Next":    next,
+	})
+}
+
+// sameLocation compares locations ignoring the bookmark flag and ping.
+func sameLocation(a, b locations.Location) bool {
+	return a.ISO == b.ISO && a.Country == b.Country && a.City == b.City
+}
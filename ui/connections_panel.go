@@ -16,6 +16,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 
 	"adgui/commands"
@@ -25,6 +26,7 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/lang"
 	"fyne.io/fyne/v2/layout"
+	fynetheme "fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -33,6 +35,8 @@ type connectionsPanelWidgets struct {
 	countryLabel   *canvas.Text
 	pingLabel      *canvas.Text
 	statusLabel    *canvas.Text
+	modeLabel      *canvas.Text
+	warningsBox    *fyne.Container
 	historyBox     *fyne.Container
 	historySection *fyne.Container
 }
@@ -43,8 +47,12 @@ func (u *UI) connectionsPanel() (*fyne.Container, *connectionsPanelWidgets) {
 		countryLabel: canvas.NewText("", ConnectedColor),
 		pingLabel:    canvas.NewText("", ConnectedColor),
 		statusLabel:  canvas.NewText(lang.X("connections.disconnected", "Disconnected"), DisconnectedStatusColor),
+		modeLabel:    canvas.NewText("", ConnectedColor),
+		warningsBox:  container.NewVBox(),
 		historyBox:   container.NewVBox(),
 	}
+	widgets.modeLabel.TextSize = 18
+	widgets.modeLabel.Alignment = fyne.TextAlignCenter
 	widgets.statusLabel.TextSize = 36
 	widgets.statusLabel.Alignment = fyne.TextAlignCenter
 	widgets.cityLabel.TextSize = 36
@@ -55,12 +63,15 @@ func (u *UI) connectionsPanel() (*fyne.Container, *connectionsPanelWidgets) {
 	widgets.pingLabel.Alignment = fyne.TextAlignCenter
 
 	connectBtn := widget.NewButton("", func() {
-		u.runPrivileged(func() {
+		// Only a button showing Cancel cancels; otherwise it does what it says.
+		if u.connectButtonCancels() && u.cancelOperation() {
+			return
+		}
+		u.runPrivileged(func(ctx context.Context) error {
 			if u.vpnmgr.IsConnected() {
-				u.vpnmgr.Disconnect()
-			} else {
-				u.vpnmgr.ConnectAuto()
+				return u.vpnmgr.Disconnect(ctx)
 			}
+			return u.vpnmgr.ConnectAuto(ctx)
 		})
 	})
 	u.dashboardConnectBtn = connectBtn
@@ -76,6 +87,7 @@ func (u *UI) connectionsPanel() (*fyne.Container, *connectionsPanelWidgets) {
 		container.NewCenter(widgets.cityLabel),
 		container.NewCenter(widgets.countryLabel),
 		container.NewCenter(widgets.pingLabel),
+		container.NewCenter(widgets.modeLabel),
 	)
 	centerArea := container.NewCenter(centerContent)
 
@@ -90,7 +102,7 @@ func (u *UI) connectionsPanel() (*fyne.Container, *connectionsPanelWidgets) {
 	historySection.Hide()
 
 	content := container.NewBorder(
-		buttonContainer,
+		container.NewVBox(widgets.warningsBox, buttonContainer),
 		historySection,
 		nil,
 		nil,
@@ -115,15 +127,18 @@ func (u *UI) refreshConnectionsPanel(w *connectionsPanelWidgets) {
 		w.cityLabel.Text = loc.City
 		w.countryLabel.Text = loc.Country
 		w.pingLabel.Text = formatPing(loc.Ping)
+		details := u.vpnmgr.StatusDetails()
+		w.modeLabel.Text = formatTunnelMode(details)
 		w.cityLabel.Color = ConnectedColor
 		w.countryLabel.Color = ConnectedColor
 		w.pingLabel.Color = ConnectedColor
 	} else {
-		w.statusLabel.Text = lang.X("connections.disconnected", "Disconnected")
+		w.statusLabel.Text = u.stateLabel(u.vpnmgr.ConnectionState())
 		w.statusLabel.Color = DisconnectedStatusColor
 		w.cityLabel.Text = ""
 		w.countryLabel.Text = ""
 		w.pingLabel.Text = ""
+		w.modeLabel.Text = ""
 		w.cityLabel.Color = DisconnectedColor
 		w.countryLabel.Color = DisconnectedColor
 		w.pingLabel.Color = DisconnectedColor
@@ -132,6 +147,8 @@ func (u *UI) refreshConnectionsPanel(w *connectionsPanelWidgets) {
 	w.countryLabel.Refresh()
 	w.pingLabel.Refresh()
 	w.statusLabel.Refresh()
+	w.modeLabel.Refresh()
+	u.refreshWarningBanners(w.warningsBox)
 
 	w.historyBox.Objects = nil
 	entries := u.vpnmgr.PreviousConnectionHistory()
@@ -150,6 +167,66 @@ func (u *UI) refreshConnectionsPanel(w *connectionsPanelWidgets) {
 	w.historySection.Refresh()
 }
 
+func formatTunnelMode(st commands.Status) string {
+	switch {
+	case st.Mode == commands.TunnelModeSOCKS && st.SOCKSPort > 0:
+		return lang.X("connections.mode.socks", "{{.Mode}} mode, port {{.Port}}", map[string]any{
+			"Mode": st.Mode, "Port": st.SOCKSPort,
+		})
+	case st.Mode != "" && st.Interface != "":
+		return lang.X("connections.mode.tun", "{{.Mode}} mode on {{.Interface}}", map[string]any{
+			"Mode": st.Mode, "Interface": st.Interface,
+		})
+	default:
+		return string(st.Mode)
+	}
+}
+
+// refreshWarningBanners shows one banner per active, not dismissed CLI warning.
+func (u *UI) refreshWarningBanners(box *fyne.Container) {
+	box.Objects = nil
+	for _, warning := range u.vpnmgr.Warnings() {
+		if warning.Dismissed {
+			continue
+		}
+		box.Add(u.warningBanner(warning))
+	}
+	box.Refresh()
+}
+
+func (u *UI) warningBanner(warning commands.Warning) fyne.CanvasObject {
+	title := widget.NewLabel(warning.Text)
+	title.TextStyle.Bold = true
+	title.Wrapping = fyne.TextWrapWord
+	title.Importance = widget.WarningImportance
+	explanation := widget.NewLabel(warningExplanation(warning.Kind))
+	explanation.Wrapping = fyne.TextWrapWord
+
+	diagnostics := widget.NewButton(lang.X("warning.diagnostics", "Check IP region"), func() {
+		u.selectDashboardTab(ipRegionTabIndex)
+	})
+	diagnostics.Importance = widget.LowImportance
+	dismiss := widget.NewButtonWithIcon("", fynetheme.CancelIcon(), func() {
+		u.vpnmgr.DismissWarning(warning.Text)
+	})
+	dismiss.Importance = widget.LowImportance
+
+	return container.NewBorder(nil, widget.NewSeparator(), widget.NewIcon(fynetheme.WarningIcon()),
+		container.NewVBox(dismiss),
+		container.NewVBox(title, explanation, container.NewHBox(diagnostics)))
+}
+
+func warningExplanation(kind commands.WarningKind) string {
+	switch kind {
+	case commands.WarningDNS:
+		return lang.X("warning.dns.explanation",
+			"DNS queries may go outside the VPN tunnel, so your provider can see which sites you visit. Check that the IP region matches the VPN location and restart the connection.")
+	default:
+		return lang.X("warning.other.explanation",
+			"adguardvpn-cli reported a problem with the current connection.")
+	}
+}
+
 func formatHistoryEntry(entry commands.ConnectionHistoryEntry) string {
 	location := entry.City
 	if entry.Country != "" {
@@ -160,5 +237,9 @@ func formatHistoryEntry(entry commands.ConnectionHistoryEntry) string {
 	if entry.EndedAt != nil {
 		ended = entry.EndedAt.Local().Format("2006-01-02 15:04:05")
 	}
-	return fmt.Sprintf("%s — %s → %s", location, started, ended)
+	line := fmt.Sprintf("%s — %s → %s", location, started, ended)
+	if entry.Reason != "" {
+		line += " (" + entry.Reason + ")"
+	}
+	return line
 }
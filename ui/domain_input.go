@@ -0,0 +1,53 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"errors"
+
+	"adgui/domains"
+
+	"fyne.io/fyne/v2/lang"
+)
+
+// domainReason explains why an entered or imported name is not a domain
+// that can be excluded.
+func domainReason(err error) string {
+	switch {
+	case errors.Is(err, domains.ErrIPAddress):
+		return lang.X("domains.import.reason.ip", "IP address")
+	case errors.Is(err, domains.ErrLocalName):
+		return lang.X("domains.import.reason.local", "local host name")
+	case errors.Is(err, domains.ErrEmptyLabel):
+		return lang.X("domains.reason.empty_label", "empty part between dots")
+	case errors.Is(err, domains.ErrLongName):
+		return lang.X("domains.reason.long_name", "longer than 253 characters")
+	case errors.Is(err, domains.ErrLongLabel):
+		return lang.X("domains.reason.long_label", "a part is longer than 63 characters")
+	case errors.Is(err, domains.ErrHyphen):
+		return lang.X("domains.reason.hyphen", "a part starts or ends with a hyphen")
+	case errors.Is(err, domains.ErrBadCharacter):
+		return lang.X("domains.reason.character", "only letters, digits and hyphens are allowed")
+	case errors.Is(err, domains.ErrWildcard):
+		return lang.X("domains.reason.wildcard", "a wildcard is only allowed as *. at the start")
+	case errors.Is(err, domains.ErrNumericTLD):
+		return lang.X("domains.reason.numeric_tld", "the last part is a number")
+	case errors.Is(err, domains.ErrIDN):
+		return lang.X("domains.reason.idn", "invalid international name")
+	default:
+		return lang.X("domains.import.reason.invalid", "not a valid domain")
+	}
+}
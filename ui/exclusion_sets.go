@@ -0,0 +1,216 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"fmt"
+	"slices"
+
+	"adgui/commands"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/widget"
+)
+
+// exclusionSetsControls builds the exclusion set row of the Domains tab.
+// current returns the mode and domains shown in the tab; reload refreshes the
+// tab after an activation changed the CLI list. The returned function reloads
+// the sets and may be called from any goroutine.
+func (u *UI) exclusionSetsControls(current func() (commands.SiteExclusionMode, []string), reload func()) (fyne.CanvasObject, func()) {
+	var sets []commands.ExclusionSet
+	active := ""
+
+	setSelect := widget.NewSelect(nil, nil)
+	setSelect.PlaceHolder = lang.X("domains.sets.none", "No set")
+	activateBtn := widget.NewButton(lang.X("domains.sets.activate", "Activate"), nil)
+	newBtn := widget.NewButton(lang.X("domains.sets.new", "New"), nil)
+	renameBtn := widget.NewButton(lang.X("domains.sets.rename", "Rename"), nil)
+	duplicateBtn := widget.NewButton(lang.X("domains.sets.duplicate", "Duplicate"), nil)
+	deleteBtn := widget.NewButton(lang.X("domains.sets.delete", "Delete"), nil)
+
+	updateButtons := func() {
+		selected := setSelect.Selected
+		for _, btn := range []*widget.Button{renameBtn, duplicateBtn, deleteBtn} {
+			if selected == "" {
+				btn.Disable()
+			} else {
+				btn.Enable()
+			}
+		}
+		if selected == "" || selected == active {
+			activateBtn.Disable()
+		} else {
+			activateBtn.Enable()
+		}
+	}
+	setSelect.OnChanged = func(string) { updateButtons() }
+
+	refresh := func() {
+		loaded, err := commands.ListExclusionSets()
+		if err != nil {
+			fmt.Printf("load exclusion sets error: %v\n", err)
+		}
+		activeName, err := commands.ActiveExclusionSet()
+		if err != nil {
+			fmt.Printf("load active exclusion set error: %v\n", err)
+		}
+		fyne.Do(func() {
+			sets = loaded
+			active = activeName
+			names := make([]string, len(sets))
+			for i, set := range sets {
+				names[i] = set.Name
+			}
+			setSelect.SetOptions(names)
+			if slices.Contains(names, active) {
+				setSelect.SetSelected(active)
+			} else {
+				setSelect.ClearSelected()
+			}
+			updateButtons()
+		})
+	}
+
+	selectedSet := func() (commands.ExclusionSet, bool) {
+		for _, set := range sets {
+			if set.Name == setSelect.Selected {
+				return set, true
+			}
+		}
+		return commands.ExclusionSet{}, false
+	}
+
+	activateBtn.OnTapped = func() {
+		set, ok := selectedSet()
+		if !ok {
+			return
+		}
+		modeLabel := exclusionModeGeneralLabel()
+		if set.Mode == commands.SiteExclusionModeSelective {
+			modeLabel = exclusionModeSelectiveLabel()
+		}
+		dialog.ShowConfirm(
+			lang.X("domains.sets.activate.title", "Activate set"),
+			lang.XN("domains.sets.activate.confirm",
+				"Replace the list with {{.Count}} domains of {{.Name}}?\n{{.Mode}}",
+				len(set.Domains), map[string]any{"Mode": modeLabel, "Count": len(set.Domains), "Name": set.Name}),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				_, domains := current()
+				activateBtn.Disable()
+				go func() {
+					if err := u.vpnmgr.ActivateExclusionSet(u.ctx, set.Name, domains); err != nil {
+						fmt.Printf("activate exclusion set error: %v\n", err)
+						fyne.Do(func() { dialog.ShowError(err, u.dashboardWindow) })
+					}
+					u.clearIPRegionCache()
+					reload()
+					refresh()
+				}()
+			}, u.dashboardWindow)
+	}
+
+	newBtn.OnTapped = func() {
+		u.promptExclusionSetName(lang.X("domains.sets.new.title", "Save the list as a set"), "", func(name string) error {
+			mode, domains := current()
+			if err := commands.CreateExclusionSet(commands.ExclusionSet{Name: name, Mode: mode, Domains: domains}); err != nil {
+				return err
+			}
+			// The set is the list the CLI has now.
+			if err := commands.SetActiveExclusionSet(name); err != nil {
+				return err
+			}
+			refresh()
+			return nil
+		})
+	}
+
+	renameBtn.OnTapped = func() {
+		set, ok := selectedSet()
+		if !ok {
+			return
+		}
+		u.promptExclusionSetName(lang.X("domains.sets.rename.title", "Rename set"), set.Name, func(name string) error {
+			if err := commands.RenameExclusionSet(set.Name, name); err != nil {
+				return err
+			}
+			refresh()
+			return nil
+		})
+	}
+
+	duplicateBtn.OnTapped = func() {
+		set, ok := selectedSet()
+		if !ok {
+			return
+		}
+		u.promptExclusionSetName(lang.X("domains.sets.duplicate.title", "Duplicate set"), set.Name, func(name string) error {
+			if err := commands.DuplicateExclusionSet(set.Name, name); err != nil {
+				return err
+			}
+			refresh()
+			return nil
+		})
+	}
+
+	deleteBtn.OnTapped = func() {
+		set, ok := selectedSet()
+		if !ok {
+			return
+		}
+		dialog.ShowConfirm(
+			lang.X("domains.sets.delete.title", "Delete set"),
+			lang.X("domains.sets.delete.confirm", "Delete the set {{.Name}}? The current list stays as it is.", map[string]any{"Name": set.Name}),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := commands.DeleteExclusionSet(set.Name); err != nil {
+					dialog.ShowError(err, u.dashboardWindow)
+				}
+				refresh()
+			}, u.dashboardWindow)
+	}
+
+	updateButtons()
+	refresh()
+	label := widget.NewLabel(lang.X("domains.sets.label", "Set:"))
+	buttons := container.NewHBox(activateBtn, newBtn, renameBtn, duplicateBtn, deleteBtn)
+	return container.NewBorder(nil, nil, label, buttons, setSelect), refresh
+}
+
+// promptExclusionSetName asks for a set name; an error of onName is shown
+// and keeps nothing changed.
+func (u *UI) promptExclusionSetName(title, initial string, onName func(name string) error) {
+	entry := widget.NewEntry()
+	entry.SetText(initial)
+	entry.Validator = commands.ValidateExclusionSetName
+	dialog.ShowForm(title, lang.X("domains.sets.save", "Save"), lang.X("domains.sets.cancel", "Cancel"),
+		[]*widget.FormItem{widget.NewFormItem(lang.X("domains.sets.name", "Name"), entry)},
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := onName(entry.Text); err != nil {
+				dialog.ShowError(err, u.dashboardWindow)
+			}
+		}, u.dashboardWindow)
+}
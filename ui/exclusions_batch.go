@@ -0,0 +1,88 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"adgui/commands"
+	"adgui/domains"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showBatchProgressDialog shows the progress of a batch of exclusion changes.
+// The returned update function fills the bar and may be called from any
+// goroutine; the hide function closes the dialog.
+func showBatchProgressDialog(title, message string, window fyne.Window, onCancel func()) (func(done, total int), func()) {
+	bar := widget.NewProgressBar()
+	content := container.NewVBox(widget.NewLabel(message), bar)
+	d := dialog.NewCustom(title, lang.X("domains.progress.cancel", "Cancel"), content, window)
+	d.SetOnClosed(onCancel)
+	d.Show()
+	update := func(done, total int) {
+		fyne.Do(func() {
+			bar.Max = float64(total)
+			bar.SetValue(float64(done))
+		})
+	}
+	return update, func() { fyne.Do(d.Hide) }
+}
+
+// showBatchReport summarizes a batch that did not apply every domain,
+// listing the failed domains with their errors; rejected names get the
+// reason they are not domains.
+func showBatchReport(title string, report commands.BatchReport, window fyne.Window) {
+	failed := report.Failed()
+	skipped := report.Skipped()
+	if len(failed) == 0 && len(skipped) == 0 {
+		return
+	}
+
+	total := len(report.Results)
+	content := container.NewVBox(widget.NewLabel(lang.XN("domains.batch.summary",
+		"{{.Count}} of {{.Total}} domains applied", total,
+		map[string]any{"Count": len(report.Applied()), "Total": total})))
+	if len(skipped) > 0 {
+		content.Add(widget.NewLabel(lang.XN("domains.batch.skipped",
+			"{{.Count}} domains skipped after cancelling", len(skipped), map[string]any{"Count": len(skipped)})))
+	}
+	var body fyne.CanvasObject = content
+	if len(failed) > 0 {
+		lines := make([]string, len(failed))
+		for i, res := range failed {
+			if errors.Is(res.Err, domains.ErrInvalidDomain) {
+				lines[i] = fmt.Sprintf("%s: %s", res.Domain, domainReason(res.Err))
+				continue
+			}
+			lines[i] = fmt.Sprintf("%s: %v", res.Domain, res.Err)
+		}
+		list := widget.NewLabelWithStyle(strings.Join(lines, "\n"), fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		scroll := container.NewScroll(list)
+		scroll.SetMinSize(fyne.NewSize(480, 200))
+		content.Add(widget.NewLabel(lang.X("domains.batch.failed", "Failed domains:")))
+		body = container.NewBorder(content, nil, nil, nil, scroll)
+	}
+	fyne.Do(func() {
+		dialog.ShowCustom(title, lang.X("domains.batch.close", "Close"), body, window)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"fmt"
+	"slices"
+
+	"adgui/commands"
+	"adgui/config"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/lang"
+)
+
+// failoverMenuItem returns the tray submenu that turns failover steps on and off.
+// The choice is saved for the next start. ADGUARD_FAILOVER overrides it, so
+// while the key is set the submenu only shows the configured steps.
+func (u *UI) failoverMenuItem() *fyne.MenuItem {
+	item := fyne.NewMenuItem(lang.X("tray.menu.failover", "On connect failure"), nil)
+	value, err := config.AdguardFailover()
+	if err != nil {
+		fmt.Printf("config read error for failover: %v\n", err)
+	}
+	configured := value != ""
+
+	var entries []*fyne.MenuItem
+	if configured {
+		note := fyne.NewMenuItem(lang.X("tray.menu.failover.configured", "Set by ADGUARD_FAILOVER in adguirc"), nil)
+		note.Disabled = true
+		entries = append(entries, note, fyne.NewMenuItemSeparator())
+	}
+	for _, step := range commands.DefaultFailoverSteps() {
+		entry := fyne.NewMenuItem(failoverStepLabel(step), nil)
+		entry.Checked = slices.Contains(u.vpnmgr.FailoverSteps(), step)
+		entry.Disabled = configured
+		entry.Action = func() {
+			steps := u.toggleFailoverStep(step)
+			entry.Checked = slices.Contains(steps, step)
+			u.desk.SetSystemTrayMenu(u.menu)
+		}
+		entries = append(entries, entry)
+	}
+	item.ChildMenu = fyne.NewMenu("", entries...)
+	return item
+}
+
+// toggleFailoverStep removes every occurrence of step from the chain, or adds
+// it at its place in the default order. The other steps stay as they are,
+// repeats included.
+func (u *UI) toggleFailoverStep(step commands.FailoverStep) []commands.FailoverStep {
+	current := u.vpnmgr.FailoverSteps()
+	var steps []commands.FailoverStep
+	if slices.Contains(current, step) {
+		for _, s := range current {
+			if s != step {
+				steps = append(steps, s)
+			}
+		}
+	} else {
+		order := commands.DefaultFailoverSteps()
+		rank := slices.Index(order, step)
+		at := slices.IndexFunc(current, func(s commands.FailoverStep) bool {
+			return slices.Index(order, s) > rank
+		})
+		if at < 0 {
+			at = len(current)
+		}
+		steps = slices.Insert(current, at, step)
+	}
+	u.vpnmgr.SetFailoverSteps(steps)
+	if err := commands.SaveFailoverSteps(steps); err != nil {
+		fmt.Printf("failed to save failover chain: %v\n", err)
+	}
+	return steps
+}
+
+func failoverStepLabel(step commands.FailoverStep) string {
+	switch step {
+	case commands.FailoverBookmarks:
+		return lang.X("tray.menu.failover.bookmarks", "Try the next bookmark")
+	case commands.FailoverCountry:
+		return lang.X("tray.menu.failover.country", "Try the fastest location in the same country")
+	case commands.FailoverAuto:
+		return lang.X("tray.menu.failover.auto", "Connect the best")
+	default:
+		return string(step)
+	}
+}
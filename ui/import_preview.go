@@ -0,0 +1,134 @@
+// Copyright (C) 2026 Alexander Grafov <grafov@inet.name>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"adgui/domains"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/widget"
+)
+
+// importFormatLabel names the detected file format in the import preview.
+func importFormatLabel(format domains.Format) string {
+	switch format {
+	case domains.FormatHosts:
+		return lang.X("domains.import.format.hosts", "hosts file")
+	case domains.FormatAdblock:
+		return lang.X("domains.import.format.adblock", "Adblock filter list")
+	case domains.FormatCSV:
+		return lang.X("domains.import.format.csv", "CSV table")
+	case domains.FormatJSON:
+		return lang.X("domains.import.format.json", "JSON")
+	case domains.FormatAdGuard:
+		return lang.X("domains.import.format.adguard", "AdGuard export")
+	default:
+		return lang.X("domains.import.format.plain", "plain list")
+	}
+}
+
+// importSkipReason explains why an entry of the imported file was not taken.
+func importSkipReason(err error) string {
+	switch {
+	case errors.Is(err, domains.ErrUnsupportedRule):
+		return lang.X("domains.import.reason.rule", "unsupported filter rule")
+	case errors.Is(err, domains.ErrDisabled):
+		return lang.X("domains.import.reason.disabled", "disabled in the export")
+	case errors.Is(err, domains.ErrDuplicate):
+		return lang.X("domains.import.reason.duplicate", "duplicate in the file")
+	case errors.Is(err, domains.ErrExisting):
+		return lang.X("domains.import.reason.existing", "already in the list")
+	default:
+		return domainReason(err)
+	}
+}
+
+// showImportPreview lists the domains found in an imported file and the
+// skipped entries with their reasons. onConfirm runs when the user accepts
+// and there is something to add.
+func showImportPreview(result domains.Result, window fyne.Window, onConfirm func(toAdd []string)) {
+	summary := widget.NewLabel(lang.X("domains.import.preview.summary",
+		"Format: {{.Format}}. To add: {{.Added}}, skipped: {{.Skipped}}.", map[string]any{
+			"Format":  importFormatLabel(result.Format),
+			"Added":   len(result.Domains),
+			"Skipped": len(result.Skipped),
+		}))
+	summary.Wrapping = fyne.TextWrapWord
+
+	addedLines := make([]string, len(result.Domains))
+	for i, domain := range result.Domains {
+		addedLines[i] = domains.ToUnicode(domain)
+	}
+	added := previewList(addedLines)
+	skippedLines := make([]string, len(result.Skipped))
+	for i, skipped := range result.Skipped {
+		line := fmt.Sprintf("%s — %s", skipped.Text, importSkipReason(skipped.Reason))
+		if skipped.Line > 0 {
+			line = fmt.Sprintf("%d: %s", skipped.Line, line)
+		}
+		skippedLines[i] = line
+	}
+	tabs := container.NewAppTabs(
+		container.NewTabItem(lang.X("domains.import.preview.added", "To add"), added),
+		container.NewTabItem(lang.X("domains.import.preview.skipped", "Skipped"), previewList(skippedLines)),
+	)
+	if len(result.Domains) == 0 && len(result.Skipped) > 0 {
+		tabs.SelectIndex(1)
+	}
+	body := container.NewBorder(summary, nil, nil, nil, tabs)
+
+	if len(result.Domains) == 0 {
+		d := dialog.NewCustom(lang.X("domains.import.title", "Import"), lang.X("domains.batch.close", "Close"), body, window)
+		d.Resize(fyne.NewSize(560, 400))
+		d.Show()
+		return
+	}
+	d := dialog.NewCustomConfirm(
+		lang.X("domains.import.title", "Import"),
+		lang.X("domains.import.preview.confirm", "Add"),
+		lang.X("domains.progress.cancel", "Cancel"),
+		body,
+		func(ok bool) {
+			if ok {
+				onConfirm(result.Domains)
+			}
+		},
+		window,
+	)
+	d.Resize(fyne.NewSize(560, 400))
+	d.Show()
+}
+
+func previewList(lines []string) fyne.CanvasObject {
+	if len(lines) == 0 {
+		return widget.NewLabel(lang.X("domains.import.preview.empty", "Nothing here"))
+	}
+	return widget.NewList(
+		func() int { return len(lines) },
+		func() fyne.CanvasObject {
+			return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(lines[id])
+		},
+	)
+}
@@ -16,7 +16,9 @@
 package ui
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
 	"adgui/commands"
 	"adgui/commands/sudowrap"
@@ -113,16 +115,103 @@ func (u *UI) activeWindow() fyne.Window {
 	return u.promptWindow
 }
 
-func (u *UI) runPrivileged(action func()) {
+// runPrivileged runs a connect or disconnect after sudo authentication.
+// The operation can be stopped with cancelOperation while it runs or waits
+// behind another one.
+func (u *UI) runPrivileged(action func(ctx context.Context) error) {
 	go func() {
 		if err := u.vpnmgr.EnsureSudoPassword(); err != nil {
 			u.showSudoAuthError(err)
 			return
 		}
-		action()
+		ctx, cancel := context.WithCancel(u.ctx)
+		u.opmx.Lock()
+		u.nextOp++
+		id := u.nextOp
+		u.cancelOps[id] = cancel
+		u.opmx.Unlock()
+		defer func() {
+			u.opmx.Lock()
+			delete(u.cancelOps, id)
+			u.opmx.Unlock()
+			cancel()
+			u.requestUpdate()
+		}()
+		u.requestUpdate()
+
+		if err := action(ctx); err != nil {
+			u.showOperationError(err)
+		}
 	}()
 }
 
+// cancelOperation stops the running and queued privileged operations and the
+// CLI process group. It reports whether there were any.
+func (u *UI) cancelOperation() bool {
+	u.opmx.Lock()
+	defer u.opmx.Unlock()
+	if len(u.cancelOps) == 0 {
+		return false
+	}
+	for id, cancel := range u.cancelOps {
+		cancel()
+		delete(u.cancelOps, id)
+	}
+	return true
+}
+
+func (u *UI) operationRunning() bool {
+	u.opmx.Lock()
+	defer u.opmx.Unlock()
+	return len(u.cancelOps) > 0
+}
+
+// showOperationError explains a failed VPN operation in a dialog. Sudo prompt
+// failures keep their own messages; a click while another operation runs, a
+// connect replaced by a newer one and a cancelled operation are ignored.
+func (u *UI) showOperationError(err error) {
+	fmt.Printf("VPN operation error: %v\n", err)
+	if errors.Is(err, commands.ErrInvalidTransition) || errors.Is(err, commands.ErrSuperseded) ||
+		commands.ErrorKindOf(err) == commands.ErrorCanceled {
+		return
+	}
+	if errors.Is(err, errSudoPromptCancelled) || errors.Is(err, commands.ErrSudoPasswordRequired) ||
+		errors.Is(err, commands.ErrSudoPasswordPrompt) || errors.Is(err, sudowrap.ErrInvalidPassword) {
+		u.showSudoAuthError(err)
+		return
+	}
+	message := cliErrorMessage(err)
+	fyne.Do(func() {
+		dialog.ShowError(errors.New(message), u.activeWindow())
+	})
+}
+
+// cliErrorMessage describes a failed VPN operation by its kind,
+// falling back to the CLI output for unclassified failures.
+func cliErrorMessage(err error) string {
+	switch commands.ErrorKindOf(err) {
+	case commands.ErrorNotLoggedIn:
+		return lang.X("error.not_logged_in", "You are not logged in to AdGuard VPN. Run `adguardvpn-cli login` in a terminal.")
+	case commands.ErrorSubscriptionExpired:
+		return lang.X("error.subscription_expired", "Your AdGuard VPN subscription has expired.")
+	case commands.ErrorDeviceLimit:
+		return lang.X("error.device_limit", "Your AdGuard VPN account has reached its device limit.")
+	case commands.ErrorLocationUnknown:
+		return lang.X("error.location_unknown", "This location is not available.")
+	case commands.ErrorSudoDenied:
+		return lang.X("error.sudo_denied", "Administrator privileges were denied.")
+	case commands.ErrorCLIMissing:
+		return lang.X("error.cli_missing", "adguardvpn-cli was not found. Install it or set ADGUARD_CMD in ~/.config/adgui/adguirc.")
+	case commands.ErrorTimeout:
+		return lang.X("error.timeout", "adguardvpn-cli did not respond in time.")
+	}
+	var cliErr *commands.CLIError
+	if errors.As(err, &cliErr) {
+		return cliErr.Message
+	}
+	return err.Error()
+}
+
 func (u *UI) showSudoAuthError(err error) {
 	if errors.Is(err, errSudoPromptCancelled) || errors.Is(err, commands.ErrSudoPasswordRequired) {
 		return
@@ -16,16 +16,17 @@
 package ui
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
-	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"adgui/commands"
+	"adgui/domains"
 	"adgui/ipregion"
 	"adgui/locations"
 	"adgui/theme"
@@ -46,9 +47,9 @@ var (
 	DisconnectedColor       = color.NRGBA{R: 128, G: 128, B: 128, A: 255} // Серый
 	DisconnectedStatusColor = color.NRGBA{R: 255, G: 0, B: 0, A: 255}     // Красный
 	ConnectedColor          = color.NRGBA{R: 0, G: 255, B: 0, A: 255}     // Зеленый
-	WarningColor      = color.NRGBA{R: 255, G: 255, B: 0, A: 255}   // Желтый
-	StarInactiveColor = color.NRGBA{R: 160, G: 160, B: 160, A: 255}
-	StarActiveColor   = color.NRGBA{R: 255, G: 193, B: 7, A: 255}
+	WarningColor            = color.NRGBA{R: 255, G: 255, B: 0, A: 255}   // Желтый
+	StarInactiveColor       = color.NRGBA{R: 160, G: 160, B: 160, A: 255}
+	StarActiveColor         = color.NRGBA{R: 255, G: 193, B: 7, A: 255}
 )
 
 const (
@@ -61,7 +62,10 @@ const (
 	locationTableCols  = 6
 )
 
-const domainsTabIndex = 3
+const (
+	ipRegionTabIndex = 1
+	domainsTabIndex  = 3
+)
 
 type (
 	// Properties related to UI.
@@ -105,6 +109,11 @@ type (
 		// Hidden window for modal prompts when dashboard is closed
 		promptWindow fyne.Window
 
+		// Cancels running and queued privileged operations
+		opmx      sync.Mutex
+		nextOp    uint64
+		cancelOps map[uint64]context.CancelFunc
+
 		// and...
 		withLogicIncluded
 	}
@@ -112,6 +121,9 @@ type (
 	withLogicIncluded struct {
 		vpnmgr    *commands.VPNManager
 		checkReqs chan struct{}
+		// ctx lives until Run returns; CLI commands still running then are stopped.
+		ctx    context.Context
+		cancel context.CancelFunc
 	}
 )
 
@@ -121,30 +133,29 @@ func New(vpnmgr *commands.VPNManager, appVersion string) *UI {
 		fyne.LogError("failed to load translations", err)
 	}
 	myApp.SetIcon(theme.DisconnectedIcon)
+	ctx, cancel := context.WithCancel(context.Background())
 	logic := withLogicIncluded{
 		vpnmgr:    vpnmgr,
 		checkReqs: make(chan struct{}, 1),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 	desk, ok := myApp.(desktop.App)
 	ui := UI{
 		Fyne:              myApp,
 		desk:              desk,
 		updateReqs:        make(chan struct{}, 1),
+		cancelOps:         make(map[uint64]context.CancelFunc),
 		appVersion:        appVersion,
 		withLogicIncluded: logic,
 	}
 	ui.installSudoPasswordPrompt()
 	if ok {
 		ui.createTrayMenu()
-		// Register callback to notify UI about status changes
-		vpnmgr.SetStatusChangeCallback(func() {
-			select {
-			case ui.updateReqs <- struct{}{}:
-			default:
-			}
-		})
+		events, _ := vpnmgr.Subscribe(32)
+		go ui.watchEvents(events)
 		go func() {
-			_, exclusions, err := vpnmgr.GetSiteExclusions()
+			_, exclusions, err := vpnmgr.GetSiteExclusions(ctx)
 			if err != nil {
 				fmt.Printf("load exclusions mode error: %v\n", err)
 				return
@@ -157,8 +168,28 @@ func New(vpnmgr *commands.VPNManager, appVersion string) *UI {
 	return &ui
 }
 
+// watchEvents turns manager events into tray and dashboard refreshes.
+// Every event kind is visible somewhere in the UI; bursts collapse into one pending request.
+func (u *UI) watchEvents(events <-chan commands.Event) {
+	for range events {
+		select {
+		case u.updateReqs <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// requestUpdate schedules a tray and dashboard refresh.
+func (u *UI) requestUpdate() {
+	select {
+	case u.updateReqs <- struct{}{}:
+	default:
+	}
+}
+
 func (u *UI) Run() {
 	u.Fyne.Run()
+	u.cancel()
 }
 
 func (u *UI) startPasteWatcher() {
@@ -184,13 +215,13 @@ func (u *UI) createTrayMenu() {
 		u.Dashboard()
 	})
 	connectAuto := fyne.NewMenuItem(lang.X("tray.menu.connect_best", "Connect the best"), func() {
-		u.runPrivileged(func() { u.vpnmgr.ConnectAuto() })
+		u.runPrivileged(u.vpnmgr.ConnectAuto)
 	})
 	connectTo := fyne.NewMenuItem(lang.X("tray.menu.connect_to", "Connect To..."), func() {
 		u.LocationSelector()
 	})
 	disconnect := fyne.NewMenuItem(lang.X("tray.menu.disconnect", "Disconnect"), func() {
-		u.runPrivileged(func() { u.vpnmgr.Disconnect() })
+		u.runPrivileged(u.vpnmgr.Disconnect)
 	})
 	quitItem := fyne.NewMenuItem(lang.X("tray.menu.quit", "Quit"), func() {
 		parent := u.activeWindow()
@@ -229,6 +260,7 @@ func (u *UI) createTrayMenu() {
 		fyne.NewMenuItemSeparator(),
 		disconnect,
 		fyne.NewMenuItemSeparator(),
+		u.failoverMenuItem(),
 		quitItem,
 	)
 	u.menu.Items[0].Disabled = true // status field
@@ -247,7 +279,9 @@ func (u *UI) updateMenuItems() {
 		domainsMenuItem := u.domainsMenuItem
 		fyne.Do(func() {
 			items := u.menu.Items
-			if u.vpnmgr.IsConnected() {
+			state := u.vpnmgr.ConnectionState()
+			switch state.State {
+			case commands.StateConnected:
 				u.menu.Label = lang.X("tray.menu.vpn_connected", "VPN connected")
 				items[0].Icon = theme.MenuConnectedIcon
 				modeSuffix := "GEN"
@@ -258,22 +292,44 @@ func (u *UI) updateMenuItems() {
 					"Location": strings.ToUpper(u.vpnmgr.Location()),
 					"Mode":     modeSuffix,
 				})
-			} else {
+			case commands.StateConnecting, commands.StateDisconnecting, commands.StateReconnecting:
+				u.menu.Label = connectionStateLabel(state)
+				items[0].Icon = theme.MenuPendingIcon
+				items[0].Label = connectionStateLabel(state)
+			case commands.StateError:
+				u.menu.Label = lang.X("tray.menu.vpn_disconnected", "VPN disconnected")
+				items[0].Icon = theme.MenuDisconnectedIcon
+				items[0].Label = u.stateLabel(state)
+			default:
 				u.menu.Label = lang.X("tray.menu.vpn_disconnected", "VPN disconnected")
 				items[0].Icon = theme.MenuDisconnectedIcon
 				items[0].Label = lang.X("tray.menu.off", "OFF")
 			}
-			connected := u.vpnmgr.IsConnected()
+			if warnings := u.vpnmgr.Warnings(); len(warnings) > 0 {
+				items[0].Label = lang.X("tray.status.warning", "⚠ {{.Status}}", map[string]any{
+					"Status": items[0].Label,
+				})
+			}
+			killSwitch := u.vpnmgr.KillSwitchEngaged()
+			if killSwitch {
+				items[0].Label = lang.X("tray.status.killswitch", "{{.Status}} · kill switch", map[string]any{
+					"Status": items[0].Label,
+				})
+			}
+			connected := state.State == commands.StateConnected
+			busy := state.State.Busy()
 			if domainsMenuItem != nil {
 				domainsMenuItem.Label = domainsMenuLabel(domainsCount)
 			}
 			// false - means available
 			items[1].Disabled = false
-			items[2].Disabled = connected  // Connect the best
-			items[3].Disabled = false      // Connect To...
-			items[4].Disabled = false      // Domains
-			items[6].Disabled = !connected // Disconnect
-			items[8].Disabled = false      // Quit
+			items[2].Disabled = connected || busy // Connect the best
+			items[3].Disabled = busy              // Connect To...
+			items[4].Disabled = false             // Domains
+			// Disconnect also lifts kill switch rules left after the tunnel dropped.
+			items[6].Disabled = busy || (state.State == commands.StateDisconnected && !killSwitch)
+			items[8].Disabled = false // On connect failure
+			items[9].Disabled = false // Quit
 			u.menu.Items = items
 			u.desk.SetSystemTrayMenu(u.menu)
 		})
@@ -298,14 +354,46 @@ func (u *UI) updateTrayIcon() {
 	defer u.traymx.RUnlock()
 
 	fyne.Do(func() {
-		if u.vpnmgr.IsConnected() {
+		state := u.vpnmgr.ConnectionState().State
+		switch {
+		case state == commands.StateConnected:
 			u.desk.SetSystemTrayIcon(theme.ConnectedIcon)
-		} else {
+		case state.Busy():
+			u.desk.SetSystemTrayIcon(theme.PendingIcon)
+		default:
 			u.desk.SetSystemTrayIcon(theme.DisconnectedIcon)
 		}
 	})
 }
 
+// connectionStateLabel describes intermediate and error states for the tray and dashboard.
+func connectionStateLabel(state commands.StateInfo) string {
+	switch state.State {
+	case commands.StateConnecting:
+		return lang.X("state.connecting", "Connecting…")
+	case commands.StateDisconnecting:
+		return lang.X("state.disconnecting", "Disconnecting…")
+	case commands.StateReconnecting:
+		return lang.X("state.reconnecting", "Reconnecting…")
+	case commands.StateError:
+		return lang.X("state.error", "Error: {{.Reason}}", map[string]any{"Reason": state.Reason})
+	case commands.StateConnected:
+		return lang.X("tray.menu.vpn_connected", "VPN connected")
+	default:
+		return lang.X("connections.disconnected", "Disconnected")
+	}
+}
+
+// stateLabel is connectionStateLabel with StateError explained by the classified failure.
+func (u *UI) stateLabel(state commands.StateInfo) string {
+	if state.State == commands.StateError {
+		if failure := u.vpnmgr.LastError(); failure != nil {
+			return lang.X("state.error", "Error: {{.Reason}}", map[string]any{"Reason": cliErrorMessage(failure)})
+		}
+	}
+	return connectionStateLabel(state)
+}
+
 func (u *UI) setDomainsCount(count int) {
 	u.traymx.Lock()
 	u.domainsCount = count
@@ -343,17 +431,35 @@ func (u *UI) selectDashboardTab(index int) {
 	})
 }
 
+// connectButtonCancels reports whether the dashboard button is in its Cancel
+// state: a transition is under way and this UI started the operation.
+func (u *UI) connectButtonCancels() bool {
+	return u.vpnmgr.ConnectionState().State.Busy() && u.operationRunning()
+}
+
 func (u *UI) updateDashboardButtons() {
 	if u.dashboardConnectBtn == nil {
 		return
 	}
 
-	connected := u.vpnmgr.IsConnected()
-	if connected {
+	state := u.vpnmgr.ConnectionState()
+	switch {
+	case state.State.Busy() && u.operationRunning():
+		u.dashboardConnectBtn.SetText(lang.X("dashboard.cancel", "Cancel: {{.State}}", map[string]any{
+			"State": connectionStateLabel(state),
+		}))
+		u.dashboardConnectBtn.Enable()
+		return
+	case state.State.Busy():
+		u.dashboardConnectBtn.SetText(connectionStateLabel(state))
+		u.dashboardConnectBtn.Disable()
+		return
+	case state.State == commands.StateConnected:
 		u.dashboardConnectBtn.SetText(lang.X("dashboard.disconnect", "Disconnect"))
-	} else {
+	default:
 		u.dashboardConnectBtn.SetText(lang.X("dashboard.connect", "Connect"))
 	}
+	u.dashboardConnectBtn.Enable()
 }
 
 func (u *UI) updateDashboard() {
@@ -485,7 +591,7 @@ func (u *UI) Dashboard() string {
 func (u *UI) licensePanel() *fyne.Container {
 	licenseLabel := parseAnsi(lang.X("license.loading", "Loading license..."))
 	go func() {
-		text := u.vpnmgr.License()
+		text := u.vpnmgr.License(u.ctx)
 		fyne.Do(func() {
 			parsed := parseAnsi(text)
 			licenseLabel.Segments = parsed.Segments
@@ -513,16 +619,19 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 		lowerQuery := strings.ToLower(query)
 		var res []string
 		for _, item := range items {
-			if strings.Contains(strings.ToLower(item), lowerQuery) {
+			if strings.Contains(strings.ToLower(item), lowerQuery) ||
+				strings.Contains(strings.ToLower(domains.ToUnicode(item)), lowerQuery) {
 				res = append(res, item)
 			}
 		}
 		return res
 	}
 
-	containsIgnoreCase := func(items []string, value string) bool {
+	// containsDomain compares normalized forms, so a Unicode name matches its punycode.
+	containsDomain := func(items []string, value string) bool {
+		key := domains.Key(value)
 		for _, item := range items {
-			if strings.EqualFold(item, value) {
+			if domains.Key(item) == key {
 				return true
 			}
 		}
@@ -559,7 +668,7 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 
 	reloadExclusions = func() {
 		go func() {
-			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions()
+			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions(u.ctx)
 			if loadErr != nil {
 				fmt.Printf("reload exclusions error: %v\n", loadErr)
 				return
@@ -589,10 +698,10 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 			removeBtn := cont.Objects[2].(*widget.Button)
 
 			domain := filtered[id]
-			label.SetText(domain)
+			label.SetText(domains.ToUnicode(domain))
 			removeBtn.OnTapped = func() {
 				go func(target string) {
-					if err := u.vpnmgr.RemoveSiteExclusion(target); err != nil {
+					if err := u.vpnmgr.RemoveSiteExclusion(u.ctx, target); err != nil {
 						fmt.Printf("remove exclusion error: %v\n", err)
 						return
 					}
@@ -608,21 +717,41 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 	}
 
 	appendCurrent := func() {
-		domain := strings.TrimSpace(filterEntry.Text)
-		if domain == "" {
-			return
+		// Invalid entries are passed on too: the batch rejects them without
+		// a CLI call and the report lists them with the reason.
+		var toAdd []string
+		for _, entry := range domains.Split(filterEntry.Text) {
+			if !containsDomain(exclusions, entry) && !containsDomain(toAdd, entry) {
+				toAdd = append(toAdd, entry)
+			}
 		}
-		if containsIgnoreCase(exclusions, domain) {
+		if len(toAdd) == 0 {
 			return
 		}
 		fyne.Do(func() { filterEntry.SetText("") }) // reset filter text on append
-		go func(value string) {
-			if err := u.vpnmgr.AddSiteExclusion(value); err != nil {
-				fmt.Printf("add exclusion error: %v\n", err)
-				return
+
+		title := lang.X("domains.append.title", "Add domains")
+		ctx, cancel := context.WithCancel(u.ctx)
+		updateProgress, hideProgress := showBatchProgressDialog(
+			title,
+			lang.XN("domains.import.progress", "Adding {{.Count}} domains...", len(toAdd), map[string]any{"Count": len(toAdd)}),
+			u.dashboardWindow,
+			cancel,
+		)
+		go func() {
+			defer cancel()
+			report, err := u.vpnmgr.BatchSiteExclusions(ctx, commands.BatchAdd, toAdd,
+				commands.BatchOptions{OnProgress: updateProgress})
+			hideProgress()
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					fyne.Do(func() { dialog.ShowError(err, u.dashboardWindow) })
+				}
+			} else {
+				showBatchReport(title, report, u.dashboardWindow)
 			}
 			reloadExclusionsAndSave()
-		}(domain)
+		}()
 	}
 
 	filterEntry.OnSubmitted = func(_ string) {
@@ -635,28 +764,16 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 	var pasteBtn *widget.Button
 
 	parseDomainFromClipboard := func(content string) string {
-		content = strings.TrimSpace(content)
-		if content == "" {
-			return ""
-		}
-		fields := strings.Fields(content)
-		if len(fields) == 0 {
-			return ""
-		}
-		token := fields[0]
-		if !strings.Contains(token, "://") {
-			token = "http://" + token
-		}
-		parsed, parseErr := url.Parse(token)
-		if parseErr != nil {
+		entries := domains.Split(content)
+		if len(entries) == 0 {
 			return ""
 		}
-		host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
-		host = strings.TrimPrefix(host, "www.")
-		if !strings.Contains(host, ".") {
+		host, err := domains.Normalize(entries[0])
+		if err != nil {
 			return ""
 		}
-		return host
+		host = strings.TrimPrefix(host, "*.")
+		return strings.TrimPrefix(host, "www.")
 	}
 
 	updatePasteButtonState := func() {
@@ -691,14 +808,14 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 			if domain == "" {
 				return
 			}
-			filterEntry.SetText(domain)
+			filterEntry.SetText(domains.ToUnicode(domain))
 			go func(target string) {
 				entries := []string{"www." + target, "*." + target}
 				for _, entry := range entries {
-					if containsIgnoreCase(exclusions, entry) {
+					if containsDomain(exclusions, entry) {
 						continue
 					}
-					if err := u.vpnmgr.AddSiteExclusion(entry); err != nil {
+					if err := u.vpnmgr.AddSiteExclusion(u.ctx, entry); err != nil {
 						fmt.Printf("add exclusion error: %v\n", err)
 						return
 					}
@@ -756,12 +873,14 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 		snapshot := append([]string(nil), exclusions...)
 		go func() {
 			defer fyne.Do(modeRadio.Enable)
-			if err := u.vpnmgr.SetSiteExclusionsMode(targetMode, snapshot); err != nil {
+			if err := u.vpnmgr.SetSiteExclusionsMode(u.ctx, targetMode, snapshot); err != nil {
 				fmt.Printf("set exclusions mode error: %v\n", err)
 				fyne.Do(func() {
 					mode = previousMode
 					selectExclusionModeRadio()
+					dialog.ShowError(err, u.dashboardWindow)
 				})
+				reloadExclusions()
 				return
 			}
 			fyne.Do(func() {
@@ -841,20 +960,14 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 				_ = reader.Close()
 			}()
 
-			scanner := bufio.NewScanner(reader)
-			var toAdd []string
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
-				if line != "" && !containsIgnoreCase(exclusions, line) {
-					toAdd = append(toAdd, line)
-				}
-			}
-			if err := scanner.Err(); err != nil {
+			result, err := domains.ParseReader(reader.URI().Name(), reader, domains.Options{Mode: mode.String()})
+			if err != nil {
 				dialog.ShowError(err, u.dashboardWindow)
 				return
 			}
+			result.Exclude(exclusions)
 
-			if len(toAdd) == 0 {
+			if len(result.Domains) == 0 && len(result.Skipped) == 0 {
 				dialog.ShowInformation(
 					lang.X("domains.import.title", "Import"),
 					lang.X("domains.import.no_new", "No new unique domains found"),
@@ -862,26 +975,29 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 				)
 				return
 			}
-
-			hideProgress := showInfiniteProgressDialog(
-				lang.X("domains.import.progress.title", "Importing"),
-				lang.XN("domains.import.progress", "Adding {{.Count}} domains...", len(toAdd), map[string]any{"Count": len(toAdd)}),
-				u.dashboardWindow,
-			)
-			go func(domains []string) {
-				defer hideProgress()
-				var importErr error
-				for _, domain := range domains {
-					if err := u.vpnmgr.AddSiteExclusion(domain); err != nil {
-						importErr = err
-						break
+			showImportPreview(result, u.dashboardWindow, func(toAdd []string) {
+				ctx, cancel := context.WithCancel(u.ctx)
+				updateProgress, hideProgress := showBatchProgressDialog(
+					lang.X("domains.import.progress.title", "Importing"),
+					lang.XN("domains.import.progress", "Adding {{.Count}} domains...", len(toAdd), map[string]any{"Count": len(toAdd)}),
+					u.dashboardWindow,
+					cancel,
+				)
+				go func() {
+					defer cancel()
+					report, err := u.vpnmgr.BatchSiteExclusions(ctx, commands.BatchAdd, toAdd,
+						commands.BatchOptions{OnProgress: updateProgress})
+					hideProgress()
+					if err != nil {
+						if !errors.Is(err, context.Canceled) {
+							fyne.Do(func() { dialog.ShowError(err, u.dashboardWindow) })
+						}
+					} else {
+						showBatchReport(lang.X("domains.import.title", "Import"), report, u.dashboardWindow)
 					}
-				}
-				if importErr != nil {
-					fyne.Do(func() { dialog.ShowError(importErr, u.dashboardWindow) })
-				}
-				reloadExclusionsAndSave()
-			}(toAdd)
+					reloadExclusionsAndSave()
+				}()
+			})
 		}, u.dashboardWindow)
 		openDlg.Show()
 	})
@@ -896,39 +1012,44 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 			lang.X("domains.clear.title", "Clear"),
 			lang.X("domains.clear.confirm", "Clear all domains in the list?"),
 			func(ok bool) {
-			if !ok {
-				return
-			}
+				if !ok {
+					return
+				}
 
-			// Create a copy of the current exclusions to operate on
-			snapshot := append([]string(nil), exclusions...)
+				// Create a copy of the current exclusions to operate on
+				snapshot := append([]string(nil), exclusions...)
 
-			// Disable the button during operation to prevent multiple clicks
-			fyne.Do(func() {
-				clearBtn.Disable()
-			})
+				// Disable the button during operation to prevent multiple clicks
+				fyne.Do(func() {
+					clearBtn.Disable()
+				})
 
-			hideProgress := showInfiniteProgressDialog(
-				lang.X("domains.clear.progress.title", "Clearing"),
-				lang.XN("domains.clear.progress", "Removing {{.Count}} domains...", len(snapshot), map[string]any{"Count": len(snapshot)}),
-				u.dashboardWindow,
-			)
-			go func() {
-				defer func() {
+				ctx, cancel := context.WithCancel(u.ctx)
+				updateProgress, hideProgress := showBatchProgressDialog(
+					lang.X("domains.clear.progress.title", "Clearing"),
+					lang.XN("domains.clear.progress", "Removing {{.Count}} domains...", len(snapshot), map[string]any{"Count": len(snapshot)}),
+					u.dashboardWindow,
+					cancel,
+				)
+				go func() {
+					defer func() {
+						cancel()
+						fyne.Do(func() {
+							clearBtn.Enable()
+						})
+					}()
+
+					report, err := u.vpnmgr.BatchSiteExclusions(ctx, commands.BatchRemove, snapshot,
+						commands.BatchOptions{OnProgress: updateProgress})
 					hideProgress()
-					fyne.Do(func() {
-						clearBtn.Enable()
-					})
-				}()
-
-				for _, domain := range snapshot {
-					if err := u.vpnmgr.RemoveSiteExclusion(domain); err != nil {
-						fmt.Printf("remove exclusion error: %v\n", err)
+					if err != nil {
+						fmt.Printf("clear exclusions error: %v\n", err)
+					} else {
+						showBatchReport(lang.X("domains.clear.title", "Clear"), report, u.dashboardWindow)
 					}
-				}
-				reloadExclusionsAndSave()
-			}()
-		}, u.dashboardWindow)
+					reloadExclusionsAndSave()
+				}()
+			}, u.dashboardWindow)
 	})
 
 	// Initially disable clear button if list is empty
@@ -946,10 +1067,14 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 		})
 	}
 
+	setsControls, refreshSets := u.exclusionSetsControls(func() (commands.SiteExclusionMode, []string) {
+		return mode, append([]string(nil), exclusions...)
+	}, func() { reloadExclusionsAndSave() })
+
 	// Override reloadExclusions to update button state after refresh
 	reloadExclusions = func() {
 		go func() {
-			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions()
+			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions(u.ctx)
 			if loadErr != nil {
 				fmt.Printf("reload exclusions error: %v\n", loadErr)
 				return
@@ -961,13 +1086,14 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 				selectExclusionModeRadio()
 				refreshFiltered()
 				updateClearButtonState()
+				refreshSets()
 			})
 		}()
 	}
 
 	reloadExclusionsAndSave = func() {
 		go func() {
-			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions()
+			newMode, newExclusions, loadErr := u.vpnmgr.GetSiteExclusions(u.ctx)
 			if loadErr != nil {
 				fmt.Printf("reload exclusions error: %v\n", loadErr)
 				return
@@ -985,11 +1111,18 @@ func (u *UI) exclusionsPanel(stopCh <-chan struct{}) *fyne.Container {
 				} else {
 					u.clearIPRegionCache()
 				}
+				if err := commands.SyncActiveExclusionSet(mode, exclusions); err != nil {
+					fmt.Printf("failed to update active exclusion set: %v\n", err)
+				}
+				refreshSets()
 			})
 		}()
 	}
 
-	header := container.NewBorder(nil, nil, nil, container.NewHBox(appendBtn, pasteBtn), filterEntry)
+	header := container.NewVBox(
+		setsControls,
+		container.NewBorder(nil, nil, nil, container.NewHBox(appendBtn, pasteBtn), filterEntry),
+	)
 	bottomButtons := container.NewHBox(importBtn, exportBtn, clearBtn)
 	bottomControls := container.NewBorder(nil, nil, modeControls, bottomButtons)
 
@@ -1237,12 +1370,14 @@ func (u *UI) LocationSelector() {
 
 			selectedLocation := filteredLocations[id.Row-1]
 			fmt.Printf("Selected: %+v\n", selectedLocation)
-			u.runPrivileged(func() {
+			u.runPrivileged(func(ctx context.Context) error {
 				fyne.Do(func() {
 					window.Hide()
 					u.setLocationShown(false)
 				})
-				u.vpnmgr.ConnectToLocation(selectedLocation)
+				hideProgress := u.showConnectProgress(selectedLocation)
+				defer hideProgress()
+				return u.vpnmgr.ConnectWithFailover(ctx, selectedLocation)
 			})
 		}
 
@@ -1265,8 +1400,11 @@ func (u *UI) LocationSelector() {
 		u.setLocationShown(true)
 
 		go func() {
-			locs := u.vpnmgr.ListLocations()
+			locs, err := u.vpnmgr.ListLocations(u.ctx)
 			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(errors.New(cliErrorMessage(err)), window)
+				}
 				if len(locs) > 0 {
 					pruned, pruneErr := commands.PruneAndSaveLocationBookmarks(bookmarks, locs)
 					if pruneErr != nil {
@@ -1281,16 +1419,3 @@ func (u *UI) LocationSelector() {
 		}()
 	})
 }
-
-func showInfiniteProgressDialog(title, message string, window fyne.Window) func() {
-	bar := widget.NewProgressBarInfinite()
-	content := container.NewVBox(widget.NewLabel(message), bar)
-	d := dialog.NewCustomWithoutButtons(title, content, window)
-	d.Show()
-	return func() {
-		fyne.Do(func() {
-			bar.Stop()
-			d.Hide()
-		})
-	}
-}